@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dschaaff/kube-janitor/pkg/janitor"
@@ -13,6 +19,33 @@ import (
 	"github.com/dschaaff/kube-janitor/pkg/janitor/shutdown"
 )
 
+// Exit codes of the janitor. Configuration and startup errors exit via
+// log.Fatal, which uses exitStartupError.
+const (
+	exitOK           = 0 // the run completed, whether or not anything was deleted
+	exitStartupError = 1 // invalid configuration or failure to start
+	exitRunFailure   = 2 // the run failed or some resources failed to be processed
+	exitNotConfirmed = 3 // the planned deletions were not confirmed with --confirm
+)
+
+// cleaner runs a single cleanup, e.g. a janitor.Janitor
+type cleaner interface {
+	CleanUp(ctx context.Context) (*janitor.CleanupResult, error)
+}
+
+// planner plans the deletions of a run and limits the following run to the
+// confirmed deletions, e.g. a janitor.Janitor
+type planner interface {
+	PlanDeletions(ctx context.Context) ([]janitor.Decision, error)
+	ConfirmDeletions(deletions []janitor.Decision)
+}
+
+// scheduledCleaner is a cleaner that knows when its next run is due
+type scheduledCleaner interface {
+	cleaner
+	NextRunIn(now time.Time) time.Duration
+}
+
 var (
 	version   = "dev"     // Will be set during build with -ldflags
 	buildDate = "unknown" // Will be set during build with -ldflags
@@ -22,12 +55,25 @@ var (
 func main() {
 	log.Printf("Kubernetes Janitor %s (built: %s, commit: %s) starting up...",
 		version, buildDate, gitCommit)
+	janitor.SetBuildInfo(version, gitCommit, buildDate)
 
 	config := janitor.NewConfig()
 	config.AddFlags(flag.CommandLine)
 
 	flag.Parse() // Parse flags after they've been added to flag.CommandLine
 
+	// Flag parsing stops at the first argument, so an argument is most likely
+	// the value of a flag like --include-cluster-resources that was given
+	// without "=", and all following flags would be ignored
+	if flag.NArg() > 0 {
+		log.Fatalf("Unexpected arguments %v, values of optional flags must be given as --flag=value, e.g. --include-cluster-resources=clusterroles", flag.Args())
+	}
+
+	// Options from the config file apply unless given on the command line
+	if err := config.LoadConfigFile(flag.CommandLine); err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+
 	// Parse the comma-separated string flags after flag.Parse()
 	config.ParseStringFlags()
 
@@ -40,7 +86,9 @@ func main() {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
 	}
 
-	if config.DryRun {
+	if config.DryRunServer {
+		log.Println("Running in server-side dry-run mode")
+	} else if config.DryRun {
 		log.Println("Running in dry-run mode")
 	}
 
@@ -65,59 +113,196 @@ func main() {
 		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	if hookName := os.Getenv("RESOURCE_CONTEXT_HOOK"); hookName != "" {
-		hookFunc, err := hooks.GetHook(hookName)
+	if config.ContextName != "" {
+		// Tell the logs and metrics of several clusters apart
+		log.SetPrefix("cluster=" + config.ContextName + " ")
+		janitor.SetMetricsCluster(config.ContextName)
+	}
+
+	if seed := os.Getenv("RANDOM_DICE_SEED"); seed != "" {
+		value, err := strconv.ParseInt(seed, 10, 64)
 		if err != nil {
-			log.Fatalf("Failed to get hook: %v", err)
+			log.Fatalf("Invalid RANDOM_DICE_SEED: %v", err)
 		}
-		// Convert hooks.ResourceContextHook to janitor.ResourceContextHook
-		config.ResourceContextHook = func(resource interface{}, cache map[string]interface{}) map[string]interface{} {
-			return hookFunc(resource, cache)
+		hooks.SeedRandomDice(value)
+	}
+
+	if hookName := os.Getenv("RESOURCE_CONTEXT_HOOK"); hookName != "" {
+		var hookFunc hooks.ClientAwareResourceContextHook
+		if hookName == hooks.PrometheusHookName {
+			prometheusHook, err := hooks.NewPrometheusHook(os.Getenv("PROMETHEUS_URL"), os.Getenv("PROMETHEUS_QUERY"), os.Getenv("PROMETHEUS_CONTEXT_KEY"))
+			if err != nil {
+				log.Fatalf("Failed to get hook: %v", err)
+			}
+			hookFunc = hooks.WithClients(prometheusHook)
+		} else {
+			var err error
+			hookFunc, err = hooks.GetClientAwareHook(hookName)
+			if err != nil {
+				log.Fatalf("Failed to get hook: %v", err)
+			}
 		}
+		// Convert hooks.ClientAwareResourceContextHook to janitor.ClientAwareResourceContextHook
+		config.ClientAwareResourceContextHook = janitor.ClientAwareResourceContextHook(hookFunc)
 	}
 
 	if err := config.LoadRules(); err != nil {
 		log.Fatalf("Failed to load rules: %v", err)
 	}
 
+	if err := config.LoadWebhookTargets(); err != nil {
+		log.Fatalf("Failed to load webhook targets: %v", err)
+	}
+
 	j, err := janitor.New(config)
 	if err != nil {
 		log.Fatalf("Failed to create janitor: %v", err)
 	}
+	defer j.Close()
+
+	if err := j.CheckServerVersion(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := j.CheckResourceTypes(); err != nil {
+		log.Fatalf("Failed to check resource types: %v", err)
+	}
+	if err := j.CheckAgainstCluster(context.Background()); err != nil {
+		log.Printf("Warning: failed to check configuration against the cluster: %v", err)
+	}
 
 	// Set up context with cancellation and signal handling
 	ctx, gs := shutdown.ShutdownWithContext()
 
+	if config.ListenAddress != "" {
+		janitor.StartServer(ctx, config, j)
+	}
+
+	if config.OTLPEndpoint != "" {
+		shutdownTracing, err := janitor.InitTracing(ctx, config.OTLPEndpoint, version)
+		if err != nil {
+			log.Fatalf("Failed to initialize tracing: %v", err)
+		}
+		defer shutdownTracing(context.Background())
+		log.Printf("Exporting traces to %s", config.OTLPEndpoint)
+	}
+
 	// Set safe to exit when we're done with cleanup
 	defer gs.SetSafeToExit(true)
 
 	if config.Once {
-		startTime := time.Now()
-		if err := j.CleanUp(ctx); err != nil {
-			log.Printf("Error during cleanup: %v", err)
-			os.Exit(1)
+		if config.Confirm {
+			stat, err := os.Stdin.Stat()
+			interactive := err == nil && stat.Mode()&os.ModeCharDevice != 0
+			confirmed, err := confirmDeletions(ctx, j, os.Stdin, os.Stdout, interactive, os.Getenv("CONFIRM_TOKEN"))
+			if err != nil {
+				log.Printf("Error during cleanup: %v", err)
+				os.Exit(exitRunFailure)
+			}
+			if !confirmed {
+				os.Exit(exitNotConfirmed)
+			}
+		}
+		if code := runOnce(ctx, j); code != exitOK {
+			os.Exit(code)
 		}
-		log.Printf("Cleanup completed in %v", time.Since(startTime))
 		return
 	}
 
-	// Run periodic cleanup
-	ticker := time.NewTicker(time.Duration(config.Interval) * time.Second)
-	defer ticker.Stop()
+	if config.NotificationsEnabled() {
+		go j.RunNotificationScheduler(ctx)
+	}
+	runLoop(ctx, j, time.Duration(config.Interval)*time.Second)
+}
+
+// runLoop runs cleanups until the context is cancelled, waking up early for
+// namespaces with a shorter interval. The timer is only reset once a run has
+// finished, so runs never overlap, even with an interval of 0 which runs them
+// back-to-back.
+func runLoop(ctx context.Context, c scheduledCleaner, interval time.Duration) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-timer.C:
+			// Both cases are ready at once when runs are back-to-back
+			if ctx.Err() != nil {
+				return
+			}
 			startTime := time.Now()
-			if err := j.CleanUp(ctx); err != nil {
+			if _, err := c.CleanUp(ctx); err != nil {
 				log.Printf("Error during cleanup: %v", err)
 			} else {
 				log.Printf("Cleanup completed in %v", time.Since(startTime))
 			}
+			timer.Reset(c.NextRunIn(time.Now()))
+		}
+	}
+}
+
+// runOnce performs a single cleanup run and returns the exit code
+func runOnce(ctx context.Context, c cleaner) int {
+	startTime := time.Now()
+	result, err := c.CleanUp(ctx)
+	if err != nil {
+		log.Printf("Error during cleanup: %v", err)
+		return exitRunFailure
+	}
+	log.Printf("Cleanup completed in %v", time.Since(startTime))
+
+	if result.Errors > 0 {
+		log.Printf("Cleanup completed with %d errors", result.Errors)
+		return exitRunFailure
+	}
+	return exitOK
+}
+
+// confirmDeletions lists the planned deletions and reports whether they are
+// confirmed, either by the token of the plan or by answering yes on the
+// terminal. Without a terminal the token is required, so that unattended runs
+// never delete anything that was not reviewed. Once confirmed, the following
+// run only deletes the planned resources. A plan without deletions needs no
+// confirmation, the run still sends its notifications but deletes nothing.
+func confirmDeletions(ctx context.Context, p planner, in io.Reader, out io.Writer, interactive bool, token string) (bool, error) {
+	deletions, err := p.PlanDeletions(ctx)
+	if err != nil {
+		return false, err
+	}
+	if len(deletions) == 0 {
+		fmt.Fprintln(out, "Nothing to delete")
+		p.ConfirmDeletions(nil)
+		return true, nil
+	}
+
+	if err := janitor.WriteDecisionTable(out, deletions); err != nil {
+		return false, fmt.Errorf("failed to write planned deletions: %v", err)
+	}
+	planToken := janitor.PlanToken(deletions)
+
+	if token != "" {
+		if token != planToken {
+			fmt.Fprintf(out, "CONFIRM_TOKEN does not match the planned deletions (token %s), not deleting anything\n", planToken)
+			return false, nil
 		}
+		p.ConfirmDeletions(deletions)
+		return true, nil
+	}
+
+	if !interactive {
+		fmt.Fprintf(out, "Not running in a terminal, set CONFIRM_TOKEN=%s to confirm these %d deletions\n", planToken, len(deletions))
+		return false, nil
+	}
+
+	fmt.Fprintf(out, "Delete these %d resources? Type yes to confirm: ", len(deletions))
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	if strings.TrimSpace(answer) != "yes" {
+		fmt.Fprintln(out, "Not confirmed, not deleting anything")
+		return false, nil
 	}
+	p.ConfirmDeletions(deletions)
+	return true, nil
 }
 
 // getEnvOrDefault moved to pkg/janitor/config.go
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dschaaff/kube-janitor/pkg/janitor"
+)
+
+// fakeCleaner returns a fixed result and error from CleanUp
+type fakeCleaner struct {
+	result *janitor.CleanupResult
+	err    error
+}
+
+func (c *fakeCleaner) CleanUp(ctx context.Context) (*janitor.CleanupResult, error) {
+	return c.result, c.err
+}
+
+func TestRunOnceExitCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		cleaner *fakeCleaner
+		want    int
+	}{
+		{
+			name:    "nothing to do",
+			cleaner: &fakeCleaner{result: &janitor.CleanupResult{}},
+			want:    exitOK,
+		},
+		{
+			name:    "resources deleted",
+			cleaner: &fakeCleaner{result: &janitor.CleanupResult{Processed: 2, Deleted: map[string]int{"pods": 2}}},
+			want:    exitOK,
+		},
+		{
+			name:    "some deletions failed",
+			cleaner: &fakeCleaner{result: &janitor.CleanupResult{Processed: 2, Errors: 1}},
+			want:    exitRunFailure,
+		},
+		{
+			name:    "run failed",
+			cleaner: &fakeCleaner{err: errors.New("failed to get resource types")},
+			want:    exitRunFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runOnce(context.Background(), tt.cleaner); got != tt.want {
+				t.Errorf("runOnce() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// loopCleaner counts its runs, cancels the context after a number of runs
+// and fails if runs overlap
+type loopCleaner struct {
+	t       *testing.T
+	cancel  context.CancelFunc
+	runs    int
+	maxRuns int
+	running atomic.Bool
+}
+
+func (c *loopCleaner) CleanUp(ctx context.Context) (*janitor.CleanupResult, error) {
+	if !c.running.CompareAndSwap(false, true) {
+		c.t.Error("Expected runs not to overlap")
+	}
+	defer c.running.Store(false)
+
+	c.runs++
+	if c.runs == c.maxRuns {
+		c.cancel()
+	}
+	return &janitor.CleanupResult{}, nil
+}
+
+func (c *loopCleaner) NextRunIn(now time.Time) time.Duration {
+	return 0
+}
+
+func TestRunLoopZeroInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &loopCleaner{t: t, cancel: cancel, maxRuns: 5}
+
+	done := make(chan struct{})
+	go func() {
+		runLoop(ctx, c, 0)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the loop to stop once the context is cancelled")
+	}
+	if c.runs != c.maxRuns {
+		t.Errorf("Expected %d back-to-back runs, got %d", c.maxRuns, c.runs)
+	}
+}
+
+// fakePlanner returns fixed planned deletions and records the confirmed ones
+type fakePlanner struct {
+	deletions []janitor.Decision
+	confirmed []janitor.Decision
+	limited   bool
+}
+
+func (p *fakePlanner) PlanDeletions(ctx context.Context) ([]janitor.Decision, error) {
+	return p.deletions, nil
+}
+
+func (p *fakePlanner) ConfirmDeletions(deletions []janitor.Decision) {
+	p.confirmed = deletions
+	p.limited = true
+}
+
+func TestConfirmDeletions(t *testing.T) {
+	deletions := []janitor.Decision{
+		{Kind: "Pod", Namespace: "default", Name: "expired", Decision: janitor.DecisionDelete},
+	}
+	token := janitor.PlanToken(deletions)
+
+	tests := []struct {
+		name        string
+		deletions   []janitor.Decision
+		input       string
+		interactive bool
+		token       string
+		want        bool
+	}{
+		{name: "confirmed on the terminal", deletions: deletions, input: "yes\n", interactive: true, want: true},
+		{name: "declined on the terminal", deletions: deletions, input: "no\n", interactive: true},
+		{name: "no answer on the terminal", deletions: deletions, interactive: true},
+		{name: "confirmed by token", deletions: deletions, token: token, want: true},
+		{name: "token of another plan", deletions: deletions, token: "0123456789ab"},
+		{name: "token required without a terminal", deletions: deletions, input: "yes\n"},
+		{name: "nothing to delete", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			p := &fakePlanner{deletions: tt.deletions}
+			got, err := confirmDeletions(context.Background(), p,
+				strings.NewReader(tt.input), &out, tt.interactive, tt.token)
+			if err != nil {
+				t.Fatalf("confirmDeletions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("confirmDeletions() = %v, want %v, output:\n%s", got, tt.want, out.String())
+			}
+			// The run is limited to exactly the confirmed plan
+			if got && (!p.limited || !reflect.DeepEqual(p.confirmed, tt.deletions)) {
+				t.Errorf("Expected the run to be limited to the planned deletions %v, got %v", tt.deletions, p.confirmed)
+			}
+			if len(tt.deletions) > 0 && !strings.Contains(out.String(), "expired") {
+				t.Errorf("Expected the planned deletions to be listed, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestConfigErrorExitCode(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		wantLog string
+	}{
+		"invalid flag combination": {args: []string{"--dry-run-table"}, wantLog: "Invalid configuration"},
+		// The list would be taken as an argument and --dry-run ignored
+		"flag value without =": {args: []string{"--include-cluster-resources", "clusterroles", "--dry-run"}, wantLog: "Unexpected arguments [clusterroles --dry-run]"},
+	}
+
+	// Run main in a subprocess, as configuration errors exit the process
+	if tt, ok := tests[os.Getenv("KUBE_JANITOR_TEST_MAIN")]; ok {
+		os.Args = append([]string{"kube-janitor"}, tt.args...)
+		main()
+		return
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestConfigErrorExitCode$")
+			cmd.Env = append(os.Environ(), "KUBE_JANITOR_TEST_MAIN="+name)
+			output, err := cmd.CombinedOutput()
+			if !strings.Contains(string(output), tt.wantLog) {
+				t.Errorf("Expected %q in the output, got:\n%s", tt.wantLog, output)
+			}
+
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("Expected the process to exit with an error, got %v", err)
+			}
+			if code := exitErr.ExitCode(); code != exitStartupError {
+				t.Errorf("Exit code = %d, want %d", code, exitStartupError)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package janitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuditEntry is a record of a deletion in the audit log
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	UID       string    `json:"uid"`
+	Reason    string    `json:"reason"`
+	RuleID    string    `json:"rule_id,omitempty"`
+	Cluster   string    `json:"cluster,omitempty"`
+
+	// The reason or requester given in the reason annotation of the resource
+	AnnotatedReason string `json:"annotated_reason,omitempty"`
+}
+
+// openAuditLog opens the audit log for appending, or returns nil if no path
+// is configured
+func openAuditLog(path string) (*os.File, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	return f, nil
+}
+
+// writeAuditLog appends a deletion to the audit log as a JSON line. Every
+// entry is synced to disk, so that records survive a crash.
+func (j *Janitor) writeAuditLog(obj metav1.Object, reason, ruleID string) error {
+	j.auditLogMutex.Lock()
+	defer j.auditLogMutex.Unlock()
+
+	if j.auditLog == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(AuditEntry{
+		Timestamp: time.Now().UTC(),
+		Kind:      objectGVK(obj).Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		UID:       string(obj.GetUID()),
+		Reason:    reason,
+		RuleID:    ruleID,
+		Cluster:   j.config.ContextName,
+
+		AnnotatedReason: j.annotatedReason(obj),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit log entry: %v", err)
+	}
+
+	if _, err := j.auditLog.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log entry: %v", err)
+	}
+	return j.auditLog.Sync()
+}
+
+// annotatedReason returns the reason or requester of the deletion given in the
+// configured reason annotation of a resource, if any
+func (j *Janitor) annotatedReason(obj metav1.Object) string {
+	if j.config.ReasonAnnotation == "" {
+		return ""
+	}
+	return strings.TrimSpace(obj.GetAnnotations()[j.config.ReasonAnnotation])
+}
+
+// withAnnotatedReason appends the annotated reason of a resource to a message
+// about its deletion
+func (j *Janitor) withAnnotatedReason(obj metav1.Object, message string) string {
+	if reason := j.annotatedReason(obj); reason != "" {
+		return fmt.Sprintf("%s, reason: %s", message, reason)
+	}
+	return message
+}
+
+// closeAuditLog closes the audit log, if it is open
+func (j *Janitor) closeAuditLog() {
+	j.auditLogMutex.Lock()
+	defer j.auditLogMutex.Unlock()
+
+	if j.auditLog != nil {
+		j.auditLog.Close()
+		j.auditLog = nil
+	}
+}
@@ -0,0 +1,161 @@
+package janitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	// The fake clientset does not generate event names, so accept all events
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	ttlPod := newUnstructuredPod("ttl-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	ttlPod.SetUID("uid-ttl")
+	rulePod := newUnstructuredPod("rule-pod", "default", time.Now().Add(-2*time.Hour), nil)
+	rulePod.SetUID("uid-rule")
+	rulePod.SetLabels(map[string]string{"environment": "test"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"},
+		ttlPod, rulePod,
+		newUnstructuredPod("valid-pod", "default", time.Now(), map[string]string{TTLAnnotation: "1h"}),
+	)
+
+	rule := Rule{ID: "test-pods", Resources: []string{"*"}, JMESPath: "metadata.labels.environment == 'test'", TTL: "1h"}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	config := NewConfig()
+	config.NotifyBackends = nil
+	config.Rules = []Rule{rule}
+	config.AuditLog = path
+
+	j, err := NewWithClients(config, clientset, dynamicClient)
+	if err != nil {
+		t.Fatalf("NewWithClients() error = %v", err)
+	}
+	defer j.Close()
+
+	if _, err := j.CleanUp(context.Background()); err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	entries := make(map[string]AuditEntry)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("Invalid audit log line %q: %v", scanner.Text(), err)
+		}
+		entries[entry.Name] = entry
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected an audit log entry per deletion, got %+v", entries)
+	}
+	if entry := entries["ttl-pod"]; entry.UID != "uid-ttl" || entry.Kind != "Pod" || entry.Namespace != "default" || entry.RuleID != "" || entry.Reason == "" || entry.Timestamp.IsZero() {
+		t.Errorf("Unexpected audit log entry for ttl-pod: %+v", entry)
+	}
+	if entry := entries["rule-pod"]; entry.UID != "uid-rule" || entry.RuleID != "test-pods" {
+		t.Errorf("Unexpected audit log entry for rule-pod: %+v", entry)
+	}
+}
+
+func TestAnnotatedReason(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var events []*corev1.Event
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		events = append(events, action.(k8stesting.CreateAction).GetObject().(*corev1.Event))
+		return true, nil, nil
+	})
+
+	const reasonAnnotation = "example.com/requested-by"
+	expiring := newUnstructuredPod("expiring", "default", time.Now().Add(-30*time.Minute),
+		map[string]string{TTLAnnotation: "1h", reasonAnnotation: "alice, load test OPS-42"})
+	expired := newUnstructuredPod("expired", "default", time.Now().Add(-2*time.Hour),
+		map[string]string{TTLAnnotation: "1h", reasonAnnotation: "alice, load test OPS-42"})
+	auditLog, err := openAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("openAuditLog() error = %v", err)
+	}
+	notifier := &recordingNotifier{}
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), expiring.DeepCopy(), expired.DeepCopy()),
+		config: &Config{
+			IncludeResources:   []string{"all"},
+			IncludeNamespaces:  []string{"all"},
+			DeleteNotification: 3600,
+			ReasonAnnotation:   reasonAnnotation,
+		},
+		cache:    make(map[string]interface{}),
+		notifier: notifier,
+		auditLog: auditLog,
+	}
+	defer j.Close()
+
+	for _, pod := range []*unstructured.Unstructured{expiring, expired} {
+		if err := j.handleResource(context.Background(), pod, make(map[string]int), make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource(%s) error = %v", pod.GetName(), err)
+		}
+	}
+
+	const want = "reason: alice, load test OPS-42"
+	if len(notifier.messages) != 1 || !strings.Contains(notifier.messages[0].Message, want) {
+		t.Errorf("Expected the notification to contain %q, got %+v", want, notifier.messages)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected a notification and an expiry event, got %d events", len(events))
+	}
+	for _, event := range events {
+		if !strings.Contains(event.Message, want) {
+			t.Errorf("Expected the %s event to contain %q, got %q", event.Reason, want, event.Message)
+		}
+	}
+
+	data, err := os.ReadFile(auditLog.Name())
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("Invalid audit log %q: %v", data, err)
+	}
+	if entry.AnnotatedReason != "alice, load test OPS-42" {
+		t.Errorf("Expected the annotated reason in the audit log, got %+v", entry)
+	}
+}
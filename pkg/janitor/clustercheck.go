@@ -0,0 +1,95 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CheckAgainstCluster warns about configured namespaces that don't exist and
+// included resources that aren't served by the cluster, which are most likely
+// typos that would silently cause nothing to be processed
+func (j *Janitor) CheckAgainstCluster(ctx context.Context) error {
+	if err := j.checkNamespaces(ctx); err != nil {
+		return err
+	}
+
+	resourceTypes, err := j.getResourceTypes()
+	if err != nil {
+		return fmt.Errorf("failed to get resource types: %v", err)
+	}
+	_, unknown := ResolveShortNames(j.config.IncludeResources, resourceTypes)
+	for _, name := range unknown {
+		log.Printf("Warning: included resource %q is not served by the cluster", name)
+	}
+	return nil
+}
+
+// checkNamespaces warns about configured namespaces that don't exist
+func (j *Janitor) checkNamespaces(ctx context.Context) error {
+	// In single namespace mode the janitor doesn't need to list namespaces
+	if j.config.Namespace != "" {
+		_, err := j.client.CoreV1().Namespaces().Get(ctx, j.config.Namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			log.Printf("Warning: namespace %q does not exist", j.config.Namespace)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get namespace %s: %v", j.config.Namespace, err)
+		}
+		return nil
+	}
+
+	namespaces, err := j.namespaceNames(ctx)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(namespaces))
+	for _, name := range namespaces {
+		existing[name] = true
+	}
+
+	for _, name := range j.config.IncludeNamespaces {
+		if name != "all" && !existing[name] {
+			log.Printf("Warning: included namespace %q does not exist", name)
+		}
+	}
+	for _, name := range j.config.ExcludeNamespaces {
+		if !existing[name] {
+			log.Printf("Warning: excluded namespace %q does not exist", name)
+		}
+	}
+	return nil
+}
+
+// CheckResourceTypes discovers the deletable resource types and fails if
+// there are fewer than the configured minimum, which most likely means that
+// the janitor lacks RBAC permissions and would silently do nothing. Short
+// names in the resource filters are resolved against the discovered types.
+func (j *Janitor) CheckResourceTypes() error {
+	resourceTypes, err := j.getResourceTypes()
+	if err != nil {
+		return fmt.Errorf("failed to get resource types: %v", err)
+	}
+	j.resolveResourceNames(resourceTypes)
+	return j.checkResourceTypeCount(resourceTypes)
+}
+
+// checkResourceTypeCount fails if fewer resource types than the configured
+// minimum were discovered. The outcome is remembered for the readiness
+// endpoint.
+func (j *Janitor) checkResourceTypeCount(resourceTypes []ResourceType) error {
+	var err error
+	if len(resourceTypes) < j.config.MinResourceTypes {
+		err = fmt.Errorf("discovered only %d deletable resource types, fewer than the minimum of %d, check the RBAC permissions of the janitor",
+			len(resourceTypes), j.config.MinResourceTypes)
+	}
+
+	j.statusMutex.Lock()
+	defer j.statusMutex.Unlock()
+	j.notReady = err
+	return err
+}
@@ -0,0 +1,157 @@
+package janitor
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckAgainstCluster(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       Config
+		wantWarnings []string
+	}{
+		{
+			name: "existing names",
+			config: Config{
+				IncludeResources:  []string{"pods"},
+				IncludeNamespaces: []string{"default"},
+				ExcludeNamespaces: []string{"kube-system"},
+			},
+		},
+		{
+			name: "nonexistent namespaces",
+			config: Config{
+				IncludeResources:  []string{"all"},
+				IncludeNamespaces: []string{"defualt", "default"},
+				ExcludeNamespaces: []string{"kube-sytem"},
+			},
+			wantWarnings: []string{
+				`included namespace "defualt" does not exist`,
+				`excluded namespace "kube-sytem" does not exist`,
+			},
+		},
+		{
+			name:         "nonexistent single namespace",
+			config:       Config{IncludeResources: []string{"all"}, Namespace: "defualt"},
+			wantWarnings: []string{`namespace "defualt" does not exist`},
+		},
+		{
+			name:         "unknown resource",
+			config:       Config{IncludeResources: []string{"podz"}, IncludeNamespaces: []string{"all"}},
+			wantWarnings: []string{`included resource "podz" is not served by the cluster`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+			)
+			clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+				{
+					GroupVersion: "v1",
+					APIResources: []metav1.APIResource{
+						{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+					},
+				},
+			}
+			j := &Janitor{client: clientset, config: &tt.config}
+
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			if err := j.CheckAgainstCluster(context.Background()); err != nil {
+				t.Fatalf("CheckAgainstCluster() error = %v", err)
+			}
+
+			output := buf.String()
+			if got := strings.Count(output, "Warning:"); got != len(tt.wantWarnings) {
+				t.Errorf("Expected %d warnings, got %d:\n%s", len(tt.wantWarnings), got, output)
+			}
+			for _, want := range tt.wantWarnings {
+				if !strings.Contains(output, want) {
+					t.Errorf("Expected warning %q, got:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckResourceTypes(t *testing.T) {
+	tests := []struct {
+		name             string
+		resources        []metav1.APIResource
+		minResourceTypes int
+		wantErr          bool
+	}{
+		{
+			name: "enough resource types",
+			resources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+			minResourceTypes: 1,
+		},
+		{
+			name: "no deletable resource types",
+			resources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list"}},
+			},
+			minResourceTypes: 1,
+			wantErr:          true,
+		},
+		{
+			name: "check disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+				{GroupVersion: "v1", APIResources: tt.resources},
+			}
+			j := &Janitor{
+				client: clientset,
+				config: &Config{
+					IncludeResources:  []string{"all"},
+					IncludeNamespaces: []string{"all"},
+					MinResourceTypes:  tt.minResourceTypes,
+				},
+			}
+
+			err := j.CheckResourceTypes()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckResourceTypes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			server := httptest.NewServer(NewServeMux(&Config{}, j))
+			defer server.Close()
+			resp, err := http.Get(server.URL + "/readyz")
+			if err != nil {
+				t.Fatalf("Failed to get readiness: %v", err)
+			}
+			resp.Body.Close()
+			wantStatus := http.StatusOK
+			if tt.wantErr {
+				wantStatus = http.StatusServiceUnavailable
+			}
+			if resp.StatusCode != wantStatus {
+				t.Errorf("/readyz status = %d, want %d", resp.StatusCode, wantStatus)
+			}
+		})
+	}
+}
@@ -4,97 +4,391 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+const (
+	defaultExcludeResources       = DefaultExcludeResources
+	defaultExcludeNamespaces      = DefaultExcludeNamespaces
+	defaultDeleteOrder            = "pods,persistentvolumeclaims,persistentvolumes"
+	defaultInterval               = DefaultInterval
+	defaultDeleteFailureThreshold = 3
+	defaultMinResourceTypes       = 1
+	defaultPauseConfigMap         = "kube-janitor/pause"
+	defaultLogFormat              = "%(asctime)s %(levelname)s: %(message)s"
 )
 
+// Supported --dry-run modes
 const (
-	defaultExcludeResources  = "events,controllerrevisions,endpoints"
-	defaultExcludeNamespaces = "kube-system"
-	defaultInterval          = 30
-	defaultLogFormat         = "%(asctime)s %(levelname)s: %(message)s"
+	DryRunClient = "client"
+	DryRunServer = "server"
 )
 
 // Config holds all configuration options for the janitor
 type Config struct {
 	// Command line flags
+	ConfigFile               string
 	DryRun                   bool
+	DryRunServer             bool
+	DryRunTable              bool
+	DryRunOnceThenApply      bool
 	Debug                    bool
 	Quiet                    bool
 	Once                     bool
+	Confirm                  bool
+	Watch                    bool
 	Interval                 int
+	RunTimeout               time.Duration
+	SoftDeleteGrace          time.Duration
+	ExpiredGrace             time.Duration
+	PauseConfigMap           string
+	PolicyConfigMap          string
+	AuditLog                 string
 	WaitAfterDelete          int
+	DeleteFailureThreshold   int
+	QuietRunThreshold        int
 	DeleteNotification       int
+	DeleteNamespaceContents  bool
 	IncludeResources         []string
 	ExcludeResources         []string
+	Namespace                string
 	IncludeNamespaces        []string
 	ExcludeNamespaces        []string
+	ExcludeLabels            []string
+	ExcludeAnnotations       []string
+	ExcludeJMESPaths         []string
+	IncludeGroups            []string
+	ExcludeGroups            []string
+	DeleteOrder              []string
+	CustomResourcesOnly      bool
+	BuiltinResourcesOnly     bool
+	APIPreferences           [][]string
+	PVCReferenceResources    []string
+	AllowedKinds             []string
 	RulesFile                string
+	WarnRuleConflicts        bool
+	EventOnKeep              bool
 	DeploymentTimeAnnotation string
+	LastActivityAnnotation   string
+	TTLBaseFields            map[string]string
 	IncludeClusterResources  bool
+	ClusterResources         []string
 	LogFormat                string
 	Parallelism              int
+	MaxConcurrentDeletes     int
+	MinResourceTypes         int
+	MaxTTL                   string
+	AllowForeverTTL          bool
+	ProtectOlderThan         string
+	CreatedBefore            string
+	CreatedAfter             string
+	ProtectedPriorityClasses []string
+	RespectPDBs              bool
+	ResolveOwners            bool
+	UseEviction              bool
+	NotifyBackends           []string
+	SNSTopicARN              string
+	SMTPHost                 string
+	SMTPFrom                 string
+	SMTPTo                   []string
+	SMTPUsername             string
+	SMTPPassword             string
+	SMTPTLS                  bool
+	PagerDutyRoutingKey      string
+	OTLPEndpoint             string
+	ImpersonateUser          string
+	ImpersonateGroups        []string
+	ImpersonateUID           string
+	ListenAddress            string
+	ContextName              string
+	ReasonAnnotation         string
+	EnablePprof              bool
+	WebhookURL               string
+	WebhookTargetsFile       string
+	WebhookSecret            string
+	WebhookSignatureHeader   string
 
 	// Internal string fields for flag parsing
 	includeResourcesStr  string
 	excludeResourcesStr  string
 	includeNamespacesStr string
 	excludeNamespacesStr string
+	includeGroupsStr     string
+	excludeGroupsStr     string
+	apiPreferencesStr    string
+	pvcReferenceStr      string
+	allowedKindsStr      string
+	deleteOrderStr       string
+	notifyBackendsStr    string
+	smtpToStr            string
+	protectedPriorityStr string
+	ttlBaseFieldsStr     string
 
 	// Additional configuration
-	Rules               []Rule
-	ResourceContextHook ResourceContextHook
-	WebhookURL          string
+	Rules                          []Rule
+	excludeExprs                   []*jmespath.JMESPath
+	ResourceContextHook            ResourceContextHook
+	ClientAwareResourceContextHook ClientAwareResourceContextHook
+	WebhookTargets                 []WebhookTarget
 }
 
 // NewConfig creates a new Config with default values
 func NewConfig() *Config {
 	return &Config{
-		Interval:          defaultInterval,
-		LogFormat:         defaultLogFormat,
-		ExcludeResources:  strings.Split(defaultExcludeResources, ","),
-		ExcludeNamespaces: strings.Split(defaultExcludeNamespaces, ","),
-		IncludeResources:  []string{"all"},
-		IncludeNamespaces: []string{"all"},
-		Parallelism:       DefaultParallelism,
+		Interval:               defaultInterval,
+		DeleteFailureThreshold: defaultDeleteFailureThreshold,
+		MinResourceTypes:       defaultMinResourceTypes,
+		ReasonAnnotation:       ReasonAnnotation,
+		PauseConfigMap:         defaultPauseConfigMap,
+		LogFormat:              defaultLogFormat,
+		ExcludeResources:       strings.Split(defaultExcludeResources, ","),
+		ExcludeNamespaces:      strings.Split(defaultExcludeNamespaces, ","),
+		IncludeResources:       []string{"all"},
+		IncludeNamespaces:      []string{"all"},
+		IncludeGroups:          []string{"all"},
+		DeleteOrder:            strings.Split(defaultDeleteOrder, ","),
+		Parallelism:            DefaultParallelism,
+		NotifyBackends:         []string{NotifyBackendWebhook},
 	}
 }
 
 // AddFlags adds command line flags to parse configuration
 func (c *Config) AddFlags(fs *flag.FlagSet) {
-	fs.BoolVar(&c.DryRun, "dry-run", false, "Dry run mode: do not change anything, just print what would be done")
+	fs.StringVar(&c.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "Load options from a YAML or JSON file mapping flag names to values, command line flags take precedence")
+	fs.Var(&dryRunFlag{config: c}, "dry-run", "Dry run mode: do not change anything, just print what would be done. Use --dry-run=server to send deletes to the API server as dry-run requests so that admission webhooks are run")
+	fs.BoolVar(&c.DryRunTable, "dry-run-table", false, "Print a table with the decision and reason for every resource at the end of each dry run")
+	fs.BoolVar(&c.DryRunOnceThenApply, "dry-run-once-then-apply", false, "Staged rollout: run the first clean up as dry run and only delete resources from the second run on, giving a chance to review the logged plan and abort")
 	fs.BoolVar(&c.Debug, "debug", false, "Debug mode: print more information")
 	fs.BoolVar(&c.Quiet, "quiet", false, "Quiet mode: Hides cleanup logs but keeps deletion logs")
 	fs.BoolVar(&c.Once, "once", false, "Run only once and exit")
-	fs.IntVar(&c.Interval, "interval", defaultInterval, "Loop interval in seconds")
+	fs.BoolVar(&c.Confirm, "confirm", false, "In --once mode, list the planned deletions and ask for confirmation before deleting (requires CONFIRM_TOKEN when not run in a terminal)")
+	fs.BoolVar(&c.Watch, "watch", false, "Watch resources with informers and process them from a local cache instead of listing them every interval")
+	fs.IntVar(&c.Interval, "interval", defaultInterval, "Loop interval in seconds (0 = run back-to-back)")
+	fs.DurationVar(&c.RunTimeout, "run-timeout", 0, "Maximum duration of a single clean up run, e.g. 10m (0 = no timeout)")
+	fs.StringVar(&c.PauseConfigMap, "pause-configmap", getEnvOrDefault("PAUSE_CONFIGMAP", defaultPauseConfigMap), "ConfigMap as namespace/name that pauses all deletions while it exists (empty to disable)")
+	fs.StringVar(&c.PolicyConfigMap, "policy-configmap", os.Getenv("POLICY_CONFIGMAP"), "ConfigMap as namespace/name with the default TTL and rules of namespaces, reloaded every run")
+	fs.StringVar(&c.AuditLog, "audit-log", os.Getenv("AUDIT_LOG"), "Append a JSON line for every deleted resource to this file")
+	fs.DurationVar(&c.SoftDeleteGrace, "soft-delete-grace", 0, "Mark expired resources with the janitor/deleted-at annotation first and only delete them once the mark is older than this grace period, e.g. 24h (0 = delete immediately)")
+	fs.DurationVar(&c.ExpiredGrace, "expired-grace", 0, "Only delete resources that were seen expired for this long, marking them with the janitor/expired-since annotation when first seen expired, e.g. 1h (0 = delete when first seen expired)")
 	fs.IntVar(&c.WaitAfterDelete, "wait-after-delete", 0, "Wait time after issuing a delete (in seconds)")
+	fs.IntVar(&c.DeleteFailureThreshold, "delete-failure-threshold", defaultDeleteFailureThreshold, "Number of consecutive failed deletes after which a resource is reported as a persistent deletion failure (0 = disabled)")
+	fs.IntVar(&c.MinResourceTypes, "min-resource-types", defaultMinResourceTypes, "Fail if discovery finds fewer deletable resource types than this, e.g. because of missing RBAC permissions (0 = disabled)")
+	fs.IntVar(&c.QuietRunThreshold, "quiet-run-threshold", 0, "Number of consecutive runs without deletions after which the janitor is reported as quiet, e.g. due to a broken rules file (0 = disabled)")
 	fs.IntVar(&c.DeleteNotification, "delete-notification", 0, "Send an event seconds before to warn of the deletion")
+	fs.BoolVar(&c.EventOnKeep, "event-on-keep", false, "Create an event explaining why an in-scope resource is kept, at most once a day per resource and reason")
+	fs.BoolVar(&c.DeleteNamespaceContents, "delete-namespace-contents", false, "Delete the resources in an expired namespace before deleting the namespace")
 
 	// Use custom variables to handle comma-separated lists
 	fs.StringVar(&c.includeResourcesStr, "include-resources", getEnvOrDefault("INCLUDE_RESOURCES", "all"), "Resources to consider for clean up (comma-separated)")
 	fs.StringVar(&c.excludeResourcesStr, "exclude-resources", getEnvOrDefault("EXCLUDE_RESOURCES", defaultExcludeResources), "Resources to exclude from clean up (comma-separated)")
+	fs.StringVar(&c.Namespace, "namespace", "", "Only clean up this namespace, skipping cluster-scoped resources (overrides include-namespaces and exclude-namespaces)")
 	fs.StringVar(&c.includeNamespacesStr, "include-namespaces", getEnvOrDefault("INCLUDE_NAMESPACES", "all"), "Include namespaces for clean up (comma-separated)")
 	fs.StringVar(&c.excludeNamespacesStr, "exclude-namespaces", getEnvOrDefault("EXCLUDE_NAMESPACES", defaultExcludeNamespaces), "Exclude namespaces from clean up (comma-separated)")
+	fs.Var((*stringSliceFlag)(&c.ExcludeLabels), "exclude-label", "Exclude resources with all of the given comma-separated key=value labels from clean up (can be repeated, resources matching any of them are excluded)")
+	fs.Var((*stringSliceFlag)(&c.ExcludeAnnotations), "exclude-annotation", "Exclude resources with all of the given comma-separated key=value annotations from clean up (can be repeated, resources matching any of them are excluded)")
+	fs.Var((*stringSliceFlag)(&c.ExcludeJMESPaths), "exclude-jmespath", "Exclude resources for which the given JMESPath expression is true from clean up (can be repeated, resources matching any of them are excluded)")
+	fs.StringVar(&c.includeGroupsStr, "include-groups", getEnvOrDefault("INCLUDE_GROUPS", "all"), "API groups to consider for clean up, use core for the core group (comma-separated)")
+	fs.StringVar(&c.excludeGroupsStr, "exclude-groups", os.Getenv("EXCLUDE_GROUPS"), "API groups to exclude from clean up, use core for the core group (comma-separated)")
+	fs.BoolVar(&c.CustomResourcesOnly, "custom-resources-only", false, "Only clean up custom resources, skipping the resource types of built-in API groups")
+	fs.BoolVar(&c.BuiltinResourcesOnly, "builtin-resources-only", false, "Only clean up the resource types of built-in API groups, skipping custom resources")
 
+	fs.StringVar(&c.apiPreferencesStr, "api-preferences", os.Getenv("API_PREFERENCES"), "Preferred APIs for resources served by multiple APIs, as comma-separated chains of group/version/plural joined by '>' (e.g. v1/events>events.k8s.io/v1/events)")
+	fs.StringVar(&c.pvcReferenceStr, "pvc-reference-resources", os.Getenv("PVC_REFERENCE_RESOURCES"), "Custom resources with pod templates whose PVCs count as referenced, as comma-separated group/version/plural (e.g. argoproj.io/v1alpha1/rollouts)")
+	fs.StringVar(&c.deleteOrderStr, "delete-order", getEnvOrDefault("DELETE_ORDER", defaultDeleteOrder), "Resource types to clean up first, in this order, so that e.g. Pods are deleted before the PVCs they mount (comma-separated)")
+	fs.StringVar(&c.allowedKindsStr, "allowed-kinds", os.Getenv("ALLOWED_KINDS"), "Resource types that may ever be deleted, as comma-separated plurals qualified with their API group (e.g. pods,deployments.apps), refusing to delete any other resource (empty = no restriction)")
+
+	fs.StringVar(&c.ContextName, "context-name", os.Getenv("CONTEXT_NAME"), "Name of the cluster, added to notifications, events, the audit log and metrics to tell clusters apart")
 	fs.StringVar(&c.RulesFile, "rules-file", os.Getenv("RULES_FILE"), "Load TTL rules from given file path")
+	fs.BoolVar(&c.WarnRuleConflicts, "warn-rule-conflicts", false, "Log a warning when several rules with differing TTLs match the same resource")
 	fs.StringVar(&c.DeploymentTimeAnnotation, "deployment-time-annotation", "", "Annotation that contains a resource's last deployment time")
-	fs.BoolVar(&c.IncludeClusterResources, "include-cluster-resources", false, "Include cluster scoped resources")
+	fs.StringVar(&c.ReasonAnnotation, "reason-annotation", ReasonAnnotation, "Annotation with the reason or requester of a resource's deletion, added to its events, delete notifications and audit log entry (empty = disabled)")
+	fs.StringVar(&c.LastActivityAnnotation, "last-activity-annotation", "", "Annotation that contains a resource's last activity time, recent activity extends the TTL")
+	fs.StringVar(&c.ttlBaseFieldsStr, "ttl-base-fields", os.Getenv("TTL_BASE_FIELDS"), "Timestamp fields that TTLs count from instead of the creation time, as comma-separated resource=field pairs (e.g. jobs=status.completionTime)")
+	fs.Var(&clusterResourcesFlag{config: c}, "include-cluster-resources", "Include cluster scoped resources, either all of them or only the given resource types (comma-separated, e.g. clusterroles.rbac.authorization.k8s.io)")
 	fs.StringVar(&c.LogFormat, "log-format", defaultLogFormat, "Set custom log format")
 	fs.IntVar(&c.Parallelism, "parallelism", DefaultParallelism, "Number of parallel workers for resource processing (0 = use number of CPUs)")
+	fs.IntVar(&c.MaxConcurrentDeletes, "max-concurrent-deletes", 0, "Maximum number of delete calls in flight at once, independent of the number of workers (0 = no limit)")
+	fs.StringVar(&c.MaxTTL, "max-ttl", "", "Maximum TTL applied to any resource, longer TTLs are clamped (e.g. 4w)")
+	fs.BoolVar(&c.AllowForeverTTL, "allow-forever-ttl", false, "Allow the forever TTL even when --max-ttl is set")
+	fs.StringVar(&c.ProtectOlderThan, "protect-older-than", "", "Never delete resources older than this age, even if they are expired (e.g. 180d)")
+	fs.StringVar(&c.CreatedBefore, "created-before", os.Getenv("CREATED_BEFORE"), "Only clean up resources created before this time (e.g. 2024-01-15 or 2024-01-15T10:00:00Z)")
+	fs.StringVar(&c.CreatedAfter, "created-after", os.Getenv("CREATED_AFTER"), "Only clean up resources created after this time (e.g. 2024-01-15 or 2024-01-15T10:00:00Z)")
+	fs.StringVar(&c.protectedPriorityStr, "protected-priority-classes", os.Getenv("PROTECTED_PRIORITY_CLASSES"), "Never delete pods with one of these priority classes (comma-separated, e.g. system-node-critical,system-cluster-critical)")
+	fs.BoolVar(&c.RespectPDBs, "respect-pdbs", false, "Never delete pods covered by a PodDisruptionBudget that allows no disruptions")
+	fs.BoolVar(&c.ResolveOwners, "resolve-owners", false, "Look up the owners of resources so that rules can match on _context.owner_exists, e.g. to delete ReplicaSets whose Deployment is gone")
+	fs.BoolVar(&c.UseEviction, "use-eviction", false, "Evict pods through the Eviction API instead of deleting them, so that PodDisruptionBudgets are honored, deleting pods whose eviction stays blocked")
+	fs.StringVar(&c.notifyBackendsStr, "notify-backend", getEnvOrDefault("NOTIFY_BACKEND", NotifyBackendWebhook), "Notification backends for delete notifications (comma-separated: webhook, sns, smtp, pagerduty)")
+	fs.StringVar(&c.SNSTopicARN, "sns-topic-arn", os.Getenv("SNS_TOPIC_ARN"), "ARN of the SNS topic to publish delete notifications to")
+	fs.StringVar(&c.SMTPHost, "smtp-host", os.Getenv("SMTP_HOST"), "SMTP server address (host:port) for email notifications")
+	fs.StringVar(&c.SMTPFrom, "smtp-from", os.Getenv("SMTP_FROM"), "Sender address for email notifications")
+	fs.StringVar(&c.smtpToStr, "smtp-to", os.Getenv("SMTP_TO"), "Recipient addresses for email notifications (comma-separated)")
+	fs.StringVar(&c.SMTPUsername, "smtp-username", os.Getenv("SMTP_USERNAME"), "Username for SMTP authentication")
+	fs.StringVar(&c.SMTPPassword, "smtp-password", os.Getenv("SMTP_PASSWORD"), "Password for SMTP authentication")
+	fs.BoolVar(&c.SMTPTLS, "smtp-tls", false, "Connect to the SMTP server using implicit TLS instead of STARTTLS")
+	fs.StringVar(&c.WebhookURL, "webhook-url", os.Getenv("WEBHOOK_URL"), "URL the webhook notification backend posts delete notifications to")
+	fs.StringVar(&c.WebhookTargetsFile, "webhook-targets-file", os.Getenv("WEBHOOK_TARGETS_FILE"), "Load additional webhook notification targets from given file path")
+	fs.StringVar(&c.WebhookSecret, "webhook-secret", os.Getenv("WEBHOOK_SECRET"), "Secret used to sign webhook payloads with HMAC-SHA256")
+	fs.StringVar(&c.WebhookSignatureHeader, "webhook-signature-header", getEnvOrDefault("WEBHOOK_SIGNATURE_HEADER", DefaultWebhookSignatureHeader), "Header carrying the webhook payload signature")
+	fs.StringVar(&c.PagerDutyRoutingKey, "pagerduty-routing-key", os.Getenv("PAGERDUTY_ROUTING_KEY"), "PagerDuty Events v2 routing key for delete notifications")
+	fs.StringVar(&c.ListenAddress, "listen-address", os.Getenv("LISTEN_ADDRESS"), "Address to serve the health and debug HTTP endpoints on, e.g. :8080 (disabled if empty)")
+	fs.BoolVar(&c.EnablePprof, "enable-pprof", false, "Serve the pprof profiling endpoints under /debug/pprof/ (requires --listen-address)")
+	fs.StringVar(&c.OTLPEndpoint, "otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP endpoint to export tracing spans to (tracing is disabled if empty)")
+
+	fs.StringVar(&c.ImpersonateUser, "as", "", "User to impersonate for all API requests")
+	fs.Var((*stringSliceFlag)(&c.ImpersonateGroups), "as-group", "Group to impersonate for all API requests (can be repeated)")
+	fs.StringVar(&c.ImpersonateUID, "as-uid", "", "UID to impersonate for all API requests")
+}
+
+// dryRunFlag is the --dry-run flag, which is either a boolean for a client-side
+// dry run or "client" or "server"
+type dryRunFlag struct {
+	config *Config
+}
+
+func (f *dryRunFlag) String() string {
+	if f.config == nil || !f.config.DryRun {
+		return "false"
+	}
+	if f.config.DryRunServer {
+		return DryRunServer
+	}
+	return "true"
+}
+
+func (f *dryRunFlag) Set(value string) error {
+	switch value {
+	case DryRunClient:
+		f.config.DryRun, f.config.DryRunServer = true, false
+	case DryRunServer:
+		f.config.DryRun, f.config.DryRunServer = true, true
+	default:
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be a boolean, %q or %q", DryRunClient, DryRunServer)
+		}
+		f.config.DryRun, f.config.DryRunServer = dryRun, false
+	}
+	return nil
+}
+
+func (f *dryRunFlag) IsBoolFlag() bool {
+	return true
+}
+
+// clusterResourcesFlag is the --include-cluster-resources flag, which is either
+// a boolean to include all cluster-scoped resources or a list of resource types
+type clusterResourcesFlag struct {
+	config *Config
+}
+
+func (f *clusterResourcesFlag) String() string {
+	if f.config == nil || !f.config.IncludeClusterResources {
+		return "false"
+	}
+	if len(f.config.ClusterResources) > 0 {
+		return strings.Join(f.config.ClusterResources, ",")
+	}
+	return "true"
+}
+
+func (f *clusterResourcesFlag) Set(value string) error {
+	if include, err := strconv.ParseBool(value); err == nil {
+		f.config.IncludeClusterResources, f.config.ClusterResources = include, nil
+		return nil
+	}
+	resources := splitList(value)
+	if len(resources) == 0 {
+		return fmt.Errorf("no resource types given")
+	}
+	f.config.IncludeClusterResources, f.config.ClusterResources = true, resources
+	return nil
+}
+
+// IsBoolFlag lets the flag be given without a value to include all
+// cluster-scoped resources, so a list of resource types has to be given as
+// --include-cluster-resources=list
+func (f *clusterResourcesFlag) IsBoolFlag() bool {
+	return true
+}
+
+// stringSliceFlag is a flag that can be repeated to collect several values
+type stringSliceFlag []string
+
+func (f *stringSliceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringSliceFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// splitList splits a comma-separated list flag, trimming whitespace around
+// the elements and dropping empty ones. An empty string yields an empty list,
+// so that e.g. --exclude-resources="" clears the defaults.
+func splitList(value string) []string {
+	list := []string{}
+	for _, element := range strings.Split(value, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			list = append(list, element)
+		}
+	}
+	return list
+}
+
+// webhookURL returns the configured webhook URL, falling back to the
+// WEBHOOK_URL environment variable for configs not built from flags
+func (c *Config) webhookURL() string {
+	if c != nil && c.WebhookURL != "" {
+		return c.WebhookURL
+	}
+	return os.Getenv("WEBHOOK_URL")
 }
 
 // ParseStringFlags parses the comma-separated string flags into string slices
 // This must be called after flag.Parse()
 func (c *Config) ParseStringFlags() {
-	c.IncludeResources = strings.Split(c.includeResourcesStr, ",")
-	c.ExcludeResources = strings.Split(c.excludeResourcesStr, ",")
-	c.IncludeNamespaces = strings.Split(c.includeNamespacesStr, ",")
-	c.ExcludeNamespaces = strings.Split(c.excludeNamespacesStr, ",")
+	c.IncludeResources = splitList(c.includeResourcesStr)
+	c.ExcludeResources = splitList(c.excludeResourcesStr)
+	c.IncludeNamespaces = splitList(c.includeNamespacesStr)
+	c.ExcludeNamespaces = splitList(c.excludeNamespacesStr)
+	c.IncludeGroups = splitList(c.includeGroupsStr)
+	if c.excludeGroupsStr != "" {
+		c.ExcludeGroups = splitList(c.excludeGroupsStr)
+	}
+	if c.apiPreferencesStr != "" {
+		c.APIPreferences = nil
+		for _, chain := range strings.Split(c.apiPreferencesStr, ",") {
+			c.APIPreferences = append(c.APIPreferences, strings.Split(chain, ">"))
+		}
+	}
+	c.PVCReferenceResources = splitList(c.pvcReferenceStr)
+	c.AllowedKinds = splitList(c.allowedKindsStr)
+	c.DeleteOrder = splitList(c.deleteOrderStr)
+	c.NotifyBackends = splitList(c.notifyBackendsStr)
+	if c.ttlBaseFieldsStr != "" {
+		c.TTLBaseFields = make(map[string]string)
+		for _, pair := range splitList(c.ttlBaseFieldsStr) {
+			resource, field, _ := strings.Cut(pair, "=")
+			c.TTLBaseFields[strings.TrimSpace(resource)] = strings.TrimSpace(field)
+		}
+	}
+	if c.protectedPriorityStr != "" {
+		c.ProtectedPriorityClasses = splitList(c.protectedPriorityStr)
+	}
+	if c.smtpToStr != "" {
+		c.SMTPTo = splitList(c.smtpToStr)
+	}
 }
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if c.Interval < 1 {
-		return fmt.Errorf("interval must be greater than 0")
+	if c.Interval < 0 {
+		return fmt.Errorf("interval must be greater than or equal to 0")
 	}
 
 	if c.DeleteNotification < 0 {
@@ -105,13 +399,179 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("wait-after-delete must be greater than or equal to 0")
 	}
 
+	if c.ImpersonateUser == "" && (len(c.ImpersonateGroups) > 0 || c.ImpersonateUID != "") {
+		return fmt.Errorf("as-group and as-uid require as")
+	}
+
+	for _, selector := range append(append([]string{}, c.ExcludeLabels...), c.ExcludeAnnotations...) {
+		for _, pair := range strings.Split(selector, ",") {
+			if key, _, _ := strings.Cut(pair, "="); key == "" {
+				return fmt.Errorf("invalid exclude selector %q: keys must not be empty", selector)
+			}
+		}
+	}
+
+	c.excludeExprs = nil
+	for _, expression := range c.ExcludeJMESPaths {
+		expr, err := jmespath.Compile(expression)
+		if err != nil {
+			return fmt.Errorf("invalid exclude-jmespath %q: %v", expression, err)
+		}
+		c.excludeExprs = append(c.excludeExprs, expr)
+	}
+
+	for _, key := range c.PVCReferenceResources {
+		if _, err := parseResourceKey(key); err != nil {
+			return fmt.Errorf("invalid pvc-reference-resources: %v", err)
+		}
+	}
+
+	if c.DeleteFailureThreshold < 0 {
+		return fmt.Errorf("delete-failure-threshold must be greater than or equal to 0")
+	}
+
+	if c.QuietRunThreshold < 0 {
+		return fmt.Errorf("quiet-run-threshold must be greater than or equal to 0")
+	}
+
+	if c.PauseConfigMap != "" {
+		if namespace, name, ok := strings.Cut(c.PauseConfigMap, "/"); !ok || namespace == "" || name == "" {
+			return fmt.Errorf("pause-configmap must be in the format namespace/name")
+		}
+	}
+
+	if c.PolicyConfigMap != "" {
+		if namespace, name, ok := strings.Cut(c.PolicyConfigMap, "/"); !ok || namespace == "" || name == "" {
+			return fmt.Errorf("policy-configmap must be in the format namespace/name")
+		}
+	}
+
+	for resource, field := range c.TTLBaseFields {
+		if resource == "" || field == "" {
+			return fmt.Errorf("ttl-base-fields must be comma-separated resource=field pairs")
+		}
+	}
+
+	if c.SoftDeleteGrace < 0 {
+		return fmt.Errorf("soft-delete-grace must be greater than or equal to 0")
+	}
+
+	if c.ExpiredGrace < 0 {
+		return fmt.Errorf("expired-grace must be greater than or equal to 0")
+	}
+
+	if c.RunTimeout < 0 {
+		return fmt.Errorf("run-timeout must be greater than or equal to 0")
+	}
+
 	if c.Parallelism < 0 {
 		return fmt.Errorf("parallelism must be greater than or equal to 0")
 	}
 
+	if c.MaxConcurrentDeletes < 0 {
+		return fmt.Errorf("max-concurrent-deletes must be greater than or equal to 0")
+	}
+
+	if c.MinResourceTypes < 0 {
+		return fmt.Errorf("min-resource-types must be greater than or equal to 0")
+	}
+
+	if c.MaxTTL != "" {
+		maxTTL, err := ParseTTL(c.MaxTTL)
+		if err != nil {
+			return fmt.Errorf("invalid max-ttl: %v", err)
+		}
+		if maxTTL < 0 {
+			return fmt.Errorf("max-ttl must be a finite duration")
+		}
+	}
+
+	if c.ProtectOlderThan != "" {
+		age, err := ParseTTL(c.ProtectOlderThan)
+		if err != nil {
+			return fmt.Errorf("invalid protect-older-than: %v", err)
+		}
+		if age < 0 {
+			return fmt.Errorf("protect-older-than must be a finite duration")
+		}
+	}
+
+	var createdBefore, createdAfter time.Time
+	if c.CreatedBefore != "" {
+		t, err := ParseExpiry(c.CreatedBefore)
+		if err != nil {
+			return fmt.Errorf("invalid created-before: %v", err)
+		}
+		createdBefore = t
+	}
+	if c.CreatedAfter != "" {
+		t, err := ParseExpiry(c.CreatedAfter)
+		if err != nil {
+			return fmt.Errorf("invalid created-after: %v", err)
+		}
+		createdAfter = t
+	}
+	if !createdBefore.IsZero() && !createdAfter.IsZero() && !createdAfter.Before(createdBefore) {
+		return fmt.Errorf("created-after must be before created-before")
+	}
+
+	if c.CustomResourcesOnly && c.BuiltinResourcesOnly {
+		return fmt.Errorf("custom-resources-only and builtin-resources-only are mutually exclusive")
+	}
+
+	if c.Confirm && !c.Once {
+		return fmt.Errorf("confirm requires once to be set")
+	}
+
+	if c.DryRunOnceThenApply && (c.DryRun || c.Once) {
+		return fmt.Errorf("dry-run-once-then-apply cannot be combined with dry-run or once")
+	}
+
+	if c.DryRunTable && !c.DryRun {
+		return fmt.Errorf("dry-run-table requires dry-run to be set")
+	}
+
+	if c.EnablePprof && c.ListenAddress == "" {
+		return fmt.Errorf("enable-pprof requires listen-address to be set")
+	}
+
+	for _, backend := range c.NotifyBackends {
+		switch backend {
+		case NotifyBackendWebhook:
+		case NotifyBackendSNS:
+			if c.SNSTopicARN == "" {
+				return fmt.Errorf("sns-topic-arn is required for the %s notification backend", NotifyBackendSNS)
+			}
+		case NotifyBackendSMTP:
+			if c.SMTPHost == "" || c.SMTPFrom == "" || len(c.SMTPTo) == 0 {
+				return fmt.Errorf("smtp-host, smtp-from and smtp-to are required for the %s notification backend", NotifyBackendSMTP)
+			}
+		case NotifyBackendPagerDuty:
+			if c.PagerDutyRoutingKey == "" {
+				return fmt.Errorf("pagerduty-routing-key is required for the %s notification backend", NotifyBackendPagerDuty)
+			}
+		default:
+			return fmt.Errorf("unknown notification backend %q", backend)
+		}
+	}
+
 	return nil
 }
 
+// NotificationsEnabled returns whether delete notifications are sent for any
+// resources, globally or by a rule
+func (c *Config) NotificationsEnabled() bool {
+	if c.DeleteNotification > 0 {
+		return true
+	}
+	for _, rule := range c.Rules {
+		if rule.IsEnabled() && rule.Notification != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // LoadRules loads rules from the rules file if specified
 func (c *Config) LoadRules() error {
 	if c.RulesFile == "" {
@@ -127,6 +587,21 @@ func (c *Config) LoadRules() error {
 	return nil
 }
 
+// LoadWebhookTargets loads webhook targets from the webhook targets file if specified
+func (c *Config) LoadWebhookTargets() error {
+	if c.WebhookTargetsFile == "" {
+		return nil
+	}
+
+	targets, err := LoadWebhookTargets(c.WebhookTargetsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook targets: %v", err)
+	}
+
+	c.WebhookTargets = targets
+	return nil
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
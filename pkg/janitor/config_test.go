@@ -3,6 +3,7 @@ package janitor
 import (
 	"context"
 	"flag"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
 )
 
 func TestConfigFlagParsing(t *testing.T) {
@@ -26,7 +28,7 @@ func TestConfigFlagParsing(t *testing.T) {
 			name:                      "default flags",
 			args:                      []string{},
 			expectedIncludeResources:  []string{"all"},
-			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints"},
+			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints", "resourcequotas", "limitranges"},
 			expectedIncludeNamespaces: []string{"all"},
 			expectedExcludeNamespaces: []string{"kube-system"},
 		},
@@ -34,7 +36,7 @@ func TestConfigFlagParsing(t *testing.T) {
 			name:                      "custom include resources",
 			args:                      []string{"-include-resources", "pods,services"},
 			expectedIncludeResources:  []string{"pods", "services"},
-			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints"},
+			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints", "resourcequotas", "limitranges"},
 			expectedIncludeNamespaces: []string{"all"},
 			expectedExcludeNamespaces: []string{"kube-system"},
 		},
@@ -42,7 +44,7 @@ func TestConfigFlagParsing(t *testing.T) {
 			name:                      "custom include namespaces",
 			args:                      []string{"-include-namespaces", "default,test"},
 			expectedIncludeResources:  []string{"all"},
-			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints"},
+			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints", "resourcequotas", "limitranges"},
 			expectedIncludeNamespaces: []string{"default", "test"},
 			expectedExcludeNamespaces: []string{"kube-system"},
 		},
@@ -50,7 +52,7 @@ func TestConfigFlagParsing(t *testing.T) {
 			name:                      "custom exclude namespaces",
 			args:                      []string{"-exclude-namespaces", "kube-system,kube-public"},
 			expectedIncludeResources:  []string{"all"},
-			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints"},
+			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints", "resourcequotas", "limitranges"},
 			expectedIncludeNamespaces: []string{"all"},
 			expectedExcludeNamespaces: []string{"kube-system", "kube-public"},
 		},
@@ -58,10 +60,34 @@ func TestConfigFlagParsing(t *testing.T) {
 			name:                      "single namespace include",
 			args:                      []string{"-include-namespaces", "test-namespace"},
 			expectedIncludeResources:  []string{"all"},
-			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints"},
+			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints", "resourcequotas", "limitranges"},
 			expectedIncludeNamespaces: []string{"test-namespace"},
 			expectedExcludeNamespaces: []string{"kube-system"},
 		},
+		{
+			name:                      "whitespace around commas",
+			args:                      []string{"-include-resources", " pods , services,", "-include-namespaces", "default, test", "-exclude-namespaces", "kube-system ,, kube-public "},
+			expectedIncludeResources:  []string{"pods", "services"},
+			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints", "resourcequotas", "limitranges"},
+			expectedIncludeNamespaces: []string{"default", "test"},
+			expectedExcludeNamespaces: []string{"kube-system", "kube-public"},
+		},
+		{
+			name:                      "cleared exclude lists",
+			args:                      []string{"-exclude-resources", "", "-exclude-namespaces", ""},
+			expectedIncludeResources:  []string{"all"},
+			expectedExcludeResources:  []string{},
+			expectedIncludeNamespaces: []string{"all"},
+			expectedExcludeNamespaces: []string{},
+		},
+		{
+			name:                      "cleared include lists",
+			args:                      []string{"-include-resources", "", "-include-namespaces", ""},
+			expectedIncludeResources:  []string{},
+			expectedExcludeResources:  []string{"events", "controllerrevisions", "endpoints", "resourcequotas", "limitranges"},
+			expectedIncludeNamespaces: []string{},
+			expectedExcludeNamespaces: []string{"kube-system"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -362,3 +388,254 @@ func TestNamespaceClusterResourcesBug(t *testing.T) {
 		t.Errorf("Namespace should definitely be processed with --include-cluster-resources flag, but matchesResourceFilter returned false")
 	}
 }
+
+func TestConfigValidateInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval int
+		wantErr  bool
+	}{
+		{name: "default interval", interval: defaultInterval},
+		{name: "back-to-back runs", interval: 0},
+		{name: "negative interval", interval: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Interval = tt.interval
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateMaxTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxTTL  string
+		wantErr bool
+	}{
+		{
+			name:    "no max TTL",
+			maxTTL:  "",
+			wantErr: false,
+		},
+		{
+			name:    "valid max TTL",
+			maxTTL:  "4w",
+			wantErr: false,
+		},
+		{
+			name:    "invalid max TTL",
+			maxTTL:  "4x",
+			wantErr: true,
+		},
+		{
+			name:    "forever is not a valid max TTL",
+			maxTTL:  "forever",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.MaxTTL = tt.maxTTL
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigAPIPreferencesFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-api-preferences", "v1/events>events.k8s.io/v1/events,apps/v1/deployments>extensions/v1beta1/deployments"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+
+	want := [][]string{
+		{"v1/events", "events.k8s.io/v1/events"},
+		{"apps/v1/deployments", "extensions/v1beta1/deployments"},
+	}
+	if !reflect.DeepEqual(config.APIPreferences, want) {
+		t.Errorf("Expected API preferences %v, got %v", want, config.APIPreferences)
+	}
+}
+
+func TestConfigPVCReferenceResourcesFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-pvc-reference-resources", "argoproj.io/v1alpha1/rollouts, apps.kruise.io/v1alpha1/clonesets"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+
+	want := []string{"argoproj.io/v1alpha1/rollouts", "apps.kruise.io/v1alpha1/clonesets"}
+	if !reflect.DeepEqual(config.PVCReferenceResources, want) {
+		t.Errorf("Expected PVC reference resources %v, got %v", want, config.PVCReferenceResources)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	config.PVCReferenceResources = []string{"rollouts"}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for a resource without group and version")
+	}
+}
+
+func TestConfigGroupsFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-include-groups", "batch, example.com,", "-exclude-groups", " metrics.k8s.io"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+
+	if want := []string{"batch", "example.com"}; !reflect.DeepEqual(config.IncludeGroups, want) {
+		t.Errorf("Expected include groups %v, got %v", want, config.IncludeGroups)
+	}
+	if want := []string{"metrics.k8s.io"}; !reflect.DeepEqual(config.ExcludeGroups, want) {
+		t.Errorf("Expected exclude groups %v, got %v", want, config.ExcludeGroups)
+	}
+}
+
+func TestConfigNotifyBackendFlag(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "webhook, sns", want: []string{NotifyBackendWebhook, NotifyBackendSNS}},
+		{value: "", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		config := NewConfig()
+		config.AddFlags(fs)
+		if err := fs.Parse([]string{"-notify-backend", tt.value}); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		config.ParseStringFlags()
+
+		if !reflect.DeepEqual(config.NotifyBackends, tt.want) {
+			t.Errorf("-notify-backend %q: expected backends %v, got %v", tt.value, tt.want, config.NotifyBackends)
+		}
+	}
+}
+
+func TestConfigProtectedPriorityClassesFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-protected-priority-classes", "system-node-critical, system-cluster-critical,"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+
+	want := []string{"system-node-critical", "system-cluster-critical"}
+	if !reflect.DeepEqual(config.ProtectedPriorityClasses, want) {
+		t.Errorf("Expected protected priority classes %v, got %v", want, config.ProtectedPriorityClasses)
+	}
+}
+
+func TestConfigSMTPToFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-smtp-to", "ops@example.com, team@example.com"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+
+	want := []string{"ops@example.com", "team@example.com"}
+	if !reflect.DeepEqual(config.SMTPTo, want) {
+		t.Errorf("Expected SMTP recipients %v, got %v", want, config.SMTPTo)
+	}
+}
+
+func TestConfigImpersonationFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-as", "janitor", "-as-group", "auditors", "-as-group", "cleaners", "-as-uid", "1234"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	restConfig := &rest.Config{}
+	applyImpersonation(restConfig, config)
+
+	want := rest.ImpersonationConfig{
+		UserName: "janitor",
+		Groups:   []string{"auditors", "cleaners"},
+		UID:      "1234",
+	}
+	if !reflect.DeepEqual(restConfig.Impersonate, want) {
+		t.Errorf("Expected impersonation config %+v, got %+v", want, restConfig.Impersonate)
+	}
+
+	config.ImpersonateUser = ""
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for as-group without as")
+	}
+}
+
+func TestDefaultExcludesQuotas(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantDeleted bool
+	}{
+		{name: "excluded by default"},
+		{name: "excluded when including them", args: []string{"-include-resources", "resourcequotas,limitranges"}},
+		{name: "included when no longer excluded", args: []string{"-exclude-resources", "events"}, wantDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			config := NewConfig()
+			config.AddFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+			config.ParseStringFlags()
+			config.DryRun = true
+			j := &Janitor{client: fake.NewSimpleClientset(), config: config, cache: make(map[string]interface{})}
+
+			for _, kind := range []string{"ResourceQuota", "LimitRange"} {
+				obj := &unstructured.Unstructured{}
+				obj.SetAPIVersion("v1")
+				obj.SetKind(kind)
+				obj.SetName("limits")
+				obj.SetNamespace("default")
+				obj.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-2 * time.Hour)))
+				obj.SetAnnotations(map[string]string{TTLAnnotation: "1h"})
+
+				counter := make(map[string]int)
+				if err := j.handleResource(context.Background(), obj, counter, make(map[string]bool)); err != nil {
+					t.Fatalf("handleResource() error = %v", err)
+				}
+				result := newCleanupResult(counter)
+				if tt.wantDeleted {
+					if len(result.Deleted) != 1 {
+						t.Errorf("Expected the %s to be deleted, got %+v", kind, result)
+					}
+				} else if result.Skipped[SkipReasonExcludedResource] != 1 || len(result.Deleted) != 0 {
+					t.Errorf("Expected the %s to be excluded, got %+v", kind, result)
+				}
+			}
+		})
+	}
+}
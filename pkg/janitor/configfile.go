@@ -0,0 +1,92 @@
+package janitor
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfigFile sets the options of the config file, a YAML or JSON mapping
+// of flag names to values, e.g. "include-resources: [pods, jobs]". Flags
+// given on the command line take precedence over the file, which in turn
+// takes precedence over environment variables and defaults. It must be
+// called after parsing the flags and before ParseStringFlags.
+func (c *Config) LoadConfigFile(fs *flag.FlagSet) error {
+	if c.ConfigFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.ConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var options map[string]interface{}
+	if err := yaml.Unmarshal(data, &options); err != nil {
+		return fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	setOnCommandLine := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf("unknown option %q in config file", name)
+		}
+		if setOnCommandLine[name] {
+			continue
+		}
+		if err := setConfigFileOption(f, options[name]); err != nil {
+			return fmt.Errorf("invalid option %q in config file: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// setConfigFileOption sets a flag to a value from the config file. A list is
+// passed to repeatable flags one value at a time and joined with commas for
+// all other flags.
+func setConfigFileOption(f *flag.Flag, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		return fmt.Errorf("expected a value or a list, got a mapping")
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, ok := item.([]interface{}); ok {
+				return fmt.Errorf("expected a list of values, got a nested list")
+			}
+			if _, ok := item.(map[string]interface{}); ok {
+				return fmt.Errorf("expected a list of values, got a mapping")
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		if _, ok := f.Value.(*stringSliceFlag); ok {
+			for _, item := range values {
+				if err := f.Value.Set(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return f.Value.Set(strings.Join(values, ","))
+	default:
+		return f.Value.Set(fmt.Sprint(v))
+	}
+}
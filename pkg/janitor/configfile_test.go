@@ -0,0 +1,114 @@
+package janitor
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		args    []string
+		check   func(t *testing.T, c *Config)
+		wantErr bool
+	}{
+		{
+			name: "yaml options are set",
+			content: `
+dry-run: true
+interval: 60
+run-timeout: 10m
+include-resources: [pods, jobs]
+exclude-namespaces: kube-system,monitoring
+exclude-label:
+  - app=db
+  - tier=critical
+`,
+			check: func(t *testing.T, c *Config) {
+				if !c.DryRun || c.Interval != 60 || c.RunTimeout != 10*time.Minute {
+					t.Errorf("Expected dry run, interval 60 and run timeout 10m, got %v, %d and %v", c.DryRun, c.Interval, c.RunTimeout)
+				}
+				if want := []string{"pods", "jobs"}; !reflect.DeepEqual(c.IncludeResources, want) {
+					t.Errorf("Expected include resources %v, got %v", want, c.IncludeResources)
+				}
+				if want := []string{"kube-system", "monitoring"}; !reflect.DeepEqual(c.ExcludeNamespaces, want) {
+					t.Errorf("Expected exclude namespaces %v, got %v", want, c.ExcludeNamespaces)
+				}
+				if want := []string{"app=db", "tier=critical"}; !reflect.DeepEqual(c.ExcludeLabels, want) {
+					t.Errorf("Expected exclude labels %v, got %v", want, c.ExcludeLabels)
+				}
+			},
+		},
+		{
+			name:    "json options are set",
+			content: `{"dry-run": "server", "include-namespaces": ["team-a"]}`,
+			check: func(t *testing.T, c *Config) {
+				if !c.DryRun || !c.DryRunServer {
+					t.Errorf("Expected a server-side dry run, got %v and %v", c.DryRun, c.DryRunServer)
+				}
+				if want := []string{"team-a"}; !reflect.DeepEqual(c.IncludeNamespaces, want) {
+					t.Errorf("Expected include namespaces %v, got %v", want, c.IncludeNamespaces)
+				}
+			},
+		},
+		{
+			name:    "command line flags take precedence",
+			content: "interval: 60\ninclude-resources: [pods]\n",
+			args:    []string{"-interval", "10"},
+			check: func(t *testing.T, c *Config) {
+				if c.Interval != 10 {
+					t.Errorf("Expected interval 10 from the command line, got %d", c.Interval)
+				}
+				if want := []string{"pods"}; !reflect.DeepEqual(c.IncludeResources, want) {
+					t.Errorf("Expected include resources %v, got %v", want, c.IncludeResources)
+				}
+			},
+		},
+		{
+			name:    "unknown option",
+			content: "intervall: 60\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid value",
+			content: "interval: often\n",
+			wantErr: true,
+		},
+		{
+			name:    "config file cannot load another config file",
+			content: "config: other.yaml\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			config := NewConfig()
+			config.AddFlags(fs)
+			if err := fs.Parse(append([]string{"-config", path}, tt.args...)); err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+
+			err := config.LoadConfigFile(fs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			config.ParseStringFlags()
+			tt.check(t, config)
+		})
+	}
+}
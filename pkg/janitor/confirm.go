@@ -0,0 +1,91 @@
+package janitor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PlanDeletions runs a dry run and returns the resources it would delete,
+// so that they can be confirmed before the actual run. The planning run is
+// not recorded as a run, and the schedule is reset afterwards, so that the
+// following run processes the same namespaces.
+func (j *Janitor) PlanDeletions(ctx context.Context) ([]Decision, error) {
+	j.planning.Store(true)
+	defer func() {
+		j.planning.Store(false)
+
+		j.scheduleMutex.Lock()
+		j.lastProcessed = nil
+		j.dueNamespaces = nil
+		j.scheduleMutex.Unlock()
+	}()
+
+	if _, err := j.CleanUp(ctx); err != nil {
+		return nil, fmt.Errorf("failed to plan deletions: %v", err)
+	}
+
+	j.decisionsMutex.Lock()
+	defer j.decisionsMutex.Unlock()
+	var deletions []Decision
+	for _, d := range j.decisions {
+		if d.Decision == DecisionDelete {
+			deletions = append(deletions, d)
+		}
+	}
+	return deletions, nil
+}
+
+// ConfirmDeletions limits the deletions of the following runs to the given
+// planned deletions, so that resources that expire after the plan was
+// confirmed are not deleted without confirmation
+func (j *Janitor) ConfirmDeletions(deletions []Decision) {
+	confirmed := make(map[string]bool, len(deletions))
+	for _, d := range deletions {
+		confirmed[decisionKey(d.Group, d.Kind, d.Namespace, d.Name)] = true
+	}
+
+	j.confirmedMutex.Lock()
+	defer j.confirmedMutex.Unlock()
+	j.confirmed = confirmed
+}
+
+// deletionConfirmed checks if a resource may be deleted, which is always the
+// case unless the deletions were limited with ConfirmDeletions
+func (j *Janitor) deletionConfirmed(obj metav1.Object) bool {
+	j.confirmedMutex.Lock()
+	defer j.confirmedMutex.Unlock()
+	if j.confirmed == nil {
+		return true
+	}
+	gvk := objectGVK(obj)
+	return j.confirmed[decisionKey(gvk.Group, gvk.Kind, obj.GetNamespace(), obj.GetName())]
+}
+
+// decisionKey identifies a resource in a plan. The API group is part of the
+// key, so that confirming a resource doesn't confirm a resource of the same
+// kind and name in another group.
+func decisionKey(group, kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", schema.GroupKind{Group: group, Kind: kind}, namespace, name)
+}
+
+// PlanToken returns a short token identifying a set of planned deletions, to
+// confirm exactly these deletions in a later non-interactive run
+func PlanToken(deletions []Decision) string {
+	keys := make([]string, 0, len(deletions))
+	for _, d := range deletions {
+		keys = append(keys, decisionKey(d.Group, d.Kind, d.Namespace, d.Name))
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintln(hash, key)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
@@ -0,0 +1,129 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPlanDeletions(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	// The fake clientset does not generate event names, so accept all events
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsGVR: "PodList"},
+		newUnstructuredPod("expired", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"}),
+		newUnstructuredPod("valid", "default", time.Now(), map[string]string{TTLAnnotation: "1h"}),
+	)
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			Interval:          30,
+			Once:              true,
+			Confirm:           true,
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	deletions, err := j.PlanDeletions(context.Background())
+	if err != nil {
+		t.Fatalf("PlanDeletions() error = %v", err)
+	}
+	if len(deletions) != 1 || deletions[0].Name != "expired" {
+		t.Fatalf("Expected the expired pod to be planned for deletion, got %+v", deletions)
+	}
+	if j.config.DryRun {
+		t.Error("Expected planning not to change the configuration")
+	}
+	if j.Status() != nil {
+		t.Errorf("Expected planning not to be recorded as a run, got %+v", j.Status())
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "expired", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected planning not to delete anything, got %v", err)
+	}
+
+	// A resource expiring after the plan was confirmed is not deleted
+	late := newUnstructuredPod("late", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Create(context.Background(), late, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create pod: %v", err)
+	}
+	j.ConfirmDeletions(deletions)
+
+	// The confirmed run processes the same namespaces right away
+	result, err := j.CleanUp(context.Background())
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "expired", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the expired pod to be deleted, got %v", err)
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "late", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the unconfirmed pod to be kept, got %v", err)
+	}
+	if result.Skipped[SkipReasonNotConfirmed] != 1 {
+		t.Errorf("Expected the unconfirmed pod to be skipped as not confirmed, got %+v", result.Skipped)
+	}
+
+	// An empty confirmed plan deletes nothing
+	j.ConfirmDeletions(nil)
+	if _, err := j.CleanUp(context.Background()); err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "late", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the unconfirmed pod to be kept, got %v", err)
+	}
+}
+
+func TestConfirmDeletionsByGroup(t *testing.T) {
+	deployment := func(apiVersion string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind("Deployment")
+		obj.SetNamespace("default")
+		obj.SetName("app")
+		return obj
+	}
+
+	j := &Janitor{}
+	deletions := []Decision{{Group: "apps", Kind: "Deployment", Namespace: "default", Name: "app", Decision: DecisionDelete}}
+	j.ConfirmDeletions(deletions)
+
+	if !j.deletionConfirmed(deployment("apps/v1")) {
+		t.Error("Expected the confirmed deployment to be deleted")
+	}
+	// A kind of the same name in another group is not confirmed
+	if j.deletionConfirmed(deployment("example.com/v1")) {
+		t.Error("Expected the deployment of another group not to be confirmed")
+	}
+
+	other := []Decision{{Group: "example.com", Kind: "Deployment", Namespace: "default", Name: "app", Decision: DecisionDelete}}
+	if PlanToken(deletions) == PlanToken(other) {
+		t.Error("Expected plans of resources in different groups to have different tokens")
+	}
+}
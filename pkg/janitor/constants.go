@@ -5,13 +5,26 @@ const (
 	TTLAnnotation      = "janitor/ttl"
 	ExpiryAnnotation   = "janitor/expires"
 	NotifiedAnnotation = "janitor/notified"
+	IntervalAnnotation = "janitor/interval"
+	ReasonAnnotation   = "janitor/reason"
+
+	// SoftDeleteAnnotation marks when an expired resource was soft deleted
+	SoftDeleteAnnotation = "janitor/deleted-at"
+
+	// ExpiredSinceAnnotation marks when a resource was first seen expired
+	ExpiredSinceAnnotation = "janitor/expired-since"
+
+	// ClusterAnnotation carries the context name on the events of the janitor
+	ClusterAnnotation = "janitor/cluster"
 
 	// Special TTL value
 	TTLUnlimited = "forever"
 
-	// Default values
+	// Default values. ResourceQuotas and LimitRanges are excluded by default,
+	// since deleting them silently lifts the limits of a namespace and allows
+	// it to overcommit.
 	DefaultInterval          = 30
-	DefaultExcludeResources  = "events,controllerrevisions"
+	DefaultExcludeResources  = "events,controllerrevisions,endpoints,resourcequotas,limitranges"
 	DefaultExcludeNamespaces = "kube-system"
 	DefaultParallelism       = 0 // 0 means use runtime.NumCPU()
 )
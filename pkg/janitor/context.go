@@ -2,31 +2,36 @@ package janitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
 
-// Force the kubernetes import to be used
-var _ kubernetes.Interface
-
 // ResourceContextHook is a function that can extend the context with custom information
 type ResourceContextHook func(resource interface{}, cache map[string]interface{}) map[string]interface{}
 
+// ClientAwareResourceContextHook is a resource context hook that also gets the
+// clients of the janitor, e.g. to look up objects related to the resource
+type ClientAwareResourceContextHook func(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, resource interface{}, cache map[string]interface{}) map[string]interface{}
+
 // getResourceContext returns additional context information for a resource
 func (j *Janitor) getResourceContext(ctx context.Context, resource metav1.Object) (map[string]interface{}, error) {
 	contextData := make(map[string]interface{})
 
-	// Fix the GetObjectKind issue with type assertion
-	kind := "Unknown"
-	if u, ok := resource.(*unstructured.Unstructured); ok {
-		kind = u.GetKind()
-	}
+	kind := objectGVK(resource).Kind
 
 	// Handle PVC specific context
 	if strings.ToLower(kind) == "persistentvolumeclaim" {
@@ -38,9 +43,86 @@ func (j *Janitor) getResourceContext(ctx context.Context, resource metav1.Object
 		contextData["pvc_is_not_referenced"] = pvcContext.PVCIsNotReferenced
 	}
 
-	// Apply resource context hook if configured
+	// Handle Service specific context
+	if strings.ToLower(kind) == "service" {
+		servicesWithEndpoints, err := j.servicesWithEndpoints(ctx, resource.GetNamespace())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Service context: %v", err)
+		}
+		contextData["service_has_no_endpoints"] = !servicesWithEndpoints[resource.GetName()]
+	}
+
+	// Listing the contents of a namespace is expensive, so it is only done
+	// if a rule asks for it
+	if kind == "Namespace" && j.rulesUseContext("namespace_is_empty") {
+		empty, err := j.namespaceIsEmpty(ctx, resource.GetName())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Namespace context: %v", err)
+		}
+		contextData["namespace_is_empty"] = empty
+	}
+
+	// Handle workload specific context
+	switch strings.ToLower(kind) {
+	case "deployment", "statefulset", "replicaset":
+		for key, value := range getWorkloadContext(resource.(*unstructured.Unstructured)) {
+			contextData[key] = value
+		}
+	case "job":
+		for key, value := range getJobContext(resource.(*unstructured.Unstructured)) {
+			contextData[key] = value
+		}
+	case "persistentvolume":
+		for key, value := range getPVContext(resource.(*unstructured.Unstructured)) {
+			contextData[key] = value
+		}
+	}
+
+	// Expose the kinds of the owners, and whether any of them still exists
+	if owners := resource.GetOwnerReferences(); len(owners) > 0 {
+		ownerKinds := make([]interface{}, 0, len(owners))
+		for _, owner := range owners {
+			ownerKinds = append(ownerKinds, owner.Kind)
+		}
+		contextData["owner_kinds"] = ownerKinds
+
+		if j.config.ResolveOwners {
+			ownerExists := false
+			for _, owner := range owners {
+				exists, err := j.ownerExists(ctx, resource, owner)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get owner context: %v", err)
+				}
+				if exists {
+					ownerExists = true
+					break
+				}
+			}
+			contextData["owner_exists"] = ownerExists
+		}
+	}
+
+	// Expose the age so that rules can match on it without date arithmetic
+	if created := resource.GetCreationTimestamp(); !created.IsZero() {
+		age := time.Since(created.Time).Truncate(time.Second)
+		contextData["age_seconds"] = age.Seconds()
+		contextData["age"] = FormatDuration(age)
+	}
+
+	// Apply resource context hook if configured. A hook is not called
+	// concurrently, so that it can use its cache without locking.
 	if j.config.ResourceContextHook != nil {
-		hookData := j.config.ResourceContextHook(resource, j.cache)
+		hookData := j.hookCache.call(func(cache map[string]interface{}) map[string]interface{} {
+			return j.config.ResourceContextHook(resource, cache)
+		})
+		for k, v := range hookData {
+			contextData[k] = v
+		}
+	}
+	if j.config.ClientAwareResourceContextHook != nil {
+		hookData := j.clientHookCache.call(func(cache map[string]interface{}) map[string]interface{} {
+			return j.config.ClientAwareResourceContextHook(ctx, j.client, j.dynamicClient, resource, cache)
+		})
 		for k, v := range hookData {
 			contextData[k] = v
 		}
@@ -49,13 +131,321 @@ func (j *Janitor) getResourceContext(ctx context.Context, resource metav1.Object
 	return contextData, nil
 }
 
+// hookCache is the cache of a resource context hook for the current run. The
+// calls of the hook are serialized by its own lock, so that a hook that is
+// slow, e.g. because it queries an API, doesn't hold up the other hooks or
+// the janitor's own caches.
+type hookCache struct {
+	mutex sync.Mutex
+	cache map[string]interface{}
+}
+
+// call calls a hook with the cache, one call at a time
+func (h *hookCache) call(hook func(cache map[string]interface{}) map[string]interface{}) map[string]interface{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.cache == nil {
+		h.cache = make(map[string]interface{})
+	}
+	return hook(h.cache)
+}
+
+// reset clears the cache at the start of a run
+func (h *hookCache) reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.cache = nil
+}
+
+// getWorkloadContext returns the replica counts from the status of a
+// Deployment, StatefulSet or ReplicaSet. The API server omits counts of zero,
+// so missing fields are reported as 0.
+func getWorkloadContext(workload *unstructured.Unstructured) map[string]interface{} {
+	contextData := make(map[string]interface{})
+	for key, field := range map[string]string{
+		"replicas":           "replicas",
+		"ready_replicas":     "readyReplicas",
+		"available_replicas": "availableReplicas",
+	} {
+		value, _, _ := unstructured.NestedInt64(workload.Object, "status", field)
+		// JMESPath compares numbers as float64
+		contextData[key] = float64(value)
+	}
+	return contextData
+}
+
+// getJobContext returns whether a Job completed or failed, read from its
+// status conditions, and its completion time, which is empty until the Job
+// completed
+func getJobContext(job *unstructured.Unstructured) map[string]interface{} {
+	contextData := map[string]interface{}{
+		"job_completed": false,
+		"job_failed":    false,
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(job.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["status"] != "True" {
+			continue
+		}
+		switch condition["type"] {
+		case "Complete":
+			contextData["job_completed"] = true
+		case "Failed":
+			contextData["job_failed"] = true
+		}
+	}
+
+	completionTime, _, _ := unstructured.NestedString(job.Object, "status", "completionTime")
+	contextData["job_completion_time"] = completionTime
+	return contextData
+}
+
+// getPVContext returns whether a PersistentVolume was released by its claim
+// and its reclaim policy, so that rules can target released volumes that are
+// retained and would otherwise linger forever
+func getPVContext(pv *unstructured.Unstructured) map[string]interface{} {
+	phase, _, _ := unstructured.NestedString(pv.Object, "status", "phase")
+	reclaimPolicy, _, _ := unstructured.NestedString(pv.Object, "spec", "persistentVolumeReclaimPolicy")
+	return map[string]interface{}{
+		"pv_is_released":    phase == "Released",
+		"pv_reclaim_policy": reclaimPolicy,
+	}
+}
+
+// servicesWithEndpoints returns the names of the Services in a namespace that
+// have at least one endpoint, cached for the duration of a cleanup run. The
+// EndpointSlices are used, falling back to Endpoints if they can't be listed
+// or the apiserver doesn't serve them.
+func (j *Janitor) servicesWithEndpoints(ctx context.Context, namespace string) (map[string]bool, error) {
+	j.endpointsMutex.Lock()
+	defer j.endpointsMutex.Unlock()
+
+	if services, ok := j.endpointsCache[namespace]; ok {
+		return services, nil
+	}
+
+	services := make(map[string]bool)
+	useEndpoints := j.noEndpointSlices
+	if !useEndpoints {
+		slices, err := j.client.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			j.debugLog("Failed to list EndpointSlices in namespace %s, falling back to Endpoints: %v", namespace, err)
+			useEndpoints = true
+		} else {
+			for _, slice := range slices.Items {
+				if service := slice.Labels[discoveryv1.LabelServiceName]; service != "" && len(slice.Endpoints) > 0 {
+					services[service] = true
+				}
+			}
+		}
+	}
+
+	if useEndpoints {
+		endpoints, err := j.client.CoreV1().Endpoints(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list endpoints: %v", err)
+		}
+		for _, ep := range endpoints.Items {
+			for _, subset := range ep.Subsets {
+				if len(subset.Addresses) > 0 || len(subset.NotReadyAddresses) > 0 {
+					services[ep.Name] = true
+					break
+				}
+			}
+		}
+	}
+
+	if j.endpointsCache == nil {
+		j.endpointsCache = make(map[string]map[string]bool)
+	}
+	j.endpointsCache[namespace] = services
+	return services, nil
+}
+
+// rulesUseContext checks whether an enabled rule refers to a context key
+func (j *Janitor) rulesUseContext(key string) bool {
+	for _, rule := range j.config.Rules {
+		if rule.IsEnabled() && strings.Contains(rule.JMESPath, "_context."+key) {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceIsEmpty checks whether a namespace contains no resources besides
+// Events and the objects Kubernetes creates in every namespace (see
+// isDefaultNamespaceObject), cached for the duration of a cleanup run. All
+// resource types that can be listed count, regardless of the resource filters
+// and exclusions, which would otherwise let a namespace be deleted with the
+// resources that are kept. A namespace with resources of a type that can't be
+// listed, e.g. because of missing permissions, counts as not empty.
+func (j *Janitor) namespaceIsEmpty(ctx context.Context, namespace string) (bool, error) {
+	j.emptyNamespaceMutex.Lock()
+	empty, cached := j.emptyNamespaces[namespace]
+	j.emptyNamespaceMutex.Unlock()
+	if cached {
+		return empty, nil
+	}
+
+	resourceTypes, err := j.listableResourceTypes()
+	if err != nil {
+		return false, fmt.Errorf("failed to get resource types: %v", err)
+	}
+	empty = j.listNamespaceIsEmpty(ctx, namespace, resourceTypes)
+
+	j.emptyNamespaceMutex.Lock()
+	defer j.emptyNamespaceMutex.Unlock()
+	if j.emptyNamespaces == nil {
+		j.emptyNamespaces = make(map[string]bool)
+	}
+	j.emptyNamespaces[namespace] = empty
+	return empty, nil
+}
+
+// listableResourceTypes returns the resource types that can be listed from
+// the discovery of the current run, discovering them if needed
+func (j *Janitor) listableResourceTypes() ([]ResourceType, error) {
+	j.pluralsMutex.Lock()
+	resourceTypes := j.listableTypes
+	j.pluralsMutex.Unlock()
+	if resourceTypes != nil {
+		return resourceTypes, nil
+	}
+
+	if _, err := j.getResourceTypes(); err != nil {
+		return nil, err
+	}
+	j.pluralsMutex.Lock()
+	defer j.pluralsMutex.Unlock()
+	return j.listableTypes, nil
+}
+
+// listNamespaceIsEmpty lists the resources of a namespace until it finds one
+// that is not an Event or a default object
+func (j *Janitor) listNamespaceIsEmpty(ctx context.Context, namespace string, resourceTypes []ResourceType) bool {
+	for _, resourceType := range resourceTypes {
+		if !resourceType.Namespaced || resourceType.Kind == "Event" {
+			continue
+		}
+
+		resources, err := j.listNamespacedResources(ctx, resourceType, namespace)
+		if errors.Is(err, errTypeForbidden) {
+			j.debugLog("Assuming namespace %s is not empty, %s can't be listed", namespace, resourceType.Kind)
+			return false
+		}
+		if err != nil {
+			log.Printf("Warning: assuming namespace %s is not empty: %v", namespace, err)
+			return false
+		}
+		for _, obj := range resources {
+			if !isDefaultNamespaceObject(obj.(*unstructured.Unstructured)) {
+				j.debugLog("Namespace %s is not empty, it contains %s %s", namespace, resourceType.Kind, obj.GetName())
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// isDefaultNamespaceObject checks whether an object is created by Kubernetes
+// in every namespace: the default ServiceAccount, its token Secret on older
+// clusters, and the ConfigMap with the cluster's root CA
+func isDefaultNamespaceObject(obj *unstructured.Unstructured) bool {
+	if obj.GroupVersionKind().Group != "" {
+		return false
+	}
+
+	switch obj.GetKind() {
+	case "ServiceAccount":
+		return obj.GetName() == "default"
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType == string(corev1.SecretTypeServiceAccountToken) &&
+			obj.GetAnnotations()[corev1.ServiceAccountNameKey] == "default"
+	}
+	return false
+}
+
+// ownerExists checks whether the owner of a resource still exists, cached for
+// the duration of a cleanup run. An object with the same name but another UID
+// is a different owner, so the original one counts as missing. Owners are
+// looked up in the namespace of the resource unless discovery reports their
+// kind as cluster-scoped. Owners whose kind was not discovered count as
+// existing, since a guessed resource name would report them as missing.
+func (j *Janitor) ownerExists(ctx context.Context, resource metav1.Object, owner metav1.OwnerReference) (bool, error) {
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return false, fmt.Errorf("invalid owner API version %q: %v", owner.APIVersion, err)
+	}
+	gvr, clusterScoped, ok := j.discoveredGVR(gv.WithKind(owner.Kind))
+	if !ok {
+		j.debugLog("Owner kind %s of %s/%s was not discovered, assuming owner %s exists",
+			owner.Kind, resource.GetNamespace(), resource.GetName(), owner.Name)
+		return true, nil
+	}
+	namespace := resource.GetNamespace()
+	if clusterScoped {
+		namespace = ""
+	}
+
+	key := fmt.Sprintf("%s/%s/%s/%s", gvr.String(), namespace, owner.Name, owner.UID)
+	j.ownerMutex.Lock()
+	exists, cached := j.ownerCache[key]
+	j.ownerMutex.Unlock()
+	if cached {
+		return exists, nil
+	}
+
+	var obj *unstructured.Unstructured
+	if namespace != "" {
+		obj, err = j.dynamicClient.Resource(gvr).Namespace(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	} else {
+		obj, err = j.dynamicClient.Resource(gvr).Get(ctx, owner.Name, metav1.GetOptions{})
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get owner %s %s: %v", owner.Kind, owner.Name, err)
+	}
+	exists = err == nil && (owner.UID == "" || obj.GetUID() == owner.UID)
+
+	j.ownerMutex.Lock()
+	defer j.ownerMutex.Unlock()
+	if j.ownerCache == nil {
+		j.ownerCache = make(map[string]bool)
+	}
+	j.ownerCache[key] = exists
+	return exists, nil
+}
+
+// discoveredGVR returns the discovered resource of a kind and whether it is
+// cluster-scoped. A kind that was discovered in another version of its group,
+// e.g. the preferred one, resolves to that version, which serves the same
+// objects. The last return value is false if the kind was not discovered.
+func (j *Janitor) discoveredGVR(gvk schema.GroupVersionKind) (schema.GroupVersionResource, bool, bool) {
+	j.pluralsMutex.Lock()
+	defer j.pluralsMutex.Unlock()
+
+	if plural, ok := j.plurals[gvk]; ok {
+		return gvk.GroupVersion().WithResource(plural), j.clusterScopedKinds[gvk], true
+	}
+	for discovered, plural := range j.plurals {
+		if discovered.GroupKind() == gvk.GroupKind() {
+			return discovered.GroupVersion().WithResource(plural), j.clusterScopedKinds[discovered], true
+		}
+	}
+	return schema.GroupVersionResource{}, false, false
+}
+
 // getPVCContext checks if a PVC is mounted by pods or referenced by other resources
 func (j *Janitor) getPVCContext(ctx context.Context, pvc metav1.Object) (*ResourceContext, error) {
 	pvcName := pvc.GetName()
 	namespace := pvc.GetNamespace()
 
 	isMounted := false
-	isReferenced := false
 
 	// Check if PVC is mounted by any pods
 	pods, err := j.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
@@ -76,10 +466,83 @@ func (j *Janitor) getPVCContext(ctx context.Context, pvc metav1.Object) (*Resour
 		}
 	}
 
-	// Check if PVC is referenced by StatefulSets
+	// Check if PVC is referenced by workloads
+	isReferenced, err := j.isPVCReferenced(ctx, namespace, pvcName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResourceContext{
+		PVCIsNotMounted:    !isMounted,
+		PVCIsNotReferenced: !isReferenced,
+		Cache:              j.cache,
+	}, nil
+}
+
+// pvcReferenceCheck checks whether the workloads of a type reference a PVC
+type pvcReferenceCheck struct {
+	kind  string
+	check func(ctx context.Context, namespace, pvcName string) (bool, error)
+	// A failed required check fails the PVC context, other failures are
+	// only logged
+	required bool
+}
+
+// isPVCReferenced checks whether any workload references a PVC. The workload
+// types are checked concurrently and the remaining checks are cancelled as
+// soon as a reference is found.
+func (j *Janitor) isPVCReferenced(ctx context.Context, namespace, pvcName string) (bool, error) {
+	checks := []pvcReferenceCheck{
+		{kind: "statefulsets", check: j.isPVCReferencedByStatefulSets, required: true},
+		{kind: "deployments", check: j.isPVCReferencedByDeployments},
+		{kind: "jobs", check: j.isPVCReferencedByJobs},
+		{kind: "cronjobs", check: j.isPVCReferencedByCronJobs},
+	}
+	for _, key := range j.config.PVCReferenceResources {
+		gvr, err := parseResourceKey(key)
+		if err != nil {
+			return false, err
+		}
+		checks = append(checks, pvcReferenceCheck{kind: key, check: j.isPVCReferencedByCustomResources(gvr)})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type checkResult struct {
+		check      pvcReferenceCheck
+		referenced bool
+		err        error
+	}
+	// Buffered so that the checks still running after a reference was
+	// found don't block
+	results := make(chan checkResult, len(checks))
+	for _, c := range checks {
+		go func(c pvcReferenceCheck) {
+			referenced, err := c.check(ctx, namespace, pvcName)
+			results <- checkResult{check: c, referenced: referenced, err: err}
+		}(c)
+	}
+
+	var requiredErr error
+	for range checks {
+		result := <-results
+		switch {
+		case result.referenced:
+			return true, nil
+		case result.err != nil && result.check.required:
+			requiredErr = fmt.Errorf("failed to list %s: %v", result.check.kind, result.err)
+		case result.err != nil:
+			log.Printf("Error checking %s: %v", result.check.kind, result.err)
+		}
+	}
+	return false, requiredErr
+}
+
+func (j *Janitor) isPVCReferencedByStatefulSets(ctx context.Context, namespace, pvcName string) (bool, error) {
 	statefulsets, err := j.client.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list statefulsets: %v", err)
+		return false, err
 	}
 
 	for _, sts := range statefulsets.Items {
@@ -93,49 +556,38 @@ func (j *Janitor) getPVCContext(ctx context.Context, pvc metav1.Object) (*Resour
 				continue
 			}
 			if matched {
-				isReferenced = true
 				log.Printf("PVC %s/%s is referenced by StatefulSet %s", namespace, pvcName, sts.Name)
-				break
+				return true, nil
 			}
 		}
-		if isReferenced {
-			break
-		}
 	}
+	return false, nil
+}
 
-	// Check if PVC is referenced by other workload types
-	if !isReferenced {
-		// Check Deployments
-		if referenced, err := j.isPVCReferencedByDeployments(ctx, namespace, pvcName); err != nil {
-			log.Printf("Error checking deployments: %v", err)
-		} else if referenced {
-			isReferenced = true
-		}
-
-		// Check Jobs
-		if !isReferenced {
-			if referenced, err := j.isPVCReferencedByJobs(ctx, namespace, pvcName); err != nil {
-				log.Printf("Error checking jobs: %v", err)
-			} else if referenced {
-				isReferenced = true
-			}
+// isPVCReferencedByCustomResources returns a check whether custom resources
+// reference a PVC in the pod template at .spec.template, e.g. Argo Rollouts
+func (j *Janitor) isPVCReferencedByCustomResources(gvr schema.GroupVersionResource) func(ctx context.Context, namespace, pvcName string) (bool, error) {
+	return func(ctx context.Context, namespace, pvcName string) (bool, error) {
+		list, err := j.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
 		}
 
-		// Check CronJobs
-		if !isReferenced {
-			if referenced, err := j.isPVCReferencedByCronJobs(ctx, namespace, pvcName); err != nil {
-				log.Printf("Error checking cronjobs: %v", err)
-			} else if referenced {
-				isReferenced = true
+		for _, item := range list.Items {
+			volumes, _, _ := unstructured.NestedSlice(item.Object, "spec", "template", "spec", "volumes")
+			for _, volume := range volumes {
+				v, ok := volume.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if claimName, _, _ := unstructured.NestedString(v, "persistentVolumeClaim", "claimName"); claimName == pvcName {
+					log.Printf("PVC %s/%s is referenced by %s %s", namespace, pvcName, item.GetKind(), item.GetName())
+					return true, nil
+				}
 			}
 		}
+		return false, nil
 	}
-
-	return &ResourceContext{
-		PVCIsNotMounted:    !isMounted,
-		PVCIsNotReferenced: !isReferenced,
-		Cache:              j.cache,
-	}, nil
 }
 
 func (j *Janitor) isPVCReferencedByDeployments(ctx context.Context, namespace, pvcName string) (bool, error) {
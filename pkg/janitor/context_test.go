@@ -2,13 +2,29 @@ package janitor
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestGetPVCContext(t *testing.T) {
@@ -103,6 +119,7 @@ func TestGetPVCContext(t *testing.T) {
 			// Create janitor instance with fake client
 			j := &Janitor{
 				client: clientset,
+				config: &Config{},
 				cache:  make(map[string]interface{}),
 			}
 
@@ -148,11 +165,12 @@ func TestGetPVCContext(t *testing.T) {
 
 func TestGetResourceContext(t *testing.T) {
 	tests := []struct {
-		name     string
-		resource metav1.Object
-		hook     ResourceContextHook
-		want     map[string]interface{}
-		setup    func(*testing.T, *Janitor)
+		name       string
+		resource   metav1.Object
+		hook       ResourceContextHook
+		clientHook ClientAwareResourceContextHook
+		want       map[string]interface{}
+		setup      func(*testing.T, *Janitor)
 	}{
 		{
 			name: "pvc with no hook",
@@ -203,6 +221,45 @@ func TestGetResourceContext(t *testing.T) {
 				"test_value": "test",
 			},
 		},
+		{
+			name: "resource with client-aware hook",
+			resource: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-pod",
+					Namespace: "default",
+				},
+			},
+			// Looks up the team owning the namespace in a ConfigMap
+			clientHook: func(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, resource interface{}, cache map[string]interface{}) map[string]interface{} {
+				namespace := resource.(metav1.Object).GetNamespace()
+				owners, err := client.CoreV1().ConfigMaps(namespace).Get(ctx, "owners", metav1.GetOptions{})
+				if err != nil {
+					return nil
+				}
+				return map[string]interface{}{
+					"owner_team": owners.Data["team"],
+				}
+			},
+			want: map[string]interface{}{
+				"owner_team": "platform",
+			},
+			setup: func(t *testing.T, j *Janitor) {
+				_, err := j.client.CoreV1().ConfigMaps("default").Create(
+					context.Background(),
+					&corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "owners",
+							Namespace: "default",
+						},
+						Data: map[string]string{"team": "platform"},
+					},
+					metav1.CreateOptions{},
+				)
+				if err != nil {
+					t.Fatalf("Failed to create ConfigMap: %v", err)
+				}
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -210,7 +267,8 @@ func TestGetResourceContext(t *testing.T) {
 			j := &Janitor{
 				client: fake.NewSimpleClientset(),
 				config: &Config{
-					ResourceContextHook: tt.hook,
+					ResourceContextHook:            tt.hook,
+					ClientAwareResourceContextHook: tt.clientHook,
 				},
 				cache: make(map[string]interface{}),
 			}
@@ -251,3 +309,800 @@ func TestGetResourceContext(t *testing.T) {
 		})
 	}
 }
+
+func TestResourceContextHooksLocking(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			ResourceContextHook: func(resource interface{}, cache map[string]interface{}) map[string]interface{} {
+				return map[string]interface{}{"simple": true}
+			},
+			// Blocks like a hook waiting for an API
+			ClientAwareResourceContextHook: func(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, resource interface{}, cache map[string]interface{}) map[string]interface{} {
+				if resource.(metav1.Object).GetName() == "slow" {
+					close(entered)
+					<-release
+				}
+				return nil
+			},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		slow := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "slow", Namespace: "default"}}
+		if _, err := j.getResourceContext(context.Background(), slow); err != nil {
+			t.Errorf("getResourceContext() error = %v", err)
+		}
+	}()
+	<-entered
+
+	// While the client-aware hook is busy, the cache of the run is not locked
+	// and the other hook still runs
+	if !j.cacheMutex.TryLock() {
+		t.Error("Expected the cache not to be locked while a hook runs")
+	} else {
+		j.cacheMutex.Unlock()
+	}
+	data := j.hookCache.call(func(cache map[string]interface{}) map[string]interface{} {
+		return j.config.ResourceContextHook(nil, cache)
+	})
+	if data["simple"] != true {
+		t.Errorf("Expected the simple hook to run, got %v", data)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestRuleMatchesResourceAge(t *testing.T) {
+	rule := Rule{
+		ID:        "older-than-a-day",
+		Resources: []string{"*"},
+		JMESPath:  "_context.age_seconds > `86400`",
+		TTL:       "1h",
+	}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{},
+		cache:  make(map[string]interface{}),
+	}
+
+	tests := []struct {
+		name    string
+		age     time.Duration
+		wantAge string
+		want    bool
+	}{
+		{name: "old pod", age: 50 * time.Hour, wantAge: "2d2h", want: true},
+		{name: "new pod", age: 2 * time.Hour, wantAge: "2h", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("pod", "default", time.Now().Add(-tt.age), nil)
+			contextData, err := j.getResourceContext(context.Background(), pod)
+			if err != nil {
+				t.Fatalf("getResourceContext() error = %v", err)
+			}
+			if contextData["age"] != tt.wantAge {
+				t.Errorf("Expected age %s, got %v", tt.wantAge, contextData["age"])
+			}
+			if got := rule.Matches(pod.Object, contextData); got != tt.want {
+				t.Errorf("Rule.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetWorkloadContext(t *testing.T) {
+	rule := Rule{
+		ID:        "scaled-to-zero",
+		Resources: []string{"*"},
+		JMESPath:  "_context.replicas == `0`",
+		TTL:       "7d",
+	}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{},
+		cache:  make(map[string]interface{}),
+	}
+
+	tests := []struct {
+		name   string
+		status map[string]interface{}
+		want   map[string]interface{}
+		match  bool
+	}{
+		{
+			name:   "scaled to zero",
+			status: map[string]interface{}{},
+			want: map[string]interface{}{
+				"replicas":           float64(0),
+				"ready_replicas":     float64(0),
+				"available_replicas": float64(0),
+			},
+			match: true,
+		},
+		{
+			name: "running",
+			status: map[string]interface{}{
+				"replicas":          int64(3),
+				"readyReplicas":     int64(2),
+				"availableReplicas": int64(1),
+			},
+			want: map[string]interface{}{
+				"replicas":           float64(3),
+				"ready_replicas":     float64(2),
+				"available_replicas": float64(1),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apps/v1",
+				"kind":       "Deployment",
+				"metadata": map[string]interface{}{
+					"name":      "web",
+					"namespace": "default",
+				},
+				"status": tt.status,
+			}}
+
+			contextData, err := j.getResourceContext(context.Background(), deployment)
+			if err != nil {
+				t.Fatalf("getResourceContext() error = %v", err)
+			}
+			if !reflect.DeepEqual(contextData, tt.want) {
+				t.Errorf("getResourceContext() = %v, want %v", contextData, tt.want)
+			}
+			if got := rule.Matches(deployment.Object, contextData); got != tt.match {
+				t.Errorf("Rule.Matches() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
+
+func TestServiceHasNoEndpoints(t *testing.T) {
+	newSlice := func(name, service string, endpoints int) *discoveryv1.EndpointSlice {
+		slice := &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: service},
+			},
+		}
+		for i := 0; i < endpoints; i++ {
+			slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}})
+		}
+		return slice
+	}
+
+	client := fake.NewSimpleClientset(
+		newSlice("web-abc", "web", 1),
+		newSlice("orphan-abc", "orphan", 0),
+	)
+	j := &Janitor{
+		client: client,
+		config: &Config{},
+		cache:  make(map[string]interface{}),
+	}
+
+	tests := []struct {
+		service string
+		want    bool
+	}{
+		{service: "web", want: false},
+		{service: "orphan", want: true},
+		{service: "no-slices", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			service := &unstructured.Unstructured{}
+			service.SetAPIVersion("v1")
+			service.SetKind("Service")
+			service.SetName(tt.service)
+			service.SetNamespace("default")
+
+			contextData, err := j.getResourceContext(context.Background(), service)
+			if err != nil {
+				t.Fatalf("getResourceContext() error = %v", err)
+			}
+			if got := contextData["service_has_no_endpoints"]; got != tt.want {
+				t.Errorf("service_has_no_endpoints = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The EndpointSlices are listed once per namespace
+	lists := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "list" && action.GetResource().Resource == "endpointslices" {
+			lists++
+		}
+	}
+	if lists != 1 {
+		t.Errorf("Expected EndpointSlices to be listed once, got %d", lists)
+	}
+}
+
+func TestGetJobContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		status map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name: "completed job",
+			status: map[string]interface{}{
+				"completionTime": "2024-01-02T03:04:05Z",
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Complete", "status": "True"},
+				},
+			},
+			want: map[string]interface{}{
+				"job_completed":       true,
+				"job_failed":          false,
+				"job_completion_time": "2024-01-02T03:04:05Z",
+			},
+		},
+		{
+			name: "failed job",
+			status: map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Failed", "status": "True"},
+				},
+			},
+			want: map[string]interface{}{
+				"job_completed":       false,
+				"job_failed":          true,
+				"job_completion_time": "",
+			},
+		},
+		{
+			name: "running job",
+			status: map[string]interface{}{
+				"active": int64(1),
+			},
+			want: map[string]interface{}{
+				"job_completed":       false,
+				"job_failed":          false,
+				"job_completion_time": "",
+			},
+		},
+	}
+
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{},
+		cache:  make(map[string]interface{}),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata": map[string]interface{}{
+					"name":      "backup",
+					"namespace": "default",
+				},
+				"status": tt.status,
+			}}
+
+			contextData, err := j.getResourceContext(context.Background(), job)
+			if err != nil {
+				t.Fatalf("getResourceContext() error = %v", err)
+			}
+			if !reflect.DeepEqual(contextData, tt.want) {
+				t.Errorf("getResourceContext() = %v, want %v", contextData, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPVContext(t *testing.T) {
+	rule := Rule{
+		ID:            "released-retained-pvs",
+		Resources:     []string{"*"},
+		JMESPath:      "_context.pv_is_released && _context.pv_reclaim_policy == 'Retain'",
+		TTL:           "7d",
+		ClusterScoped: true,
+	}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{},
+		cache:  make(map[string]interface{}),
+	}
+
+	tests := []struct {
+		name              string
+		phase             string
+		reclaimPolicy     string
+		wantReleased      bool
+		wantReclaimPolicy string
+		wantMatch         bool
+	}{
+		{
+			name:              "released retained pv",
+			phase:             "Released",
+			reclaimPolicy:     "Retain",
+			wantReleased:      true,
+			wantReclaimPolicy: "Retain",
+			wantMatch:         true,
+		},
+		{
+			name:              "bound pv",
+			phase:             "Bound",
+			reclaimPolicy:     "Retain",
+			wantReleased:      false,
+			wantReclaimPolicy: "Retain",
+			wantMatch:         false,
+		},
+		{
+			name:              "released pv with delete policy",
+			phase:             "Released",
+			reclaimPolicy:     "Delete",
+			wantReleased:      true,
+			wantReclaimPolicy: "Delete",
+			wantMatch:         false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pv := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "PersistentVolume",
+				"metadata": map[string]interface{}{
+					"name": "pv-1",
+				},
+				"spec": map[string]interface{}{
+					"persistentVolumeReclaimPolicy": tt.reclaimPolicy,
+				},
+				"status": map[string]interface{}{
+					"phase": tt.phase,
+				},
+			}}
+
+			contextData, err := j.getResourceContext(context.Background(), pv)
+			if err != nil {
+				t.Fatalf("getResourceContext() error = %v", err)
+			}
+			if contextData["pv_is_released"] != tt.wantReleased {
+				t.Errorf("Expected pv_is_released %v, got %v", tt.wantReleased, contextData["pv_is_released"])
+			}
+			if contextData["pv_reclaim_policy"] != tt.wantReclaimPolicy {
+				t.Errorf("Expected pv_reclaim_policy %s, got %v", tt.wantReclaimPolicy, contextData["pv_reclaim_policy"])
+			}
+			if got := rule.Matches(pv.Object, contextData); got != tt.wantMatch {
+				t.Errorf("Rule.Matches() = %v, want %v", got, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestIsPVCReferenced(t *testing.T) {
+	volumes := []corev1.Volume{{
+		Name:         "data",
+		VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data-db-0"}},
+	}}
+	meta := metav1.ObjectMeta{Name: "db", Namespace: "default"}
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: meta, Spec: appsv1.StatefulSetSpec{
+		VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+	}}
+	deployment := &appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{
+		Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Volumes: volumes}},
+	}}
+	job := &batchv1.Job{ObjectMeta: meta, Spec: batchv1.JobSpec{
+		Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Volumes: volumes}},
+	}}
+	cronJob := &batchv1.CronJob{ObjectMeta: meta, Spec: batchv1.CronJobSpec{
+		JobTemplate: batchv1.JobTemplateSpec{Spec: job.Spec},
+	}}
+
+	tests := []struct {
+		name           string
+		objects        []runtime.Object
+		failingList    string
+		wantReferenced bool
+		wantErr        bool
+	}{
+		{
+			name:           "referenced by all workload types",
+			objects:        []runtime.Object{statefulSet, deployment, job, cronJob},
+			wantReferenced: true,
+		},
+		{
+			name:           "referenced by a CronJob only",
+			objects:        []runtime.Object{cronJob},
+			wantReferenced: true,
+		},
+		{
+			name: "not referenced",
+		},
+		{
+			name:           "reference found despite failing StatefulSet list",
+			objects:        []runtime.Object{deployment, job},
+			failingList:    "statefulsets",
+			wantReferenced: true,
+		},
+		{
+			name:        "failing StatefulSet list without reference",
+			failingList: "statefulsets",
+			wantErr:     true,
+		},
+		{
+			name:        "failing Deployment list is only logged",
+			failingList: "deployments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tt.objects...)
+			if tt.failingList != "" {
+				clientset.PrependReactor("list", tt.failingList, func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, fmt.Errorf("connection refused")
+				})
+			}
+			j := &Janitor{client: clientset, config: &Config{}}
+
+			// Run several checks at once, e.g. for PVCs processed in parallel
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					referenced, err := j.isPVCReferenced(context.Background(), "default", "data-db-0")
+					if (err != nil) != tt.wantErr {
+						t.Errorf("isPVCReferenced() error = %v, wantErr %v", err, tt.wantErr)
+					}
+					if referenced != tt.wantReferenced {
+						t.Errorf("isPVCReferenced() = %v, want %v", referenced, tt.wantReferenced)
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestGetPVCContextCustomResources(t *testing.T) {
+	rollout := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"volumes": []interface{}{
+						map[string]interface{}{"name": "tmp", "emptyDir": map[string]interface{}{}},
+						map[string]interface{}{"name": "cache", "persistentVolumeClaim": map[string]interface{}{"claimName": "web-cache"}},
+					},
+				},
+			},
+		},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}: "RolloutList"},
+		rollout)
+
+	tests := []struct {
+		name              string
+		pvc               string
+		resources         []string
+		wantNotReferenced bool
+	}{
+		{name: "referenced by a configured custom resource", pvc: "web-cache", resources: []string{"argoproj.io/v1alpha1/rollouts"}},
+		{name: "custom resources not configured", pvc: "web-cache", wantNotReferenced: true},
+		{name: "other PVC", pvc: "db-data", resources: []string{"argoproj.io/v1alpha1/rollouts"}, wantNotReferenced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				client:        fake.NewSimpleClientset(),
+				dynamicClient: dynamicClient,
+				config:        &Config{PVCReferenceResources: tt.resources},
+			}
+
+			pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: tt.pvc, Namespace: "default"}}
+			got, err := j.getPVCContext(context.Background(), pvc)
+			if err != nil {
+				t.Fatalf("getPVCContext() error = %v", err)
+			}
+			if got.PVCIsNotReferenced != tt.wantNotReferenced {
+				t.Errorf("getPVCContext().PVCIsNotReferenced = %v, want %v", got.PVCIsNotReferenced, tt.wantNotReferenced)
+			}
+		})
+	}
+}
+
+func TestOwnerExists(t *testing.T) {
+	rule := Rule{
+		ID:        "orphaned-replicasets",
+		Resources: []string{"*"},
+		JMESPath:  "_context.owner_exists == `false`",
+		TTL:       "1h",
+	}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	deployment := &unstructured.Unstructured{}
+	deployment.SetAPIVersion("apps/v1")
+	deployment.SetKind("Deployment")
+	deployment.SetName("web")
+	deployment.SetNamespace("default")
+	deployment.SetUID("web-uid")
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), deployment)
+
+	newReplicaSet := func(name string, owner *metav1.OwnerReference) *unstructured.Unstructured {
+		replicaSet := &unstructured.Unstructured{}
+		replicaSet.SetAPIVersion("apps/v1")
+		replicaSet.SetKind("ReplicaSet")
+		replicaSet.SetName(name)
+		replicaSet.SetNamespace("default")
+		if owner != nil {
+			replicaSet.SetOwnerReferences([]metav1.OwnerReference{*owner})
+		}
+		return replicaSet
+	}
+
+	j := &Janitor{
+		client:        fake.NewSimpleClientset(),
+		dynamicClient: dynamicClient,
+		config:        &Config{ResolveOwners: true},
+		cache:         make(map[string]interface{}),
+		plurals: map[schema.GroupVersionKind]string{
+			{Group: "apps", Version: "v1", Kind: "Deployment"}: "deployments",
+		},
+	}
+
+	tests := []struct {
+		name            string
+		owner           *metav1.OwnerReference
+		wantOwnerExists interface{}
+		wantMatch       bool
+	}{
+		{
+			name:            "live owner",
+			owner:           &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "web-uid"},
+			wantOwnerExists: true,
+		},
+		{
+			name:            "missing owner",
+			owner:           &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "api", UID: "api-uid"},
+			wantOwnerExists: false,
+			wantMatch:       true,
+		},
+		{
+			name:            "owner recreated with another UID",
+			owner:           &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "old-web-uid"},
+			wantOwnerExists: false,
+			wantMatch:       true,
+		},
+		{
+			name:            "owner in a version that was not discovered",
+			owner:           &metav1.OwnerReference{APIVersion: "apps/v1beta1", Kind: "Deployment", Name: "web", UID: "web-uid"},
+			wantOwnerExists: true,
+		},
+		{
+			name:            "owner of a kind that was not discovered",
+			owner:           &metav1.OwnerReference{APIVersion: "example.com/v1", Kind: "Widget", Name: "web", UID: "widget-uid"},
+			wantOwnerExists: true,
+		},
+		{
+			name: "no owner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicaSet := newReplicaSet("web-abc", tt.owner)
+
+			contextData, err := j.getResourceContext(context.Background(), replicaSet)
+			if err != nil {
+				t.Fatalf("getResourceContext() error = %v", err)
+			}
+			if got := contextData["owner_exists"]; got != tt.wantOwnerExists {
+				t.Errorf("owner_exists = %v, want %v", got, tt.wantOwnerExists)
+			}
+			if tt.owner != nil && !reflect.DeepEqual(contextData["owner_kinds"], []interface{}{tt.owner.Kind}) {
+				t.Errorf("owner_kinds = %v, want [%s]", contextData["owner_kinds"], tt.owner.Kind)
+			}
+			if got := rule.Matches(replicaSet.Object, contextData); got != tt.wantMatch {
+				t.Errorf("Rule.Matches() = %v, want %v", got, tt.wantMatch)
+			}
+		})
+	}
+
+	// Owners are looked up once per run, owners of kinds that were not
+	// discovered not at all
+	if _, err := j.getResourceContext(context.Background(), newReplicaSet("web-def", tests[0].owner)); err != nil {
+		t.Fatalf("getResourceContext() error = %v", err)
+	}
+	gets := 0
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "get" {
+			gets++
+		}
+	}
+	if gets != 3 {
+		t.Errorf("Expected one get per distinct discovered owner, got %d", gets)
+	}
+}
+
+func TestNamespaceIsEmpty(t *testing.T) {
+	rule := Rule{
+		ID:        "empty-namespaces",
+		Resources: []string{"namespaces"},
+		JMESPath:  "_context.namespace_is_empty && _context.age_seconds > `86400`",
+		TTL:       "1h",
+	}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	newObject := func(kind, namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+	newTokenSecret := func(namespace, serviceAccount string) *unstructured.Unstructured {
+		secret := newObject("Secret", namespace, serviceAccount+"-token-abcde")
+		secret.SetAnnotations(map[string]string{corev1.ServiceAccountNameKey: serviceAccount})
+		unstructured.SetNestedField(secret.Object, string(corev1.SecretTypeServiceAccountToken), "type")
+		return secret
+	}
+
+	var objects []runtime.Object
+	for _, namespace := range []string{"empty", "busy", "builder"} {
+		objects = append(objects,
+			newObject("ServiceAccount", namespace, "default"),
+			newObject("ConfigMap", namespace, "kube-root-ca.crt"),
+			newTokenSecret(namespace, "default"),
+			newObject("Event", namespace, "pod.17a"),
+		)
+	}
+	objects = append(objects,
+		newObject("Pod", "busy", "web"),
+		newObject("ServiceAccount", "builder", "builder"),
+	)
+	// Resource types that can't be deleted still count
+	lease := newObject("Lease", "leased", "leader")
+	lease.SetAPIVersion("example.com/v1")
+	objects = append(objects, lease)
+
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "events", Kind: "Event", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "namespaces", Kind: "Namespace", Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "leases", Kind: "Lease", Namespaced: true, Verbs: []string{"get", "list"}},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "pods"}:                         "PodList",
+			{Version: "v1", Resource: "serviceaccounts"}:              "ServiceAccountList",
+			{Version: "v1", Resource: "configmaps"}:                   "ConfigMapList",
+			{Version: "v1", Resource: "secrets"}:                      "SecretList",
+			{Version: "v1", Resource: "events"}:                       "EventList",
+			{Group: "example.com", Version: "v1", Resource: "leases"}: "LeaseList",
+		},
+		objects...,
+	)
+
+	tests := []struct {
+		namespace string
+		include   []string
+		want      bool
+	}{
+		{namespace: "empty", include: []string{"all"}, want: true},
+		{namespace: "busy", include: []string{"all"}, want: false},
+		{namespace: "builder", include: []string{"all"}, want: false},
+		// Resource types that are not cleaned up still count
+		{namespace: "empty", include: []string{"namespaces"}, want: true},
+		{namespace: "busy", include: []string{"namespaces"}, want: false},
+		{namespace: "leased", include: []string{"namespaces"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.namespace+" including "+strings.Join(tt.include, ","), func(t *testing.T) {
+			j := &Janitor{
+				client:        clientset,
+				dynamicClient: dynamicClient,
+				config: &Config{
+					IncludeResources: tt.include,
+					Rules:            []Rule{rule},
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:              tt.namespace,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-48 * time.Hour)),
+			}}
+
+			contextData, err := j.getResourceContext(context.Background(), namespace)
+			if err != nil {
+				t.Fatalf("getResourceContext() error = %v", err)
+			}
+			if got := contextData["namespace_is_empty"]; got != tt.want {
+				t.Errorf("namespace_is_empty = %v, want %v", got, tt.want)
+			}
+
+			resourceMap, err := j.objectToMap(namespace)
+			if err != nil {
+				t.Fatalf("objectToMap() error = %v", err)
+			}
+			if got := rule.Matches(resourceMap, contextData); got != tt.want {
+				t.Errorf("Rule.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Discovery and the result of a namespace are reused within a run
+	j := &Janitor{client: clientset, dynamicClient: dynamicClient, config: &Config{}}
+	if _, err := j.getResourceTypes(); err != nil {
+		t.Fatalf("getResourceTypes() error = %v", err)
+	}
+	discoveries := len(clientset.Actions())
+	if empty, err := j.namespaceIsEmpty(context.Background(), "empty"); err != nil || !empty {
+		t.Fatalf("namespaceIsEmpty() = %v, %v, want true", empty, err)
+	}
+	lists := len(dynamicClient.Actions())
+	if empty, err := j.namespaceIsEmpty(context.Background(), "empty"); err != nil || !empty {
+		t.Fatalf("namespaceIsEmpty() = %v, %v, want true", empty, err)
+	}
+	if got := len(clientset.Actions()) - discoveries; got != 0 {
+		t.Errorf("Expected the discovery of the run to be reused, got %d more discovery calls", got)
+	}
+	if got := len(dynamicClient.Actions()) - lists; got != 0 {
+		t.Errorf("Expected the result to be cached for the run, got %d more list calls", got)
+	}
+
+	// A resource type that can't be listed makes the namespace count as not empty
+	dynamicClient.PrependReactor("list", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "example.com", Resource: "leases"}, "", errors.New("denied"))
+	})
+	j = &Janitor{client: clientset, dynamicClient: dynamicClient, config: &Config{}}
+	if empty, err := j.namespaceIsEmpty(context.Background(), "empty"); err != nil || empty {
+		t.Errorf("namespaceIsEmpty() = %v, %v, want false without error", empty, err)
+	}
+}
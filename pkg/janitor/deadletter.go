@@ -0,0 +1,47 @@
+package janitor
+
+import (
+	"fmt"
+	"log"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// trackDeleteResult counts consecutive delete failures of a resource and
+// reports the resource as a persistent deletion failure once the configured
+// threshold is reached. A successful delete resets the count.
+func (j *Janitor) trackDeleteResult(obj metav1.Object, err error) {
+	if j.config.DeleteFailureThreshold <= 0 {
+		return
+	}
+
+	gvk := objectGVK(obj)
+	key := fmt.Sprintf("%s/%s/%s/%s", gvk.Group, gvk.Kind, obj.GetNamespace(), obj.GetName())
+
+	j.deleteFailuresMutex.Lock()
+	defer j.deleteFailuresMutex.Unlock()
+
+	if j.deleteFailures == nil {
+		j.deleteFailures = make(map[string]int)
+	}
+
+	// A resource that is gone no longer needs to be tracked
+	if err == nil || apierrors.IsNotFound(err) {
+		delete(j.deleteFailures, key)
+	} else {
+		j.deleteFailures[key]++
+		if failures := j.deleteFailures[key]; failures >= j.config.DeleteFailureThreshold {
+			log.Printf("Warning: persistent deletion failure: %s %s/%s failed to delete %d times in a row: %v",
+				gvk.Kind, obj.GetNamespace(), obj.GetName(), failures, err)
+		}
+	}
+
+	persistent := 0
+	for _, failures := range j.deleteFailures {
+		if failures >= j.config.DeleteFailureThreshold {
+			persistent++
+		}
+	}
+	persistentDeleteFailures.Set(float64(persistent))
+}
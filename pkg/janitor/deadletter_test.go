@@ -0,0 +1,55 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPersistentDeletionFailure(t *testing.T) {
+	pod := newUnstructuredPod("stuck-pod", "default", time.Now().Add(-2*time.Hour), nil)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+
+	// Fail deletes until told otherwise, e.g. due to a failing admission webhook
+	failDeletes := true
+	dynamicClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if failDeletes {
+			return true, nil, errors.New("admission webhook denied the request")
+		}
+		return false, nil, nil
+	})
+
+	j := &Janitor{
+		dynamicClient: dynamicClient,
+		config:        &Config{DeleteFailureThreshold: 3},
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := j.deleteResource(context.Background(), pod, "", ""); err == nil {
+			t.Fatalf("Expected delete #%d to fail", i)
+		}
+
+		want := 0.0
+		if i == 3 {
+			want = 1
+		}
+		if got := testutil.ToFloat64(persistentDeleteFailures); got != want {
+			t.Errorf("After %d failures: persistent delete failures = %v, want %v", i, got, want)
+		}
+	}
+
+	// A successful delete clears the failure
+	failDeletes = false
+	if err := j.deleteResource(context.Background(), pod, "", ""); err != nil {
+		t.Fatalf("deleteResource() error = %v", err)
+	}
+	if got := testutil.ToFloat64(persistentDeleteFailures); got != 0 {
+		t.Errorf("After a successful delete: persistent delete failures = %v, want 0", got)
+	}
+}
@@ -0,0 +1,86 @@
+package janitor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Decisions for a resource
+const (
+	DecisionDelete = "delete"
+	DecisionKeep   = "keep"
+)
+
+// Decision records why a resource is deleted or retained in a cleanup run
+type Decision struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+	Age       time.Duration
+	Source    string // where the TTL or expiry came from, e.g. "annotation janitor/ttl=1h"
+	Decision  string
+	Reason    string
+}
+
+// recordDecision records the decision for a resource if the decision table is
+// enabled or the deletions are being planned
+func (j *Janitor) recordDecision(obj metav1.Object, source, decision, reason string) {
+	if !j.config.DryRunTable && !j.planning.Load() {
+		return
+	}
+
+	age := time.Duration(0)
+	if created := obj.GetCreationTimestamp(); !created.IsZero() {
+		age = time.Since(created.Time)
+	}
+
+	j.decisionsMutex.Lock()
+	defer j.decisionsMutex.Unlock()
+	gvk := objectGVK(obj)
+	j.decisions = append(j.decisions, Decision{
+		Group:     gvk.Group,
+		Kind:      gvk.Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Age:       age,
+		Source:    source,
+		Decision:  decision,
+		Reason:    reason,
+	})
+}
+
+// writeDecisionTable writes the decisions recorded in the current run as a table
+func (j *Janitor) writeDecisionTable(out io.Writer) error {
+	j.decisionsMutex.Lock()
+	defer j.decisionsMutex.Unlock()
+	return WriteDecisionTable(out, j.decisions)
+}
+
+// WriteDecisionTable sorts decisions by kind, namespace and name and writes
+// them as a table
+func WriteDecisionTable(out io.Writer, decisions []Decision) error {
+	sort.Slice(decisions, func(a, b int) bool {
+		da, db := decisions[a], decisions[b]
+		if da.Kind != db.Kind {
+			return da.Kind < db.Kind
+		}
+		if da.Namespace != db.Namespace {
+			return da.Namespace < db.Namespace
+		}
+		return da.Name < db.Name
+	})
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tAGE\tSOURCE\tDECISION\tREASON")
+	for _, d := range decisions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.Kind, d.Namespace, d.Name, FormatDuration(d.Age.Truncate(time.Minute)), d.Source, d.Decision, d.Reason)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,77 @@
+package janitor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWriteDecisionTable(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:            true,
+			DryRunTable:       true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	resources := []struct {
+		name        string
+		created     time.Time
+		annotations map[string]string
+	}{
+		{"expired-pod", time.Now().Add(-2 * time.Hour), map[string]string{TTLAnnotation: "1h"}},
+		{"valid-pod", time.Now().Add(-30 * time.Minute), map[string]string{TTLAnnotation: "1h"}},
+		{"forever-pod", time.Now().Add(-30 * time.Minute), map[string]string{TTLAnnotation: "forever"}},
+	}
+	for _, r := range resources {
+		pod := newUnstructuredPod(r.name, "default", r.created, r.annotations)
+		if err := j.handleResource(context.Background(), pod, make(map[string]int), make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource() error = %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := j.writeDecisionTable(&buf); err != nil {
+		t.Fatalf("writeDecisionTable() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header and 3 rows, got:\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "KIND NAMESPACE NAME AGE SOURCE DECISION REASON" {
+		t.Errorf("Unexpected header: %s", lines[0])
+	}
+
+	// Rows are sorted by kind, namespace, and name
+	wantRows := []struct {
+		name     string
+		age      string
+		decision string
+		reason   string
+	}{
+		{"expired-pod", "2h", DecisionDelete, "TTL 1h expired on"},
+		{"forever-pod", "30m", DecisionKeep, "unlimited TTL"},
+		{"valid-pod", "30m", DecisionKeep, "TTL 1h expires on"},
+	}
+	for i, want := range wantRows {
+		fields := strings.Fields(lines[i+1])
+		if fields[0] != "Pod" || fields[1] != "default" || fields[2] != want.name || fields[3] != want.age {
+			t.Errorf("Unexpected row %d: %s", i, lines[i+1])
+		}
+		if !strings.Contains(lines[i+1], "annotation "+TTLAnnotation) {
+			t.Errorf("Expected row %d to show the TTL annotation as source: %s", i, lines[i+1])
+		}
+		if !strings.Contains(lines[i+1], " "+want.decision+" ") || !strings.Contains(lines[i+1], want.reason) {
+			t.Errorf("Expected row %d to be %s (%s): %s", i, want.decision, want.reason, lines[i+1])
+		}
+	}
+}
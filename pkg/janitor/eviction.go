@@ -0,0 +1,63 @@
+package janitor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Number of attempts to evict a pod that is blocked by a PodDisruptionBudget
+// and the backoff before the first retry, doubled for every further retry
+var (
+	evictionAttempts     = 3
+	evictionRetryBackoff = 5 * time.Second
+)
+
+// shouldEvict checks whether a resource is a pod that is removed through the
+// Eviction API instead of being deleted
+func (j *Janitor) shouldEvict(obj metav1.Object) bool {
+	gvk := objectGVK(obj)
+	return j.config.UseEviction && gvk.Group == "" && gvk.Kind == "Pod"
+}
+
+// evictPod removes a pod through the Eviction API, so that PodDisruptionBudgets
+// are honored. Evictions that are rejected because of a PodDisruptionBudget
+// (429 Too Many Requests) are retried with backoff, and the pod is deleted
+// once the retries are exhausted.
+func (j *Janitor) evictPod(ctx context.Context, obj metav1.Object, deleteOptions metav1.DeleteOptions) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta:    metav1.ObjectMeta{Name: obj.GetName(), Namespace: obj.GetNamespace()},
+		DeleteOptions: &deleteOptions,
+	}
+
+	backoff := evictionRetryBackoff
+	for attempt := 1; ; attempt++ {
+		j.infoLog("Evicting pod %s/%s", obj.GetNamespace(), obj.GetName())
+		err := j.client.PolicyV1().Evictions(obj.GetNamespace()).Evict(ctx, eviction)
+		if err == nil || !apierrors.IsTooManyRequests(err) {
+			return err
+		}
+		if attempt >= evictionAttempts {
+			log.Printf("Warning: eviction of pod %s/%s is still blocked after %d attempts, deleting it: %v",
+				obj.GetNamespace(), obj.GetName(), attempt, err)
+			break
+		}
+
+		j.debugLog("Eviction of pod %s/%s blocked (attempt %d/%d), retrying in %v: %v",
+			obj.GetNamespace(), obj.GetName(), attempt, evictionAttempts, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		backoff *= 2
+	}
+
+	return j.dynamicClient.Resource(j.gvrFor(obj)).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), deleteOptions)
+}
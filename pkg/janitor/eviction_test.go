@@ -0,0 +1,72 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDeleteResourceUseEviction(t *testing.T) {
+	defer func(backoff time.Duration) { evictionRetryBackoff = backoff }(evictionRetryBackoff)
+	evictionRetryBackoff = time.Millisecond
+
+	tests := []struct {
+		name          string
+		useEviction   bool
+		blocked       bool
+		wantEvictions int
+		wantDeletes   int
+	}{
+		{name: "pod is evicted", useEviction: true, wantEvictions: 1},
+		{name: "blocked eviction falls back to delete", useEviction: true, blocked: true, wantEvictions: evictionAttempts, wantDeletes: 1},
+		{name: "pod is deleted without eviction", wantDeletes: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("expired", "default", time.Now().Add(-2*time.Hour), nil)
+
+			evictions := 0
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "eviction" {
+					return false, nil, nil
+				}
+				evictions++
+				if tt.blocked {
+					return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+				}
+				return true, nil, nil
+			})
+
+			deletes := 0
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+			dynamicClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				deletes++
+				return false, nil, nil
+			})
+
+			j := &Janitor{
+				client:        clientset,
+				dynamicClient: dynamicClient,
+				config:        &Config{UseEviction: tt.useEviction},
+			}
+			if err := j.deleteResource(context.Background(), pod, "", ""); err != nil {
+				t.Fatalf("deleteResource() error = %v", err)
+			}
+
+			if evictions != tt.wantEvictions {
+				t.Errorf("Expected %d evictions, got %d", tt.wantEvictions, evictions)
+			}
+			if deletes != tt.wantDeletes {
+				t.Errorf("Expected %d deletes, got %d", tt.wantDeletes, deletes)
+			}
+		})
+	}
+}
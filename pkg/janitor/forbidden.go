@@ -0,0 +1,74 @@
+package janitor
+
+import (
+	"errors"
+	"log"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// errTypeForbidden is returned when listing or deleting resources of a type
+// is forbidden, after which the type is skipped for the rest of the run
+var errTypeForbidden = errors.New("resource type is forbidden")
+
+// markForbidden skips a resource type for the rest of the run because the
+// janitor is not permitted to list or delete it. Only the first denial of a
+// type is logged, so that a missing permission doesn't flood the logs with an
+// error per resource.
+func (j *Janitor) markForbidden(gvr schema.GroupVersionResource, verb string, err error) {
+	j.forbiddenMutex.Lock()
+	defer j.forbiddenMutex.Unlock()
+
+	if j.forbiddenTypes[gvr] {
+		return
+	}
+	if j.forbiddenTypes == nil {
+		j.forbiddenTypes = make(map[schema.GroupVersionResource]bool)
+	}
+	j.forbiddenTypes[gvr] = true
+
+	log.Printf("Warning: skipping %s for the rest of the run, the janitor is not permitted to %s them, check its RBAC permissions: %v",
+		gvr.GroupResource(), verb, err)
+	forbiddenResourceTypes.WithLabelValues(gvr.GroupResource().String(), verb).Set(1)
+}
+
+// isForbidden checks if a resource type was found to be forbidden in the
+// current run
+func (j *Janitor) isForbidden(gvr schema.GroupVersionResource) bool {
+	j.forbiddenMutex.Lock()
+	defer j.forbiddenMutex.Unlock()
+	return j.forbiddenTypes[gvr]
+}
+
+// resetForbidden forgets the forbidden resource types at the start of a run,
+// so that granted permissions are picked up
+func (j *Janitor) resetForbidden() {
+	j.forbiddenMutex.Lock()
+	defer j.forbiddenMutex.Unlock()
+	j.forbiddenTypes = nil
+	forbiddenResourceTypes.Reset()
+}
+
+// deleteSkipped checks if a delete failed only because the resource no longer
+// exists, its type is forbidden or doesn't support delete, deletions are
+// paused or not confirmed, none of which is an error of the run. All but gone
+// resources are counted as skipped.
+func (j *Janitor) deleteSkipped(err error, counter map[string]int) bool {
+	if errors.Is(err, errPaused) {
+		j.countSkip(counter, SkipReasonPaused)
+		return true
+	}
+	if errors.Is(err, errNotConfirmed) {
+		j.countSkip(counter, SkipReasonNotConfirmed)
+		return true
+	}
+	if errors.Is(err, errTypeForbidden) {
+		j.countSkip(counter, SkipReasonForbidden)
+		return true
+	}
+	if errors.Is(err, errNotDeletable) {
+		j.countSkip(counter, SkipReasonNotDeletable)
+		return true
+	}
+	return errors.Is(err, errResourceGone)
+}
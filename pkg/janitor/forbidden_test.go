@@ -0,0 +1,101 @@
+package janitor
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestForbiddenResourceTypeSkipped(t *testing.T) {
+	tests := []struct {
+		name     string
+		verb     string
+		wantSkip bool
+	}{
+		{name: "forbidden list", verb: "list"},
+		{name: "forbidden delete", verb: "delete", wantSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+			var objects []runtime.Object
+			for _, ns := range []string{"team-a", "team-b"} {
+				for _, name := range []string{"first", "second"} {
+					objects = append(objects, newUnstructuredPod(name, ns, time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"}))
+				}
+			}
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{podsGVR: "PodList"}, objects...)
+			calls := 0
+			dynamicClient.PrependReactor(tt.verb, "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				calls++
+				return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", nil)
+			})
+
+			clientset := fake.NewSimpleClientset(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+			)
+			clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+			j := &Janitor{
+				client:        clientset,
+				dynamicClient: dynamicClient,
+				config: &Config{
+					IncludeResources:  []string{"all"},
+					IncludeNamespaces: []string{"all"},
+				},
+				cache: make(map[string]interface{}),
+			}
+			j.resetForbidden()
+
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			counter := make(map[string]int)
+			podType := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+			if err := j.cleanupResourceType(context.Background(), podType, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("cleanupResourceType() error = %v", err)
+			}
+
+			if calls != 1 {
+				t.Errorf("Expected the type to be skipped after the first forbidden %s, got %d calls", tt.verb, calls)
+			}
+			if got := strings.Count(buf.String(), "Warning:"); got != 1 {
+				t.Errorf("Expected a single warning, got %d:\n%s", got, buf.String())
+			}
+			// Namespaces that are still listed once a delete was forbidden are
+			// skipped, so the number of processed resources varies
+			result := newCleanupResult(counter)
+			if result.Errors != 0 || result.Skipped[SkipReasonForbidden] != result.Processed || (result.Processed > 0) != tt.wantSkip {
+				t.Errorf("Expected no errors and all processed resources to be skipped as forbidden, got %+v", result)
+			}
+			if got := testutil.ToFloat64(forbiddenResourceTypes.WithLabelValues("pods", tt.verb)); got != 1 {
+				t.Errorf("forbidden resource types metric = %v, want 1", got)
+			}
+
+			// The next run tries again, in case the permissions were granted
+			j.resetForbidden()
+			if j.isForbidden(podsGVR) {
+				t.Error("Expected the forbidden types to be reset for the next run")
+			}
+		})
+	}
+}
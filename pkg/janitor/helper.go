@@ -14,11 +14,15 @@ var (
 		"2006-01-02T15:04",
 		"2006-01-02",
 	}
+
+	// UnlimitedTTLAliases are accepted in addition to TTLUnlimited as TTL
+	// values that never expire
+	UnlimitedTTLAliases = []string{"never", "infinite", "-1"}
 )
 
 // ParseTTL parses a TTL string into duration
 func ParseTTL(ttl string) (time.Duration, error) {
-	if ttl == TTLUnlimited {
+	if ttl == TTLUnlimited || stringInSlice(ttl, UnlimitedTTLAliases) {
 		return -1, nil
 	}
 
@@ -48,6 +48,29 @@ func TestParseTTL(t *testing.T) {
 			expected: -1,
 			wantErr:  false,
 		},
+		{
+			name:     "never alias",
+			ttl:      "never",
+			expected: -1,
+			wantErr:  false,
+		},
+		{
+			name:     "infinite alias",
+			ttl:      "infinite",
+			expected: -1,
+			wantErr:  false,
+		},
+		{
+			name:     "-1 alias",
+			ttl:      "-1",
+			expected: -1,
+			wantErr:  false,
+		},
+		{
+			name:    "negative TTL",
+			ttl:     "-2",
+			wantErr: true,
+		},
 		{
 			name:    "invalid format",
 			ttl:     "invalid",
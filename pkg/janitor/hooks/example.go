@@ -2,11 +2,26 @@ package hooks
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
 const CacheKeyRandomDice = "random_dice"
 
+// diceRand is the source of the dice rolls, shared by all janitor runs
+var (
+	diceRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+	diceMutex sync.Mutex
+)
+
+// SeedRandomDice seeds the dice of RandomDice, so that the sequence of rolls
+// is reproducible, e.g. for tests or canary rollouts
+func SeedRandomDice(seed int64) {
+	diceMutex.Lock()
+	defer diceMutex.Unlock()
+	diceRand = rand.New(rand.NewSource(seed))
+}
+
 // RandomDice is a built-in example resource context hook that sets _context.random_dice
 // to a random dice value (1-6)
 func RandomDice(resource interface{}, cache map[string]interface{}) map[string]interface{} {
@@ -18,8 +33,9 @@ func RandomDice(resource interface{}, cache map[string]interface{}) map[string]i
 	}
 
 	// Roll the dice
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	diceValue := r.Intn(6) + 1 // 1-6
+	diceMutex.Lock()
+	diceValue := diceRand.Intn(6) + 1 // 1-6
+	diceMutex.Unlock()
 
 	// Cache the value
 	cache[CacheKeyRandomDice] = diceValue
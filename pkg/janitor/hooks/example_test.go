@@ -1,6 +1,7 @@
 package hooks
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -38,3 +39,23 @@ func TestRandomDiceCache(t *testing.T) {
 		t.Error("Expected dice value to be cached")
 	}
 }
+
+func TestRandomDiceSeed(t *testing.T) {
+	roll := func() []int {
+		var rolls []int
+		for i := 0; i < 10; i++ {
+			// A new cache per roll, as in separate janitor runs
+			rolls = append(rolls, RandomDice(nil, make(map[string]interface{}))["random_dice"].(int))
+		}
+		return rolls
+	}
+
+	SeedRandomDice(42)
+	first := roll()
+	SeedRandomDice(42)
+	second := roll()
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected identical seeds to yield identical rolls, got %v and %v", first, second)
+	}
+}
@@ -1,16 +1,26 @@
 package hooks
 
 import (
+	"context"
 	"fmt"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
 // ResourceContextHook is a function that can extend the context with custom information
 type ResourceContextHook func(resource interface{}, cache map[string]interface{}) map[string]interface{}
 
-// Hook represents a resource context hook
+// ClientAwareResourceContextHook is a resource context hook that also gets the
+// clients of the janitor, e.g. to look up objects related to the resource
+type ClientAwareResourceContextHook func(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, resource interface{}, cache map[string]interface{}) map[string]interface{}
+
+// Hook represents a resource context hook. Hooks that query the cluster set
+// ClientAwareFunc instead of Func.
 type Hook struct {
-	Name string
-	Func ResourceContextHook
+	Name            string
+	Func            ResourceContextHook
+	ClientAwareFunc ClientAwareResourceContextHook
 }
 
 // RegisteredHooks contains all available hooks
@@ -19,12 +29,40 @@ var RegisteredHooks = map[string]Hook{
 		Name: "random_dice",
 		Func: RandomDice,
 	},
+	"rollout_bucket": {
+		Name: "rollout_bucket",
+		Func: RolloutBucket,
+	},
 }
 
 // GetHook returns a hook by name
 func GetHook(name string) (ResourceContextHook, error) {
-	if hook, ok := RegisteredHooks[name]; ok {
-		return hook.Func, nil
+	hook, ok := RegisteredHooks[name]
+	if !ok {
+		return nil, fmt.Errorf("hook %q not found", name)
+	}
+	if hook.Func == nil {
+		return nil, fmt.Errorf("hook %q needs the Kubernetes clients, use GetClientAwareHook", name)
+	}
+	return hook.Func, nil
+}
+
+// GetClientAwareHook returns a hook by name, including hooks that don't use
+// the clients
+func GetClientAwareHook(name string) (ClientAwareResourceContextHook, error) {
+	hook, ok := RegisteredHooks[name]
+	if !ok {
+		return nil, fmt.Errorf("hook %q not found", name)
+	}
+	if hook.ClientAwareFunc != nil {
+		return hook.ClientAwareFunc, nil
+	}
+	return WithClients(hook.Func), nil
+}
+
+// WithClients turns a hook into a client-aware hook that ignores the clients
+func WithClients(hook ResourceContextHook) ClientAwareResourceContextHook {
+	return func(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, resource interface{}, cache map[string]interface{}) map[string]interface{} {
+		return hook(resource, cache)
 	}
-	return nil, fmt.Errorf("hook %q not found", name)
 }
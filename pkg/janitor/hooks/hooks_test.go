@@ -1,7 +1,15 @@
 package hooks
 
 import (
+	"context"
+	"reflect"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestRandomDice(t *testing.T) {
@@ -33,3 +41,46 @@ func TestRandomDice(t *testing.T) {
 		t.Error("Expected cached dice value to be reused")
 	}
 }
+
+func TestGetClientAwareHook(t *testing.T) {
+	const name = "owner_team"
+	RegisteredHooks[name] = Hook{
+		Name: name,
+		ClientAwareFunc: func(ctx context.Context, client kubernetes.Interface, dynamicClient dynamic.Interface, resource interface{}, cache map[string]interface{}) map[string]interface{} {
+			owners, err := client.CoreV1().ConfigMaps("default").Get(ctx, "owners", metav1.GetOptions{})
+			if err != nil {
+				return nil
+			}
+			return map[string]interface{}{"owner_team": owners.Data["team"]}
+		},
+	}
+	defer delete(RegisteredHooks, name)
+
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "owners", Namespace: "default"},
+		Data:       map[string]string{"team": "platform"},
+	})
+
+	hook, err := GetClientAwareHook(name)
+	if err != nil {
+		t.Fatalf("GetClientAwareHook() error = %v", err)
+	}
+	if got := hook(context.Background(), client, nil, nil, make(map[string]interface{})); got["owner_team"] != "platform" {
+		t.Errorf("Expected the hook to read the owner team from the cluster, got %v", got)
+	}
+
+	// Client-aware hooks can't be used without the clients
+	if _, err := GetHook(name); err == nil {
+		t.Error("Expected GetHook to fail for a client-aware hook")
+	}
+
+	// Hooks that don't use the clients work either way
+	hook, err = GetClientAwareHook("rollout_bucket")
+	if err != nil {
+		t.Fatalf("GetClientAwareHook() error = %v", err)
+	}
+	resource := map[string]interface{}{"metadata": map[string]interface{}{"name": "pod", "namespace": "default"}}
+	if got, want := hook(context.Background(), client, nil, resource, nil), RolloutBucket(resource, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the simple hook to be called, got %v, want %v", got, want)
+	}
+}
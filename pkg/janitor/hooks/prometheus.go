@@ -0,0 +1,166 @@
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PrometheusHookName is the name under which NewPrometheusHook is selected as
+// resource context hook
+const PrometheusHookName = "prometheus"
+
+// defaultPrometheusContextKey is the context key of the query result if none
+// is configured
+const defaultPrometheusContextKey = "request_rate"
+
+// cacheKeyPrometheusPrefix prefixes the cache keys of query results, so that
+// every query is only run once per janitor run
+const cacheKeyPrometheusPrefix = "prometheus:"
+
+// PrometheusQueryData is passed to the query template of a Prometheus hook
+type PrometheusQueryData struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// prometheusResponse is the response of the Prometheus instant query API
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// NewPrometheusHook returns a resource context hook that runs the query, a
+// Go template filled with the PrometheusQueryData of the resource, against the
+// Prometheus server at the given URL and sets _context.<key> to the value of
+// the first sample, _context.request_rate if key is empty. Query results are cached for the run. If the query fails
+// or returns no samples, the key is not set, so that rules on it don't match
+// on missing data; append "or vector(0)" to the query to treat missing series
+// as zero.
+func NewPrometheusHook(prometheusURL, query, key string) (ResourceContextHook, error) {
+	if prometheusURL == "" {
+		return nil, fmt.Errorf("Prometheus URL must be set")
+	}
+	tmpl, err := template.New("query").Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Prometheus query template: %v", err)
+	}
+
+	if key == "" {
+		key = defaultPrometheusContextKey
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	endpoint := strings.TrimSuffix(prometheusURL, "/") + "/api/v1/query"
+
+	return func(resource interface{}, cache map[string]interface{}) map[string]interface{} {
+		data, ok := queryData(resource)
+		if !ok {
+			return nil
+		}
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, data); err != nil {
+			log.Printf("Failed to render Prometheus query for %s/%s: %v", data.Namespace, data.Name, err)
+			return nil
+		}
+
+		// Failed queries are cached as well, so that an unavailable
+		// Prometheus doesn't slow down every resource of the run
+		cacheKey := cacheKeyPrometheusPrefix + rendered.String()
+		value, cached := cache[cacheKey]
+		if !cached {
+			result, err := queryPrometheus(client, endpoint, rendered.String())
+			if err != nil {
+				log.Printf("Failed to query Prometheus for %s/%s: %v", data.Namespace, data.Name, err)
+			}
+			value = result
+			cache[cacheKey] = value
+		}
+
+		if value == nil {
+			return nil
+		}
+		return map[string]interface{}{key: value}
+	}, nil
+}
+
+// queryData returns the template data of a resource given as object or as
+// unstructured map
+func queryData(resource interface{}) (PrometheusQueryData, bool) {
+	namespace, name, ok := resourceKey(resource)
+	if !ok {
+		return PrometheusQueryData{}, false
+	}
+
+	data := PrometheusQueryData{Namespace: namespace, Name: name}
+	if obj, ok := resource.(interface{ GetKind() string }); ok {
+		data.Kind = obj.GetKind()
+	} else if m, ok := resource.(map[string]interface{}); ok {
+		data.Kind, _ = m["kind"].(string)
+	}
+	return data, true
+}
+
+// queryPrometheus runs an instant query and returns the value of the first
+// sample as float64, or nil if the query returned no samples
+func queryPrometheus(client *http.Client, endpoint, query string) (interface{}, error) {
+	resp, err := client.Get(endpoint + "?" + url.Values{"query": {query}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response with status %d: %v", resp.StatusCode, err)
+	}
+	if response.Status != "success" {
+		return nil, fmt.Errorf("query failed with status %d: %s", resp.StatusCode, response.Error)
+	}
+
+	var sample []interface{}
+	switch response.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(response.Data.Result, &sample); err != nil {
+			return nil, fmt.Errorf("failed to decode scalar: %v", err)
+		}
+	case "vector":
+		var vector []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(response.Data.Result, &vector); err != nil {
+			return nil, fmt.Errorf("failed to decode vector: %v", err)
+		}
+		if len(vector) == 0 {
+			return nil, nil
+		}
+		sample = vector[0].Value
+	default:
+		return nil, fmt.Errorf("unsupported result type %q", response.Data.ResultType)
+	}
+
+	if len(sample) != 2 {
+		return nil, fmt.Errorf("invalid sample %v", sample)
+	}
+	text, ok := sample[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid sample value %v", sample[1])
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sample value %q: %v", text, err)
+	}
+	return value, nil
+}
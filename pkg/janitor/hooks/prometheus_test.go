@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dschaaff/kube-janitor/pkg/janitor"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestPrometheusHook(t *testing.T) {
+	rates := map[string]string{
+		`sum(rate(http_requests_total{namespace="default",deployment="idle"}[1h]))`: "0",
+		`sum(rate(http_requests_total{namespace="default",deployment="busy"}[1h]))`: "12.5",
+	}
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		rate, ok := rates[r.URL.Query().Get("query")]
+		if !ok {
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"%s"]}]}}`, rate)
+	}))
+	defer server.Close()
+
+	hook, err := NewPrometheusHook(server.URL,
+		`sum(rate(http_requests_total{namespace="{{.Namespace}}",deployment="{{.Name}}"}[1h]))`, "")
+	if err != nil {
+		t.Fatalf("NewPrometheusHook() error = %v", err)
+	}
+
+	rule := janitor.Rule{
+		ID:        "idle-deployments",
+		Resources: []string{"*"},
+		JMESPath:  "_context.request_rate == `0`",
+		TTL:       "1d",
+	}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("ValidateAndCompile() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		wantMatch bool
+	}{
+		{name: "idle", wantMatch: true},
+		{name: "busy"},
+		{name: "unknown"},
+	}
+
+	cache := make(map[string]interface{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &unstructured.Unstructured{}
+			deployment.SetAPIVersion("apps/v1")
+			deployment.SetKind("Deployment")
+			deployment.SetNamespace("default")
+			deployment.SetName(tt.name)
+
+			context := hook(deployment, cache)
+			if got := rule.Matches(deployment.Object, context); got != tt.wantMatch {
+				t.Errorf("Matches() = %v with context %v, want %v", got, context, tt.wantMatch)
+			}
+		})
+	}
+
+	// Query results are cached for the run
+	deployment := &unstructured.Unstructured{}
+	deployment.SetNamespace("default")
+	deployment.SetName("idle")
+	hook(deployment, cache)
+	if queries != len(tests) {
+		t.Errorf("Expected %d queries, got %d", len(tests), queries)
+	}
+}
+
+func TestNewPrometheusHookInvalid(t *testing.T) {
+	if _, err := NewPrometheusHook("", "up", ""); err == nil {
+		t.Error("Expected an error without URL")
+	}
+	if _, err := NewPrometheusHook("http://prometheus:9090", "{{.Name", ""); err == nil {
+		t.Error("Expected an error for an invalid query template")
+	}
+}
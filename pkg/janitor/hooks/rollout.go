@@ -0,0 +1,51 @@
+package hooks
+
+import (
+	"hash/fnv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// rolloutBuckets is the number of rollout buckets, so that a bucket is a
+// percentage of the resources
+const rolloutBuckets = 100
+
+// RolloutBucket is a built-in resource context hook that sets
+// _context.rollout_bucket to a bucket from 0 to 99 derived from the
+// namespace and name of the resource. Unlike RandomDice, the bucket of a
+// resource is the same in every run, so that a rule with
+// _context.rollout_bucket < 10 gradually rolls out to the same 10% of the
+// resources.
+func RolloutBucket(resource interface{}, cache map[string]interface{}) map[string]interface{} {
+	namespace, name, ok := resourceKey(resource)
+	if !ok {
+		return nil
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(namespace + "/" + name))
+	return map[string]interface{}{
+		"rollout_bucket": int(h.Sum32() % rolloutBuckets),
+	}
+}
+
+// resourceKey returns the namespace and name of a resource, given as object
+// or as unstructured map
+func resourceKey(resource interface{}) (string, string, bool) {
+	switch r := resource.(type) {
+	case metav1.Object:
+		return r.GetNamespace(), r.GetName(), true
+	case map[string]interface{}:
+		metadata, ok := r["metadata"].(map[string]interface{})
+		if !ok {
+			return "", "", false
+		}
+		name, ok := metadata["name"].(string)
+		if !ok {
+			return "", "", false
+		}
+		namespace, _ := metadata["namespace"].(string)
+		return namespace, name, true
+	}
+	return "", "", false
+}
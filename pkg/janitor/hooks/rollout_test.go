@@ -0,0 +1,66 @@
+package hooks
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRolloutBucketStable(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetNamespace("default")
+	obj.SetName("test-pod")
+	resource := map[string]interface{}{
+		"kind": "Pod",
+		"metadata": map[string]interface{}{
+			"namespace": "default",
+			"name":      "test-pod",
+		},
+	}
+
+	want := RolloutBucket(obj, make(map[string]interface{}))["rollout_bucket"]
+	bucket, ok := want.(int)
+	if !ok || bucket < 0 || bucket >= 100 {
+		t.Fatalf("Expected a bucket between 0 and 99, got %v", want)
+	}
+
+	// The bucket is the same in every run and for both representations
+	for i := 0; i < 3; i++ {
+		if got := RolloutBucket(obj, make(map[string]interface{}))["rollout_bucket"]; got != want {
+			t.Errorf("Expected bucket %v in run %d, got %v", want, i, got)
+		}
+	}
+	if got := RolloutBucket(resource, make(map[string]interface{}))["rollout_bucket"]; got != want {
+		t.Errorf("Expected bucket %v for the unstructured map, got %v", want, got)
+	}
+
+	if got := RolloutBucket("not a resource", make(map[string]interface{})); got != nil {
+		t.Errorf("Expected no context for an unknown resource, got %v", got)
+	}
+}
+
+func TestRolloutBucketDistribution(t *testing.T) {
+	const resources = 10000
+	counts := make([]int, 100)
+	for i := 0; i < resources; i++ {
+		obj := &metav1.ObjectMeta{Namespace: fmt.Sprintf("team-%d", i%7), Name: fmt.Sprintf("pod-%d", i)}
+		counts[RolloutBucket(obj, nil)["rollout_bucket"].(int)]++
+	}
+
+	// Every bucket should get about 1% of the resources
+	for bucket, count := range counts {
+		if count < 50 || count > 150 {
+			t.Errorf("Bucket %d has %d resources, expected about %d", bucket, count, resources/100)
+		}
+	}
+
+	canary := 0
+	for _, count := range counts[:10] {
+		canary += count
+	}
+	if canary < 800 || canary > 1200 {
+		t.Errorf("Expected about 10%% of the resources in buckets below 10, got %d of %d", canary, resources)
+	}
+}
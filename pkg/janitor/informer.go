@@ -0,0 +1,84 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// informerFor returns the shared informer for a resource type, starting it on first use
+func (j *Janitor) informerFor(gvr schema.GroupVersionResource) informers.GenericInformer {
+	j.informerMutex.Lock()
+	defer j.informerMutex.Unlock()
+
+	if j.informerFactory == nil {
+		j.informerFactory = dynamicinformer.NewDynamicSharedInformerFactory(j.dynamicClient, 0)
+		j.informerStopCh = make(chan struct{})
+	}
+
+	informer := j.informerFactory.ForResource(gvr)
+	// Start only starts informers that are not running yet
+	j.informerFactory.Start(j.informerStopCh)
+	return informer
+}
+
+// listCachedResources returns all resources of a type from the informer cache
+func (j *Janitor) listCachedResources(ctx context.Context, resourceType ResourceType) (resources []metav1.Object, err error) {
+	ctx, span := j.startSpan(ctx, "listCachedResources", attrKind.String(resourceType.Kind))
+	defer func() {
+		span.SetAttributes(attrCount.Int(len(resources)))
+		endSpan(span, err)
+	}()
+
+	gvr := schema.GroupVersionResource{
+		Group:    resourceType.Group,
+		Version:  resourceType.Version,
+		Resource: resourceType.Plural,
+	}
+
+	informer := j.informerFor(gvr)
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		return nil, fmt.Errorf("failed to sync informer cache for %s", resourceType.Kind)
+	}
+
+	items, err := informer.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s from informer cache: %v", resourceType.Kind, err)
+	}
+
+	for _, item := range items {
+		u, ok := item.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		// Copy the object as the cache must not be mutated
+		obj := u.DeepCopy()
+		obj.SetKind(resourceType.Kind)
+		obj.SetAPIVersion(fmt.Sprintf("%s/%s", resourceType.Group, resourceType.Version))
+		resources = append(resources, obj)
+	}
+
+	return resources, nil
+}
+
+// Close stops the informers started in watch mode and closes the audit log
+func (j *Janitor) Close() {
+	j.closeAuditLog()
+
+	j.informerMutex.Lock()
+	defer j.informerMutex.Unlock()
+
+	if j.informerFactory == nil {
+		return
+	}
+	close(j.informerStopCh)
+	j.informerFactory.Shutdown()
+	j.informerFactory = nil
+}
@@ -0,0 +1,96 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCleanupResourceTypeFromInformerCache(t *testing.T) {
+	expired := newUnstructuredPod("expired-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{
+		TTLAnnotation: "1h",
+	})
+	valid := newUnstructuredPod("valid-pod", "default", time.Now().Add(-30*time.Minute), map[string]string{
+		TTLAnnotation: "1h",
+	})
+
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsGVR: "PodList"}, expired, valid)
+	clientset := fake.NewSimpleClientset()
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			Watch:             true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			Parallelism:       1,
+		},
+		cache: make(map[string]interface{}),
+	}
+	defer j.Close()
+
+	podType := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+	counter := make(map[string]int)
+	if err := j.cleanupResourceType(context.Background(), podType, counter, make(map[string]bool)); err != nil {
+		t.Fatalf("cleanupResourceType() error = %v", err)
+	}
+
+	if counter["resources-processed"] != 2 {
+		t.Errorf("Expected 2 resources to be processed from the cache, got %d", counter["resources-processed"])
+	}
+	if counter["pods-deleted"] != 1 {
+		t.Errorf("Expected 1 pod to be deleted, got %d", counter["pods-deleted"])
+	}
+
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "expired-pod", metav1.GetOptions{}); err == nil {
+		t.Error("Expected expired-pod to be deleted")
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "valid-pod", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected valid-pod to be kept: %v", err)
+	}
+
+	// The informer path must not list namespaces from the API server
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "list" {
+			t.Errorf("Unexpected list call for %s in watch mode", action.GetResource().Resource)
+		}
+	}
+}
+
+func TestListCachedResources(t *testing.T) {
+	pod := newUnstructuredPod("test-pod", "default", time.Now(), nil)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"}, pod)
+
+	j := &Janitor{dynamicClient: dynamicClient, config: &Config{Watch: true}}
+	defer j.Close()
+
+	podType := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+	resources, err := j.listCachedResources(context.Background(), podType)
+	if err != nil {
+		t.Fatalf("listCachedResources() error = %v", err)
+	}
+
+	if len(resources) != 1 || resources[0].GetName() != "test-pod" {
+		t.Fatalf("Expected test-pod from the cache, got %v", resources)
+	}
+
+	// Objects returned must be copies so the cache is never mutated
+	resources[0].SetName("mutated")
+	resources, err = j.listCachedResources(context.Background(), podType)
+	if err != nil {
+		t.Fatalf("listCachedResources() error = %v", err)
+	}
+	if resources[0].GetName() != "test-pod" {
+		t.Errorf("Expected cached object to be unchanged, got %s", resources[0].GetName())
+	}
+}
@@ -4,64 +4,225 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 )
 
 // Janitor handles the cleanup of Kubernetes resources
 type Janitor struct {
 	client        kubernetes.Interface
 	dynamicClient dynamic.Interface
+	discovery     discovery.DiscoveryInterface
 	config        *Config
 	cache         map[string]interface{}
+	cacheMutex    sync.Mutex
 	debug         bool
 	counterMutex  sync.Mutex
+	notifier      Notifier
+	tracer        trace.Tracer
+
+	// Informers used in watch mode, started on first use
+	informerFactory dynamicinformer.DynamicSharedInformerFactory
+	informerStopCh  chan struct{}
+	informerMutex   sync.Mutex
+
+	// Decisions of the current run, recorded for the dry-run table and
+	// while planning the deletions to confirm. A planning run is a dry run
+	// that is not recorded as a run in the status and metrics.
+	decisions      []Decision
+	decisionsMutex sync.Mutex
+	planning       atomic.Bool
+
+	// Resources confirmed for deletion, nil unless deletions are limited to
+	// a confirmed plan
+	confirmed      map[string]bool
+	confirmedMutex sync.Mutex
+
+	// Per-namespace scheduling state, the empty namespace stands for
+	// cluster-scoped resources
+	lastProcessed      map[string]time.Time
+	namespaceIntervals map[string]time.Duration
+	dueNamespaces      map[string]bool
+	scheduleMutex      sync.Mutex
+
+	// Namespace labels and annotations, PodDisruptionBudgets and Services
+	// with endpoints cached for the current run
+	namespaceCache map[string]metav1.ObjectMeta
+	namespaceMutex sync.Mutex
+	pdbCache       map[string][]policyv1.PodDisruptionBudget
+	pdbMutex       sync.Mutex
+	endpointsCache map[string]map[string]bool
+	endpointsMutex sync.Mutex
+
+	// Whether the owners of resources exist, by owner, and whether
+	// namespaces are empty, cached for the current run
+	ownerCache          map[string]bool
+	ownerMutex          sync.Mutex
+	emptyNamespaces     map[string]bool
+	emptyNamespaceMutex sync.Mutex
+
+	// Resource types the janitor may not list or delete, skipped for the
+	// rest of the current run
+	forbiddenTypes map[schema.GroupVersionResource]bool
+	forbiddenMutex sync.Mutex
+
+	// When a keep event was last created by resource and skip reason, kept
+	// across runs
+	keepEvents      map[string]time.Time
+	keepEventsMutex sync.Mutex
+
+	// Consecutive delete failures by resource, kept across runs
+	deleteFailures      map[string]int
+	deleteFailuresMutex sync.Mutex
+
+	// Consecutive successful runs without deletions, only updated by CleanUp
+	runsWithoutDeletions int
+
+	// Append-only log of deletions, nil if disabled
+	auditLog      *os.File
+	auditLogMutex sync.Mutex
+
+	// Status of the latest run, served on /status, and why the janitor is
+	// not ready, served on /readyz
+	status      *RunStatus
+	notReady    error
+	statusMutex sync.Mutex
+
+	// Delete notifications due between runs by the time they are due, and
+	// by resource. Only queued while the notification scheduler is running,
+	// which it wakes up through notificationsWake.
+	notifications      notificationQueue
+	notificationIndex  map[string]*scheduledNotification
+	notificationsWake  chan struct{}
+	notificationsMutex sync.Mutex
+
+	// Whether deletions are paused in the current run
+	paused atomic.Bool
+
+	// Namespace policy loaded from the policy ConfigMap for the current run
+	policy atomic.Pointer[Policy]
+
+	// Whether the current run is the first run of --dry-run-once-then-apply,
+	// which runs as dry run, and whether that run completed
+	stagedDryRun     atomic.Bool
+	stagedDryRunDone bool
+
+	// Slots for the delete calls in flight, created on first use if
+	// MaxConcurrentDeletes is set
+	deleteSlots     chan struct{}
+	deleteSlotsOnce sync.Once
+
+	// Aggregates repeated events like the client-go event recorder does
+	eventCorrelator     *record.EventCorrelator
+	eventCorrelatorOnce sync.Once
+
+	// Caches of the resource context hooks for the current run, each with
+	// its own lock
+	hookCache       hookCache
+	clientHookCache hookCache
+
+	// Whether the apiserver is too old to serve EndpointSlices, set at startup
+	noEndpointSlices bool
+
+	// Plural resource names, cluster-scoped kinds, the resources that
+	// support delete and the resource types that can be listed, from the
+	// last discovery
+	plurals            map[schema.GroupVersionKind]string
+	clusterScopedKinds map[schema.GroupVersionKind]bool
+	deletableResources map[schema.GroupVersionResource]bool
+	listableTypes      []ResourceType
+	pluralsMutex       sync.Mutex
+
+	// Resource filters with short names resolved against the first
+	// discovery, leaving the config untouched
+	resolvedFilters  atomic.Pointer[resourceFilters]
+	resolveNamesOnce sync.Once
+}
+
+// resourceFilters are the resource names of the include and exclude filters,
+// the delete order and the included cluster resources
+type resourceFilters struct {
+	include     []string
+	exclude     []string
+	deleteOrder []string
+	cluster     []string
 }
 
 // New creates a new Janitor instance
 func New(config *Config) (*Janitor, error) {
+	restConfig, err := getRestConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create the Kubernetes client
-	client, err := getKubeClient()
+	client, err := getKubeClient(restConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Kubernetes client: %v", err)
 	}
 
-	dynamicClient, err := getDynamicClient()
+	dynamicClient, err := getDynamicClient(restConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
 	}
 
+	return NewWithClients(config, client, dynamicClient)
+}
+
+// NewWithClients creates a new Janitor instance that uses the given clients,
+// e.g. to embed the janitor in another controller or to inject fakes in tests
+func NewWithClients(config *Config, client kubernetes.Interface, dynamicClient dynamic.Interface) (*Janitor, error) {
+	notifier, err := NewNotifier(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notifier: %v", err)
+	}
+
+	auditLog, err := openAuditLog(config.AuditLog)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Janitor{
 		client:        client,
 		dynamicClient: dynamicClient,
 		config:        config,
 		cache:         make(map[string]interface{}),
 		debug:         config.Debug,
+		notifier:      notifier,
+		auditLog:      auditLog,
 	}, nil
 }
 
-// getDynamicClient creates a new dynamic client for the Kubernetes cluster
-func getDynamicClient() (dynamic.Interface, error) {
-	var config *rest.Config
-	var err error
-
+// getRestConfig creates the client config for the Kubernetes cluster,
+// impersonating the configured user if any
+func getRestConfig(config *Config) (*rest.Config, error) {
 	// Try in-cluster config first
-	config, err = rest.InClusterConfig()
+	restConfig, err := rest.InClusterConfig()
 	if err != nil {
 		// Fall back to kubeconfig
 		kubeconfigPath := os.Getenv("KUBECONFIG")
@@ -73,12 +234,33 @@ func getDynamicClient() (dynamic.Interface, error) {
 			}
 		}
 
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+		restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create config: %v (try setting KUBECONFIG environment variable)", err)
 		}
 	}
 
+	applyImpersonation(restConfig, config)
+	return restConfig, nil
+}
+
+// applyImpersonation makes the clients created from the client config act as
+// the configured user, so that the API server audit log attributes deletions
+// to it
+func applyImpersonation(restConfig *rest.Config, config *Config) {
+	if config.ImpersonateUser == "" && len(config.ImpersonateGroups) == 0 && config.ImpersonateUID == "" {
+		return
+	}
+
+	restConfig.Impersonate = rest.ImpersonationConfig{
+		UserName: config.ImpersonateUser,
+		Groups:   config.ImpersonateGroups,
+		UID:      config.ImpersonateUID,
+	}
+}
+
+// getDynamicClient creates a new dynamic client for the Kubernetes cluster
+func getDynamicClient(config *rest.Config) (dynamic.Interface, error) {
 	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
@@ -89,7 +271,7 @@ func getDynamicClient() (dynamic.Interface, error) {
 
 // sendDeleteNotification sends a notification about upcoming resource deletion
 func (j *Janitor) sendDeleteNotification(ctx context.Context, resource metav1.Object, reason string, expiryTime time.Time) error {
-	if j.config.DryRun {
+	if j.dryRun() {
 		// Use type assertion to get the kind
 		kind := "Unknown"
 		if u, ok := resource.(*unstructured.Unstructured); ok {
@@ -113,7 +295,7 @@ func (j *Janitor) sendDeleteNotification(ctx context.Context, resource metav1.Ob
 	}
 
 	// Create notification message
-	contextName := os.Getenv("CONTEXT_NAME")
+	contextName := j.config.ContextName
 	formattedTime := expiryTime.Format(time.RFC3339)
 
 	// Get kind using type assertion
@@ -134,27 +316,109 @@ func (j *Janitor) sendDeleteNotification(ctx context.Context, resource metav1.Ob
 		resource.GetName(),
 		formattedTime,
 		reason)
+	message = j.withAnnotatedReason(resource, message)
 
 	// Create event
 	if err := j.createEvent(ctx, resource, message, "DeleteNotification"); err != nil {
 		return err
 	}
 
-	// Send webhook notification
-	if err := SendWebhookNotification(message); err != nil {
+	// Send notification to the configured backends, falling back to the webhook
+	if j.notifier != nil {
+		notification := WebhookMessage{
+			Message:   message,
+			Cluster:   contextName,
+			Kind:      kind,
+			Namespace: resource.GetNamespace(),
+			Name:      resource.GetName(),
+		}
+		if err := j.notifier.Send(notification); err != nil {
+			log.Printf("Failed to send notification: %v", err)
+		}
+	} else if err := SendWebhookNotification(j.config, message); err != nil {
 		log.Printf("Failed to send webhook notification: %v", err)
 	}
 
-	// Add notification flag
+	// Add notification flag and persist it so the notification is only sent once
 	if annotations == nil {
 		annotations = make(map[string]string)
 	}
 	annotations[NotifiedAnnotation] = "yes"
 	resource.SetAnnotations(annotations)
 
+	if err := j.persistNotifiedAnnotation(ctx, resource); err != nil {
+		log.Printf("Failed to persist %s annotation on %s %s/%s: %v",
+			NotifiedAnnotation, kind, resource.GetNamespace(), resource.GetName(), err)
+	}
+
 	return nil
 }
 
+// notifyBeforeDeletion sends a delete notification if the expiry time is within
+// the given lead time and the resource was not notified before, and reports
+// whether it was sent. A lead time of 0 disables the notification.
+func (j *Janitor) notifyBeforeDeletion(ctx context.Context, obj metav1.Object, reason string, expiryTime time.Time, lead time.Duration) (bool, error) {
+	if lead <= 0 {
+		return false, nil
+	}
+
+	notificationTime := expiryTime.Add(-lead)
+	j.debugLog("Resource %s/%s notification time: %s", obj.GetNamespace(), obj.GetName(), notificationTime)
+	if j.wasNotified(obj) {
+		return false, nil
+	}
+	if time.Now().Before(notificationTime) {
+		// Notifications due before the next run are sent by the scheduler
+		j.scheduleNotification(obj, reason, expiryTime, notificationTime)
+		return false, nil
+	}
+	j.unscheduleNotification(obj)
+
+	j.infoLog("Sending delete notification for resource %s/%s (%s)", obj.GetNamespace(), obj.GetName(), reason)
+	if err := j.sendDeleteNotification(ctx, obj, reason, expiryTime); err != nil {
+		return false, fmt.Errorf("failed to send delete notification: %v", err)
+	}
+
+	return true, nil
+}
+
+// notificationLead returns the configured lead time of delete notifications
+func (j *Janitor) notificationLead() time.Duration {
+	return time.Duration(j.config.DeleteNotification) * time.Second
+}
+
+// persistNotifiedAnnotation patches the notified annotation onto the resource in the cluster
+func (j *Janitor) persistNotifiedAnnotation(ctx context.Context, obj metav1.Object) error {
+	return j.patchAnnotation(ctx, obj, NotifiedAnnotation, "yes")
+}
+
+// patchAnnotation sets an annotation on the resource in the cluster
+func (j *Janitor) patchAnnotation(ctx context.Context, obj metav1.Object, key, value string) error {
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, key, value))
+	gvr := j.gvrFor(obj)
+
+	var err error
+	if obj.GetNamespace() != "" {
+		_, err = j.dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	} else {
+		_, err = j.dynamicClient.Resource(gvr).Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	return err
+}
+
+// dryRun checks if the current run must not change anything, because of
+// --dry-run, because it is the first run of --dry-run-once-then-apply or
+// because it plans the deletions to confirm
+func (j *Janitor) dryRun() bool {
+	return j.config.DryRun || j.stagedDryRun.Load() || j.planning.Load()
+}
+
+// dryRunServer checks if deletions of the current run are sent as
+// server-side dry run. A planning run only logs them.
+func (j *Janitor) dryRunServer() bool {
+	return j.config.DryRunServer && !j.planning.Load()
+}
+
 // debugLog logs a message if debug mode is enabled
 func (j *Janitor) debugLog(format string, args ...interface{}) {
 	if j.debug {
@@ -169,116 +433,356 @@ func (j *Janitor) infoLog(format string, args ...interface{}) {
 	}
 }
 
-// CleanUp performs one cleanup run
-func (j *Janitor) CleanUp(ctx context.Context) error {
+// CleanUp performs one cleanup run and returns its result
+func (j *Janitor) CleanUp(ctx context.Context) (result *CleanupResult, err error) {
+	ctx, span := j.startSpan(ctx, "CleanUp")
+	defer func() { endSpan(span, err) }()
+
+	start := time.Now()
+	defer func() {
+		if j.planning.Load() {
+			return
+		}
+		j.recordStatus(start, result, err)
+		if err == nil {
+			j.trackQuietRun(result)
+		}
+	}()
+
+	if j.config.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.config.RunTimeout)
+		defer cancel()
+	}
+
 	j.debugLog("Starting cleanup run")
 
-	resourceTypes, err := GetResourceTypes(j.client)
+	resourceTypes, err := j.getResourceTypes()
 	if err != nil {
-		return fmt.Errorf("failed to get resource types: %v", err)
+		return nil, fmt.Errorf("failed to get resource types: %v", err)
 	}
 
 	j.debugLog("Found %d resource types", len(resourceTypes))
+	if err := j.checkResourceTypeCount(resourceTypes); err != nil {
+		return nil, err
+	}
+	j.resolveResourceNames(resourceTypes)
+	j.sortByDeleteOrder(resourceTypes)
+
+	if err := j.planRun(ctx, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to plan cleanup run: %v", err)
+	}
+
+	j.updatePaused(ctx)
+	j.updatePolicy(ctx)
+	j.pruneKeepEvents(time.Now())
+
+	// The first run of a staged rollout only logs what it would delete
+	staged := j.config.DryRunOnceThenApply && !j.stagedDryRunDone && !j.planning.Load()
+	j.stagedDryRun.Store(staged)
+	if staged {
+		log.Printf("First run of a staged rollout, running as dry run")
+	}
 
 	// Create maps for tracking
 	counter := make(map[string]int)
 	alreadySeen := make(map[string]bool)
 
+	j.decisionsMutex.Lock()
+	j.decisions = nil
+	j.decisionsMutex.Unlock()
+
+	j.pdbMutex.Lock()
+	j.pdbCache = nil
+	j.pdbMutex.Unlock()
+
+	j.endpointsMutex.Lock()
+	j.endpointsCache = nil
+	j.endpointsMutex.Unlock()
+
+	j.ownerMutex.Lock()
+	j.ownerCache = nil
+	j.ownerMutex.Unlock()
+
+	j.emptyNamespaceMutex.Lock()
+	j.emptyNamespaces = nil
+	j.emptyNamespaceMutex.Unlock()
+
+	j.resetForbidden()
+
+	// Resource context hooks cache their data for the current run
+	j.cacheMutex.Lock()
+	j.cache = make(map[string]interface{})
+	j.cacheMutex.Unlock()
+	j.hookCache.reset()
+	j.clientHookCache.reset()
+
 	// First handle namespaces if included
 	j.debugLog("Processing namespaces")
 	if err := j.cleanupNamespaces(ctx, counter); err != nil {
-		return fmt.Errorf("failed to cleanup namespaces: %v", err)
+		return nil, fmt.Errorf("failed to cleanup namespaces: %v", err)
 	}
 
 	// Then handle other resources
 	for _, resourceType := range resourceTypes {
+		if ctx.Err() != nil {
+			break
+		}
 		j.debugLog("Processing resource type: %s", resourceType.Kind)
 		if err := j.cleanupResourceType(ctx, resourceType, counter, alreadySeen); err != nil {
 			log.Printf("Error cleaning up resource type %s: %v", resourceType.Kind, err)
+			j.countError(counter)
 			continue
 		}
 	}
 
 	j.logCleanupSummary(counter)
+	if j.config.DryRunTable {
+		if err := j.writeDecisionTable(os.Stdout); err != nil {
+			log.Printf("Failed to write dry-run table: %v", err)
+		}
+	}
+	span.SetAttributes(attrCount.Int(counter[processedCounter]))
+
+	if ctx.Err() == context.DeadlineExceeded {
+		log.Printf("Warning: cleanup run exceeded the run timeout of %v and was cancelled", j.config.RunTimeout)
+		return newCleanupResult(counter), fmt.Errorf("cleanup run timed out after %v", j.config.RunTimeout)
+	}
+
 	j.debugLog("Cleanup run completed")
-	return nil
+	result = newCleanupResult(counter)
+	if staged {
+		j.stagedDryRunDone = true
+		j.stagedDryRun.Store(false)
+
+		// The next run applies the plan, so it processes the same namespaces
+		j.scheduleMutex.Lock()
+		j.lastProcessed = nil
+		j.dueNamespaces = nil
+		j.scheduleMutex.Unlock()
+
+		planned := 0
+		for _, deleted := range result.Deleted {
+			planned += deleted
+		}
+		log.Printf("Staged rollout: the dry run would have deleted %d resources, resources are deleted from the next run on", planned)
+	}
+	return result, nil
+}
+
+// resolveResourceNames resolves short names in the resource filters against
+// the discovered resource types. The names are resolved only once, against
+// the first discovery, so that unknown names are only reported once.
+func (j *Janitor) resolveResourceNames(resourceTypes []ResourceType) {
+	j.resolveNamesOnce.Do(func() {
+		include, unknown := ResolveShortNames(j.config.IncludeResources, resourceTypes)
+		for _, name := range unknown {
+			log.Printf("Warning: unknown resource %q in include resources", name)
+		}
+
+		// Unknown excluded resources are harmless, e.g. the default endpoints
+		// exclusion when discovery dropped the deprecated endpoints API
+		exclude, unknown := ResolveShortNames(j.config.ExcludeResources, resourceTypes)
+		for _, name := range unknown {
+			j.debugLog("Unknown resource %q in exclude resources", name)
+		}
+
+		deleteOrder, unknown := ResolveShortNames(j.config.DeleteOrder, resourceTypes)
+		for _, name := range unknown {
+			j.debugLog("Unknown resource %q in delete order", name)
+		}
+
+		cluster, unknown := ResolveShortNames(j.config.ClusterResources, resourceTypes)
+		for _, name := range unknown {
+			log.Printf("Warning: unknown resource %q in include cluster resources", name)
+		}
+
+		j.resolvedFilters.Store(&resourceFilters{
+			include:     include,
+			exclude:     exclude,
+			deleteOrder: deleteOrder,
+			cluster:     cluster,
+		})
+	})
+}
+
+// resourceFilters returns the resource filters with short names resolved,
+// or the configured ones if the names were not resolved yet
+func (j *Janitor) resourceFilters() *resourceFilters {
+	if filters := j.resolvedFilters.Load(); filters != nil {
+		return filters
+	}
+	return &resourceFilters{
+		include:     j.config.IncludeResources,
+		exclude:     j.config.ExcludeResources,
+		deleteOrder: j.config.DeleteOrder,
+		cluster:     j.config.ClusterResources,
+	}
 }
 
 // cleanupResourceType handles cleanup for a specific resource type
-func (j *Janitor) cleanupResourceType(ctx context.Context, resourceType ResourceType, counter map[string]int, alreadySeen map[string]bool) error {
+func (j *Janitor) cleanupResourceType(ctx context.Context, resourceType ResourceType, counter map[string]int, alreadySeen map[string]bool) (err error) {
 	// Skip if resource type is excluded
 	if !j.shouldProcessResourceType(resourceType) {
 		j.debugLog("Skipping excluded resource type: %s", resourceType.Kind)
 		return nil
 	}
 
-	j.debugLog("Getting namespaces for resource type: %s", resourceType.Kind)
-	// Get all namespaces
-	namespaces, err := j.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to list namespaces: %v", err)
+	ctx, span := j.startSpan(ctx, "cleanupResourceType", attrKind.String(resourceType.Kind))
+	defer func() { endSpan(span, err) }()
+
+	// In watch mode resources are served from the informer cache instead of listing them
+	if j.config.Watch {
+		if !resourceType.Namespaced && !j.includesClusterResource(resourceType.Plural, resourceType.Group) {
+			return nil
+		}
+
+		cached, err := j.listCachedResources(ctx, resourceType)
+		if err != nil {
+			return err
+		}
+		j.debugLog("Found %d cached resources of type %s", len(cached), resourceType.Kind)
+
+		resetManagedResources(resourceType.Kind)
+		for namespace, count := range j.countManagedResources(cached) {
+			observeManagedResources(resourceType.Kind, namespace, count)
+		}
+
+		var resources []metav1.Object
+		for _, obj := range cached {
+			if j.isDue(obj.GetNamespace()) {
+				resources = append(resources, obj)
+			}
+		}
+
+		span.SetAttributes(attrCount.Int(len(resources)))
+		j.processResourcesInParallel(ctx, resources, counter, alreadySeen)
+		return nil
 	}
 
 	// Process namespaced resources
 	if resourceType.Namespaced {
-		j.debugLog("Processing namespaced resources for type: %s", resourceType.Kind)
+		j.debugLog("Getting namespaces for resource type: %s", resourceType.Kind)
+		namespaces, err := j.namespaceNames(ctx)
+		if err != nil {
+			return err
+		}
 
-		// Collect all resources from all namespaces first
-		var allResources []metav1.Object
-		var resourcesMutex sync.Mutex
+		j.debugLog("Processing namespaced resources for type: %s", resourceType.Kind)
 
-		for _, ns := range namespaces.Items {
-			// Skip excluded namespaces
-			if !j.shouldProcessNamespace(ns.Name) {
-				j.debugLog("Skipping excluded namespace: %s", ns.Name)
-				continue
-			}
+		// Resources are processed while the remaining namespaces are listed,
+		// so that only the resources of a single namespace are held at once
+		sent := 0
+		j.processResourceStream(ctx, func(send func(metav1.Object) bool) {
+			for _, ns := range namespaces {
+				if ctx.Err() != nil {
+					return
+				}
 
-			j.debugLog("Listing resources of type %s in namespace %s", resourceType.Kind, ns.Name)
-			resources, err := j.listNamespacedResources(ctx, resourceType, ns.Name)
-			if err != nil {
-				log.Printf("Error listing %s in namespace %s: %v", resourceType.Kind, ns.Name, err)
-				continue
-			}
-			j.debugLog("Found %d resources of type %s in namespace %s", len(resources), resourceType.Kind, ns.Name)
+				// Skip excluded namespaces
+				if !j.shouldProcessNamespace(ns) {
+					j.debugLog("Skipping excluded namespace: %s", ns)
+					continue
+				}
+				if !j.isDue(ns) {
+					j.debugLog("Skipping namespace %s, not due for processing", ns)
+					continue
+				}
 
-			resourcesMutex.Lock()
-			allResources = append(allResources, resources...)
-			resourcesMutex.Unlock()
-		}
+				j.debugLog("Listing resources of type %s in namespace %s", resourceType.Kind, ns)
+				resources, err := j.listNamespacedResources(ctx, resourceType, ns)
+				if errors.Is(err, errTypeForbidden) {
+					return
+				}
+				if err != nil {
+					log.Printf("Error listing %s in namespace %s: %v", resourceType.Kind, ns, err)
+					j.countError(counter)
+					continue
+				}
+				j.debugLog("Found %d resources of type %s in namespace %s", len(resources), resourceType.Kind, ns)
+				observeManagedResources(resourceType.Kind, ns, j.countManagedResources(resources)[ns])
 
-		// Process resources in parallel
-		j.processResourcesInParallel(ctx, allResources, counter, alreadySeen)
+				for _, resource := range resources {
+					if !send(resource) {
+						return
+					}
+					sent++
+				}
+			}
+		}, counter, alreadySeen)
+		span.SetAttributes(attrCount.Int(sent))
 
-	} else if j.config.IncludeClusterResources {
+	} else if j.includesClusterResource(resourceType.Plural, resourceType.Group) && j.isDue("") {
 		// Process cluster-scoped resources if enabled
 		j.debugLog("Processing cluster-scoped resources for type: %s", resourceType.Kind)
 		resources, err := j.listClusterResources(ctx, resourceType)
+		if errors.Is(err, errTypeForbidden) {
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("failed to list cluster-scoped %s: %v", resourceType.Kind, err)
 		}
 		j.debugLog("Found %d cluster-scoped resources of type %s", len(resources), resourceType.Kind)
+		observeManagedResources(resourceType.Kind, "", j.countManagedResources(resources)[""])
 
 		// Process resources in parallel
+		span.SetAttributes(attrCount.Int(len(resources)))
 		j.processResourcesInParallel(ctx, resources, counter, alreadySeen)
 	}
 
 	return nil
 }
 
+// countManagedResources counts the resources that match the filters by
+// namespace
+func (j *Janitor) countManagedResources(resources []metav1.Object) map[string]int {
+	counts := make(map[string]int)
+	for _, obj := range resources {
+		if j.filterSkipReason(obj) == "" {
+			counts[obj.GetNamespace()]++
+		}
+	}
+	return counts
+}
+
+// sortByDeleteOrder moves the resource types of the delete order to the
+// front, in that order, so that e.g. the Pods of a namespace are deleted
+// before the PVCs they mount and the PVCs before their PVs. Resource types
+// are cleaned up one after the other, so the order holds within every
+// namespace. The other resource types keep their order.
+func (j *Janitor) sortByDeleteOrder(resourceTypes []ResourceType) {
+	deleteOrder := j.resourceFilters().deleteOrder
+	rank := func(rt ResourceType) int {
+		for i, name := range deleteOrder {
+			if matchesResourceName(name, rt.Plural, rt.Group) {
+				return i
+			}
+		}
+		return len(deleteOrder)
+	}
+	sort.SliceStable(resourceTypes, func(a, b int) bool {
+		return rank(resourceTypes[a]) < rank(resourceTypes[b])
+	})
+}
+
 // shouldProcessResourceType checks if a resource type should be processed
 func (j *Janitor) shouldProcessResourceType(resourceType ResourceType) bool {
+	if !j.shouldProcessGroup(resourceType.Group) {
+		return false
+	}
+
 	// Skip if resource type is explicitly excluded
-	for _, excluded := range j.config.ExcludeResources {
-		if excluded == resourceType.Plural {
+	filters := j.resourceFilters()
+	for _, excluded := range filters.exclude {
+		if matchesResourceName(excluded, resourceType.Plural, resourceType.Group) {
 			j.debugLog("Resource type %s is in exclude list", resourceType.Plural)
 			return false
 		}
 	}
 
 	// Check if resource type is included
-	for _, included := range j.config.IncludeResources {
-		if included == "all" || included == resourceType.Plural {
+	for _, included := range filters.include {
+		if included == "all" || matchesResourceName(included, resourceType.Plural, resourceType.Group) {
 			j.debugLog("Resource type %s is included for processing", resourceType.Plural)
 			return true
 		}
@@ -288,8 +792,63 @@ func (j *Janitor) shouldProcessResourceType(resourceType ResourceType) bool {
 	return false
 }
 
+// shouldProcessGroup checks if resources of an API group should be processed
+func (j *Janitor) shouldProcessGroup(group string) bool {
+	if j.config.CustomResourcesOnly && isBuiltinGroup(group) {
+		j.debugLog("API group %q is built-in, only processing custom resources", group)
+		return false
+	}
+	if j.config.BuiltinResourcesOnly && !isBuiltinGroup(group) {
+		j.debugLog("API group %q is not built-in, only processing built-in resources", group)
+		return false
+	}
+
+	for _, excluded := range j.config.ExcludeGroups {
+		if matchesGroupName(excluded, group) {
+			j.debugLog("API group %q is in exclude list", group)
+			return false
+		}
+	}
+
+	// No include list means all groups are included
+	if len(j.config.IncludeGroups) == 0 {
+		return true
+	}
+	for _, included := range j.config.IncludeGroups {
+		if included == "all" || matchesGroupName(included, group) {
+			return true
+		}
+	}
+
+	j.debugLog("API group %q is not included for processing", group)
+	return false
+}
+
+// namespaceNames returns the names of the namespaces to look for resources
+// in. Only the configured namespace is returned when a single namespace is
+// processed, so that the janitor doesn't need to list namespaces then.
+func (j *Janitor) namespaceNames(ctx context.Context) ([]string, error) {
+	if j.config.Namespace != "" {
+		return []string{j.config.Namespace}, nil
+	}
+
+	namespaces, err := j.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	names := make([]string, 0, len(namespaces.Items))
+	for _, ns := range namespaces.Items {
+		names = append(names, ns.Name)
+	}
+	return names, nil
+}
+
 // shouldProcessNamespace checks if a namespace should be processed
 func (j *Janitor) shouldProcessNamespace(namespace string) bool {
+	if j.config.Namespace != "" {
+		return namespace == j.config.Namespace
+	}
+
 	// Skip if namespace is explicitly excluded
 	for _, excluded := range j.config.ExcludeNamespaces {
 		if excluded == namespace {
@@ -310,19 +869,38 @@ func (j *Janitor) shouldProcessNamespace(namespace string) bool {
 	return false
 }
 
-func (j *Janitor) listNamespacedResources(ctx context.Context, resourceType ResourceType, namespace string) ([]metav1.Object, error) {
+func (j *Janitor) listNamespacedResources(ctx context.Context, resourceType ResourceType, namespace string) (resources []metav1.Object, err error) {
+	ctx, span := j.startSpan(ctx, "listNamespacedResources",
+		attrKind.String(resourceType.Kind), attrNamespace.String(namespace))
+	defer func() {
+		span.SetAttributes(attrCount.Int(len(resources)))
+		endSpan(span, err)
+	}()
+
 	gvr := schema.GroupVersionResource{
 		Group:    resourceType.Group,
 		Version:  resourceType.Version,
 		Resource: resourceType.Plural,
 	}
 
-	list, err := j.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if j.isForbidden(gvr) {
+		return nil, errTypeForbidden
+	}
+	list, err := j.listWithRetry(ctx, resourceType, namespace, func() (*unstructured.UnstructuredList, error) {
+		return j.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	})
+	if apierrors.IsNotFound(err) {
+		j.debugLog("Namespace %s no longer exists, skipping %s", namespace, resourceType.Kind)
+		return nil, nil
+	}
+	if apierrors.IsForbidden(err) {
+		j.markForbidden(gvr, "list", err)
+		return nil, errTypeForbidden
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to list %s in namespace %s: %v", resourceType.Kind, namespace, err)
 	}
 
-	var resources []metav1.Object
 	for _, item := range list.Items {
 		// Convert unstructured.Unstructured to metav1.Object
 		obj := item.DeepCopy()
@@ -334,19 +912,33 @@ func (j *Janitor) listNamespacedResources(ctx context.Context, resourceType Reso
 	return resources, nil
 }
 
-func (j *Janitor) listClusterResources(ctx context.Context, resourceType ResourceType) ([]metav1.Object, error) {
+func (j *Janitor) listClusterResources(ctx context.Context, resourceType ResourceType) (resources []metav1.Object, err error) {
+	ctx, span := j.startSpan(ctx, "listClusterResources", attrKind.String(resourceType.Kind))
+	defer func() {
+		span.SetAttributes(attrCount.Int(len(resources)))
+		endSpan(span, err)
+	}()
+
 	gvr := schema.GroupVersionResource{
 		Group:    resourceType.Group,
 		Version:  resourceType.Version,
 		Resource: resourceType.Plural,
 	}
 
-	list, err := j.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+	if j.isForbidden(gvr) {
+		return nil, errTypeForbidden
+	}
+	list, err := j.listWithRetry(ctx, resourceType, "", func() (*unstructured.UnstructuredList, error) {
+		return j.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+	})
+	if apierrors.IsForbidden(err) {
+		j.markForbidden(gvr, "list", err)
+		return nil, errTypeForbidden
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to list cluster-scoped %s: %v", resourceType.Kind, err)
 	}
 
-	var resources []metav1.Object
 	for _, item := range list.Items {
 		// Convert unstructured.Unstructured to metav1.Object
 		obj := item.DeepCopy()
@@ -358,30 +950,50 @@ func (j *Janitor) listClusterResources(ctx context.Context, resourceType Resourc
 	return resources, nil
 }
 
-// getKubeClient creates a new Kubernetes client
-func getKubeClient() (kubernetes.Interface, error) {
-	var config *rest.Config
-	var err error
+// Number of attempts to list resources and the backoff before the first retry,
+// doubled for every further retry
+var (
+	listAttempts     = 3
+	listRetryBackoff = time.Second
+)
 
-	// Try in-cluster config first
-	config, err = rest.InClusterConfig()
-	if err != nil {
-		// Fall back to kubeconfig
-		kubeconfigPath := os.Getenv("KUBECONFIG")
-		if kubeconfigPath == "" {
-			// If KUBECONFIG is not set, use default location
-			homeDir, err := os.UserHomeDir()
-			if err == nil {
-				kubeconfigPath = filepath.Join(homeDir, ".kube", "config")
-			}
+// listWithRetry calls list until it succeeds, retrying transient failures
+// with exponential backoff so that a single failed request doesn't skip the
+// resources for a whole interval. If all attempts fail, the failure is counted
+// in the list failures metric. The empty namespace stands for cluster-scoped
+// resources.
+func (j *Janitor) listWithRetry(ctx context.Context, resourceType ResourceType, namespace string, list func() (*unstructured.UnstructuredList, error)) (*unstructured.UnstructuredList, error) {
+	backoff := listRetryBackoff
+	for attempt := 1; ; attempt++ {
+		result, err := list()
+		if err == nil {
+			return result, nil
+		}
+		// Retrying won't bring back a deleted namespace or grant permissions
+		if apierrors.IsNotFound(err) || apierrors.IsForbidden(err) {
+			return nil, err
+		}
+		if attempt >= listAttempts || ctx.Err() != nil {
+			listFailures.WithLabelValues(resourceType.Kind, namespace).Inc()
+			return nil, err
 		}
 
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create config: %v (try setting KUBECONFIG environment variable)", err)
+		j.debugLog("Failed to list %s in namespace %q (attempt %d/%d), retrying in %v: %v",
+			resourceType.Kind, namespace, attempt, listAttempts, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			listFailures.WithLabelValues(resourceType.Kind, namespace).Inc()
+			return nil, err
 		}
+		backoff *= 2
 	}
+}
 
+// getKubeClient creates a new Kubernetes client
+func getKubeClient(config *rest.Config) (kubernetes.Interface, error) {
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %v", err)
@@ -390,9 +1002,13 @@ func getKubeClient() (kubernetes.Interface, error) {
 	return clientset, nil
 }
 
-// createEvent creates a Kubernetes event for the given resource
+// createEvent creates a Kubernetes event for the given resource. Like the
+// client-go event recorder, repeated events of a resource are aggregated into
+// a single event with an increasing count, and events of a resource that
+// floods them are dropped. Events are created synchronously, so that failing
+// to create one stops the deletion it announces.
 func (j *Janitor) createEvent(ctx context.Context, resource metav1.Object, message string, reason string) error {
-	if j.config.DryRun {
+	if j.dryRun() {
 		log.Printf("**DRY-RUN**: Would create event: %s", message)
 		return nil
 	}
@@ -412,11 +1028,17 @@ func (j *Janitor) createEvent(ctx context.Context, resource metav1.Object, messa
 		eventNamespace = "default"
 	}
 
+	var annotations map[string]string
+	if j.config.ContextName != "" {
+		annotations = map[string]string{ClusterAnnotation: j.config.ContextName}
+	}
+
 	now := time.Now()
 	event := &corev1.Event{
 		ObjectMeta: metav1.ObjectMeta{
-			GenerateName: "kube-janitor-",
-			Namespace:    eventNamespace,
+			Name:        fmt.Sprintf("%s.%x", resource.GetName(), now.UnixNano()),
+			Namespace:   eventNamespace,
+			Annotations: annotations,
 		},
 		InvolvedObject: corev1.ObjectReference{
 			APIVersion: apiVersion,
@@ -436,11 +1058,43 @@ func (j *Janitor) createEvent(ctx context.Context, resource metav1.Object, messa
 		},
 	}
 
-	_, err := j.client.CoreV1().Events(eventNamespace).Create(ctx, event, metav1.CreateOptions{})
+	j.eventCorrelatorOnce.Do(func() {
+		j.eventCorrelator = record.NewEventCorrelatorWithOptions(record.CorrelatorOptions{})
+	})
+	result, err := j.eventCorrelator.EventCorrelate(event)
+	if err != nil {
+		return fmt.Errorf("failed to correlate event: %v", err)
+	}
+	if result.Skip {
+		j.debugLog("Dropping event %s for %s/%s, too many events for the resource", reason, resource.GetNamespace(), resource.GetName())
+		return nil
+	}
+	event = result.Event
+
+	events := j.client.CoreV1().Events(eventNamespace)
+	var recorded *corev1.Event
+	if event.Count > 1 {
+		recorded, err = events.Patch(ctx, event.Name, types.StrategicMergePatchType, result.Patch, metav1.PatchOptions{})
+	}
+	// The aggregated event is created again if it was removed in the meantime
+	if event.Count <= 1 || apierrors.IsNotFound(err) {
+		event.ResourceVersion = ""
+		recorded, err = events.Create(ctx, event, metav1.CreateOptions{})
+	}
+	if apierrors.IsNotFound(err) {
+		// The namespace was deleted since the resource was listed
+		j.debugLog("Not creating event in namespace %s, it no longer exists", eventNamespace)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create event: %v", err)
 	}
 
+	// Remember the event as stored, so that the next repeat patches it
+	if recorded == nil {
+		recorded = event
+	}
+	j.eventCorrelator.UpdateState(recorded)
 	return nil
 }
 
@@ -467,7 +1121,17 @@ func (j *Janitor) handleExpiry(ctx context.Context, obj metav1.Object, counter m
 		kind = u.GetKind()
 	}
 
+	source := fmt.Sprintf("annotation %s=%s", ExpiryAnnotation, expiry)
 	if time.Now().After(expiryTime) {
+		pending, err := j.deletionPending(ctx, obj, counter, source)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return nil
+		}
+		reason := fmt.Sprintf("expired on %s", expiryTime.Format(time.RFC3339))
+		j.recordDecision(obj, source, DecisionDelete, reason)
 		message := fmt.Sprintf("%s %s/%s expired on %s and will be deleted (annotation %s is set)",
 			kind,
 			obj.GetNamespace(),
@@ -475,24 +1139,24 @@ func (j *Janitor) handleExpiry(ctx context.Context, obj metav1.Object, counter m
 			expiry,
 			ExpiryAnnotation)
 
-		if err := j.createEvent(ctx, obj, message, "ExpiryTimeReached"); err != nil {
+		if err := j.createEvent(ctx, obj, j.withAnnotatedReason(obj, message), "ExpiryTimeReached"); err != nil {
 			return fmt.Errorf("failed to create event: %v", err)
 		}
 
-		if err := j.deleteResource(ctx, obj); err != nil {
+		if err := j.deleteResource(ctx, obj, source+": "+reason, ""); j.deleteSkipped(err, counter) {
+			return nil
+		} else if err != nil {
 			return fmt.Errorf("failed to delete resource: %v", err)
 		}
 
 		j.counterMutex.Lock()
 		defer j.counterMutex.Unlock()
-		resourceType := fmt.Sprintf("%ss", strings.ToLower(kind))
-		counter[resourceType+"-deleted"]++
-	} else if j.config.DeleteNotification > 0 {
-		notificationTime := expiryTime.Add(-time.Duration(j.config.DeleteNotification) * time.Second)
-		if time.Now().After(notificationTime) && !j.wasNotified(obj) {
-			if err := j.sendDeleteNotification(ctx, obj, fmt.Sprintf("annotation %s is set", ExpiryAnnotation), expiryTime); err != nil {
-				return fmt.Errorf("failed to send delete notification: %v", err)
-			}
+		counter[j.counterName(obj)+deletedCounterSuffix]++
+	} else {
+		j.skipResource(ctx, obj, counter, SkipReasonNotExpired, source, fmt.Sprintf("expires on %s", expiryTime.Format(time.RFC3339)))
+		observeTimeToExpiry(obj, expiryTime)
+		if _, err := j.notifyBeforeDeletion(ctx, obj, fmt.Sprintf("annotation %s is set", ExpiryAnnotation), expiryTime, j.notificationLead()); err != nil {
+			return err
 		}
 	}
 
@@ -501,9 +1165,11 @@ func (j *Janitor) handleExpiry(ctx context.Context, obj metav1.Object, counter m
 
 // wasNotified checks if a delete notification was already sent
 
-// SendWebhookNotification sends a notification to a webhook
-func SendWebhookNotification(message string) error {
-	webhookURL := os.Getenv("WEBHOOK_URL")
+// SendWebhookNotification sends a notification to the webhook URL of the
+// config, falling back to the WEBHOOK_URL environment variable if the config
+// is nil or has no URL
+func SendWebhookNotification(config *Config, message string) error {
+	webhookURL := config.webhookURL()
 	if webhookURL == "" {
 		return nil
 	}
@@ -511,6 +1177,9 @@ func SendWebhookNotification(message string) error {
 	payload := WebhookMessage{
 		Message: message,
 	}
+	if config != nil {
+		payload.Cluster = config.ContextName
+	}
 
 	data, err := json.Marshal(payload)
 	if err != nil {
@@ -532,14 +1201,8 @@ func SendWebhookNotification(message string) error {
 
 // handleTTL processes a resource's TTL annotation or matching rules
 func (j *Janitor) handleTTL(ctx context.Context, obj metav1.Object, counter map[string]int) error {
-	annotations := obj.GetAnnotations()
-	if annotations == nil {
-		j.debugLog("Resource %s/%s has no annotations", obj.GetNamespace(), obj.GetName())
-		return nil
-	}
-
 	// Check for TTL annotation
-	ttl, hasTTL := annotations[TTLAnnotation]
+	ttl, hasTTL := obj.GetAnnotations()[TTLAnnotation]
 	if !hasTTL {
 		j.debugLog("Resource %s/%s has no TTL annotation, checking rules", obj.GetNamespace(), obj.GetName())
 		// No TTL annotation, check if any rules match
@@ -547,87 +1210,225 @@ func (j *Janitor) handleTTL(ctx context.Context, obj metav1.Object, counter map[
 	}
 
 	j.infoLog("Resource %s/%s has TTL annotation: %s", obj.GetNamespace(), obj.GetName(), ttl)
+	return j.applyTTL(ctx, obj, counter, ttl, "TTL", fmt.Sprintf("annotation %s=%s", TTLAnnotation, ttl))
+}
 
+// applyTTL deletes a resource if the given TTL has expired, or sends a delete
+// notification if it is about to expire. The label describes the TTL in
+// messages, e.g. "TTL" or "namespace TTL", and the source is recorded as
+// the origin of the decision.
+func (j *Janitor) applyTTL(ctx context.Context, obj metav1.Object, counter map[string]int, ttl, label, source string) error {
 	// Parse TTL
 	ttlDuration, err := ParseTTL(ttl)
 	if err != nil {
-		return fmt.Errorf("invalid TTL value: %v", err)
+		return fmt.Errorf("invalid %s value: %v", label, err)
+	}
+
+	// Apply the max TTL cap, if configured
+	if clamped, ok := j.clampTTL(obj, ttlDuration); ok {
+		ttlDuration = clamped
+		ttl = FormatDuration(clamped)
 	}
 
 	// TTL of -1 means "forever", so skip
 	if ttlDuration < 0 {
 		j.debugLog("Resource %s/%s has unlimited TTL, skipping", obj.GetNamespace(), obj.GetName())
+		j.skipResource(ctx, obj, counter, SkipReasonUnlimitedTTL, source, "unlimited TTL")
 		return nil
 	}
 
-	// Get deployment time
-	var deploymentTime time.Time
-	if j.config.DeploymentTimeAnnotation != "" {
-		if deployTimeStr, ok := annotations[j.config.DeploymentTimeAnnotation]; ok {
-			if t, err := time.Parse(time.RFC3339, deployTimeStr); err == nil {
-				deploymentTime = t
-				j.debugLog("Using deployment time from annotation: %s", deploymentTime)
-			}
-		}
-	}
-
-	// If no deployment time annotation or couldn't parse it, use creation timestamp
+	// Calculate expiry time
+	deploymentTime := j.ttlBaseTime(obj, "")
 	if deploymentTime.IsZero() {
-		deploymentTime = obj.GetCreationTimestamp().Time
-		j.debugLog("Using creation timestamp as deployment time: %s", deploymentTime)
+		j.skipWithoutBaseTime(ctx, obj, counter, source)
+		return nil
 	}
-
-	// Calculate expiry time
 	expiryTime := deploymentTime.Add(ttlDuration)
 	j.infoLog("Resource %s/%s expires at: %s", obj.GetNamespace(), obj.GetName(), expiryTime)
 
 	// Check if resource has expired
 	if time.Now().After(expiryTime) {
 		j.infoLog("Resource %s/%s has expired, will be deleted", obj.GetNamespace(), obj.GetName())
+		pending, err := j.deletionPending(ctx, obj, counter, source)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return nil
+		}
+		reason := fmt.Sprintf("%s %s expired on %s", label, ttl, expiryTime.Format(time.RFC3339))
+		j.recordDecision(obj, source, DecisionDelete, reason)
 		// Get kind using type assertion
 		kind := "Unknown"
 		if u, ok := obj.(*unstructured.Unstructured); ok {
 			kind = u.GetKind()
 		}
 
-		message := fmt.Sprintf("%s %s/%s expired on %s and will be deleted (TTL %s from %s)",
+		message := fmt.Sprintf("%s %s/%s expired on %s and will be deleted (%s %s from %s)",
 			kind,
 			obj.GetNamespace(),
 			obj.GetName(),
 			expiryTime.Format(time.RFC3339),
+			label,
 			ttl,
 			deploymentTime.Format(time.RFC3339))
 
-		if err := j.createEvent(ctx, obj, message, "TTLExpired"); err != nil {
+		if err := j.createEvent(ctx, obj, j.withAnnotatedReason(obj, message), "TTLExpired"); err != nil {
 			return fmt.Errorf("failed to create event: %v", err)
 		}
 
-		if err := j.deleteResource(ctx, obj); err != nil {
+		if err := j.deleteResource(ctx, obj, source+": "+reason, ""); j.deleteSkipped(err, counter) {
+			return nil
+		} else if err != nil {
 			return fmt.Errorf("failed to delete resource: %v", err)
 		}
 
-		resourceType := fmt.Sprintf("%ss", strings.ToLower(kind))
-		counter[resourceType+"-deleted"]++
-	} else if j.config.DeleteNotification > 0 {
-		// Send notification if configured and not already notified
-		notificationTime := expiryTime.Add(-time.Duration(j.config.DeleteNotification) * time.Second)
-		j.debugLog("Resource %s/%s notification time: %s", obj.GetNamespace(), obj.GetName(), notificationTime)
-		if time.Now().After(notificationTime) && !j.wasNotified(obj) {
-			j.infoLog("Sending delete notification for resource %s/%s", obj.GetNamespace(), obj.GetName())
-			if err := j.sendDeleteNotification(ctx, obj, fmt.Sprintf("TTL %s from %s", ttl, deploymentTime.Format(time.RFC3339)), expiryTime); err != nil {
-				return fmt.Errorf("failed to send delete notification: %v", err)
-			}
-		}
-	}
+		j.counterMutex.Lock()
+		defer j.counterMutex.Unlock()
+		counter[j.counterName(obj)+deletedCounterSuffix]++
+	} else {
+		j.skipResource(ctx, obj, counter, SkipReasonNotExpired, source, fmt.Sprintf("%s %s expires on %s", label, ttl, expiryTime.Format(time.RFC3339)))
+		observeTimeToExpiry(obj, expiryTime)
+		if _, err := j.notifyBeforeDeletion(ctx, obj, fmt.Sprintf("%s %s from %s", label, ttl, deploymentTime.Format(time.RFC3339)), expiryTime, j.notificationLead()); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// ttlBaseTime returns the time a resource's TTL counts from: its deployment
+// time (from baseAnnotation if given, else the deployment time annotation, the
+// configured TTL base field or its creation timestamp), or its last activity
+// if that is more recent. The zero time is returned if there is none of them,
+// e.g. for imported or hand-crafted objects without creation timestamp.
+func (j *Janitor) ttlBaseTime(obj metav1.Object, baseAnnotation string) time.Time {
+	annotations := obj.GetAnnotations()
+
+	// A rule's base annotation overrides the deployment time
+	var deploymentTime time.Time
+	if baseAnnotation != "" {
+		if baseStr, ok := annotations[baseAnnotation]; ok {
+			t, err := time.Parse(time.RFC3339, baseStr)
+			if err != nil {
+				log.Printf("Warning: ignoring invalid %s annotation %q on %s/%s",
+					baseAnnotation, baseStr, obj.GetNamespace(), obj.GetName())
+			} else {
+				deploymentTime = t
+				j.debugLog("Using %s annotation as deployment time: %s", baseAnnotation, deploymentTime)
+			}
+		}
+	}
+
+	// Get deployment time
+	if deploymentTime.IsZero() && j.config.DeploymentTimeAnnotation != "" {
+		if deployTimeStr, ok := annotations[j.config.DeploymentTimeAnnotation]; ok {
+			if t, err := time.Parse(time.RFC3339, deployTimeStr); err == nil {
+				deploymentTime = t
+				j.debugLog("Using deployment time from annotation: %s", deploymentTime)
+			}
+		}
+	}
+
+	// Otherwise use the configured timestamp field, e.g. the completion time of Jobs
+	if deploymentTime.IsZero() {
+		if field := j.ttlBaseField(obj); field != "" {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				deploymentTime = latestTimestamp(u.Object, strings.Split(field, "."))
+				if !deploymentTime.IsZero() {
+					j.debugLog("Using %s as deployment time: %s", field, deploymentTime)
+				}
+			}
+		}
+	}
+
+	// If no deployment time annotation or couldn't parse it, use creation timestamp
+	if created := obj.GetCreationTimestamp(); deploymentTime.IsZero() && !created.IsZero() {
+		deploymentTime = created.Time
+		j.debugLog("Using creation timestamp as deployment time: %s", deploymentTime)
+	}
+
+	// Recent activity extends the TTL
+	if j.config.LastActivityAnnotation != "" {
+		if activityStr, ok := annotations[j.config.LastActivityAnnotation]; ok {
+			activityTime, err := time.Parse(time.RFC3339, activityStr)
+			if err != nil {
+				log.Printf("Warning: ignoring invalid %s annotation %q on %s/%s",
+					j.config.LastActivityAnnotation, activityStr, obj.GetNamespace(), obj.GetName())
+			} else if activityTime.After(deploymentTime) {
+				j.debugLog("Using last activity time from annotation: %s", activityTime)
+				return activityTime
+			}
+		}
+	}
+
+	return deploymentTime
+}
+
+// skipWithoutBaseTime skips a resource without a time its TTL can count from,
+// instead of treating it as created in 1970 and deleting it right away
+func (j *Janitor) skipWithoutBaseTime(ctx context.Context, obj metav1.Object, counter map[string]int, source string) {
+	log.Printf("Warning: not deleting %s/%s, it has no creation timestamp or deployment time to count its TTL from",
+		obj.GetNamespace(), obj.GetName())
+	j.skipResource(ctx, obj, counter, SkipReasonNoCreationTimestamp, source, "no creation timestamp")
+}
+
+// ttlBaseField returns the timestamp field configured as TTL base for the type
+// of a resource, or an empty string if there is none
+func (j *Janitor) ttlBaseField(obj metav1.Object) string {
+	gvk := objectGVK(obj)
+	resourceType := j.resourceTypeFor(obj).Plural
+	for resource, field := range j.config.TTLBaseFields {
+		if matchesResourceName(resource, resourceType, gvk.Group) {
+			return field
+		}
+	}
+	return ""
+}
+
+// latestTimestamp returns the latest RFC 3339 timestamp found at a field path
+// of an object, or the zero time if there is none. A path segment ending in
+// [] is a list, e.g. status.containerStatuses[].state.terminated.finishedAt
+// covers all containers.
+func latestTimestamp(obj interface{}, path []string) time.Time {
+	if len(path) == 0 {
+		value, ok := obj.(string)
+		if !ok {
+			return time.Time{}
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}
+		}
+		return t
+	}
+
+	fields, ok := obj.(map[string]interface{})
+	if !ok {
+		return time.Time{}
+	}
+
+	name, isList := strings.CutSuffix(path[0], "[]")
+	if !isList {
+		return latestTimestamp(fields[name], path[1:])
+	}
+
+	items, _ := fields[name].([]interface{})
+	var latest time.Time
+	for _, item := range items {
+		if t := latestTimestamp(item, path[1:]); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
 // handleRules checks if any rules match the resource and applies TTL accordingly
 func (j *Janitor) handleRules(ctx context.Context, obj metav1.Object, counter map[string]int) error {
-	if len(j.config.Rules) == 0 {
+	rules := j.rulesFor(ctx, obj)
+	if len(rules) == 0 {
 		j.debugLog("No rules configured, skipping rule evaluation for %s/%s", obj.GetNamespace(), obj.GetName())
-		return nil
+		return j.handleNamespaceTTL(ctx, obj, counter, SkipReasonNoTTL, "no TTL annotation or rules")
 	}
 
 	// Convert resource to map for JMESPath evaluation
@@ -636,7 +1437,9 @@ func (j *Janitor) handleRules(ctx context.Context, obj metav1.Object, counter ma
 		return fmt.Errorf("failed to convert resource to map: %v", err)
 	}
 
-	j.debugLog("Evaluating %d rules for resource %s/%s", len(j.config.Rules), obj.GetNamespace(), obj.GetName())
+	j.debugLog("Evaluating %d rules for resource %s/%s", len(rules), obj.GetNamespace(), obj.GetName())
+
+	resourceType := j.resourceTypeFor(obj)
 
 	// Get resource context
 	context, err := j.getResourceContext(ctx, obj)
@@ -652,44 +1455,54 @@ func (j *Janitor) handleRules(ctx context.Context, obj metav1.Object, counter ma
 		context = make(map[string]interface{})
 	}
 
-	// Check each rule
-	for _, rule := range j.config.Rules {
+	if j.config.WarnRuleConflicts {
+		j.warnRuleConflicts(obj, rules, resourceType, resourceMap, context)
+	}
+
+	// Check each rule, remembering the first matching rule with an unlimited TTL
+	var foreverSource string
+	for _, rule := range rules {
+		if !rule.IsEnabled() {
+			j.debugLog("Rule %s is disabled, skipping", rule.ID)
+			continue
+		}
 		j.debugLog("Checking rule %s for resource %s/%s", rule.ID, obj.GetNamespace(), obj.GetName())
-		if rule.Matches(resourceMap, context) {
+		if rule.MatchesResource(resourceType, resourceMap, context) {
 			j.infoLog("Rule %s matched resource %s/%s", rule.ID, obj.GetNamespace(), obj.GetName())
+			j.countRule(counter, rule.ID, ruleStatMatched)
+			if !j.planning.Load() {
+				ruleMatches.WithLabelValues(rule.ID).Inc()
+			}
 			// Parse TTL
 			ttlDuration, err := ParseTTL(rule.TTL)
 			if err != nil {
 				return fmt.Errorf("invalid TTL in rule %s: %v", rule.ID, err)
 			}
 
+			// Apply the max TTL cap, if configured
+			ruleTTL := rule.TTL
+			if clamped, ok := j.clampTTL(obj, ttlDuration); ok {
+				ttlDuration = clamped
+				ruleTTL = FormatDuration(clamped)
+			}
+
+			source := fmt.Sprintf("rule %s (ttl %s)", rule.ID, rule.TTL)
+
 			// TTL of -1 means "forever", so skip
 			if ttlDuration < 0 {
 				j.debugLog("Rule %s has unlimited TTL, skipping", rule.ID)
-				continue
-			}
-
-			// Get deployment time
-			var deploymentTime time.Time
-			if j.config.DeploymentTimeAnnotation != "" {
-				annotations := obj.GetAnnotations()
-				if annotations != nil {
-					if deployTimeStr, ok := annotations[j.config.DeploymentTimeAnnotation]; ok {
-						if t, err := time.Parse(time.RFC3339, deployTimeStr); err == nil {
-							deploymentTime = t
-							j.debugLog("Using deployment time from annotation: %s", deploymentTime)
-						}
-					}
+				if foreverSource == "" {
+					foreverSource = source
 				}
+				continue
 			}
 
-			// If no deployment time annotation or couldn't parse it, use creation timestamp
+			// Calculate expiry time
+			deploymentTime := j.ttlBaseTime(obj, rule.TTLFrom)
 			if deploymentTime.IsZero() {
-				deploymentTime = obj.GetCreationTimestamp().Time
-				j.debugLog("Using creation timestamp as deployment time: %s", deploymentTime)
+				j.skipWithoutBaseTime(ctx, obj, counter, source)
+				return nil
 			}
-
-			// Calculate expiry time
 			expiryTime := deploymentTime.Add(ttlDuration)
 			j.infoLog("Resource %s/%s expires at: %s based on rule %s",
 				obj.GetNamespace(), obj.GetName(), expiryTime, rule.ID)
@@ -698,6 +1511,15 @@ func (j *Janitor) handleRules(ctx context.Context, obj metav1.Object, counter ma
 			if time.Now().After(expiryTime) {
 				j.infoLog("Resource %s/%s has expired based on rule %s, will be deleted",
 					obj.GetNamespace(), obj.GetName(), rule.ID)
+				pending, err := j.deletionPending(ctx, obj, counter, source)
+				if err != nil {
+					return err
+				}
+				if pending {
+					return nil
+				}
+				reason := fmt.Sprintf("TTL %s expired on %s", ruleTTL, expiryTime.Format(time.RFC3339))
+				j.recordDecision(obj, source, DecisionDelete, reason)
 				// Get kind using type assertion
 				kind := "Unknown"
 				if u, ok := obj.(*unstructured.Unstructured); ok {
@@ -710,42 +1532,150 @@ func (j *Janitor) handleRules(ctx context.Context, obj metav1.Object, counter ma
 					obj.GetName(),
 					expiryTime.Format(time.RFC3339),
 					rule.ID,
-					rule.TTL,
+					ruleTTL,
 					deploymentTime.Format(time.RFC3339))
 
-				if err := j.createEvent(ctx, obj, message, "RuleTTLExpired"); err != nil {
+				if err := j.createEvent(ctx, obj, j.withAnnotatedReason(obj, message), "RuleTTLExpired"); err != nil {
 					return fmt.Errorf("failed to create event: %v", err)
 				}
 
-				if err := j.deleteResource(ctx, obj); err != nil {
+				if err := j.deleteResource(ctx, obj, source+": "+reason, rule.ID); j.deleteSkipped(err, counter) {
+					return nil
+				} else if err != nil {
 					return fmt.Errorf("failed to delete resource: %v", err)
 				}
 
+				j.countRule(counter, rule.ID, ruleStatDeleted)
 				j.counterMutex.Lock()
 				defer j.counterMutex.Unlock()
-				resourceType := fmt.Sprintf("%ss", strings.ToLower(kind))
-				counter[resourceType+"-deleted"]++
+				counter[j.counterName(obj)+deletedCounterSuffix]++
 				return nil
-			} else if j.config.DeleteNotification > 0 {
-				// Send notification if configured and not already notified
-				notificationTime := expiryTime.Add(-time.Duration(j.config.DeleteNotification) * time.Second)
-				j.debugLog("Rule %s notification time for resource %s/%s: %s",
-					rule.ID, obj.GetNamespace(), obj.GetName(), notificationTime)
-				if time.Now().After(notificationTime) && !j.wasNotified(obj) {
-					j.infoLog("Sending delete notification for resource %s/%s based on rule %s",
-						obj.GetNamespace(), obj.GetName(), rule.ID)
-					if err := j.sendDeleteNotification(ctx, obj, fmt.Sprintf("rule %s, TTL %s from %s", rule.ID, rule.TTL, deploymentTime.Format(time.RFC3339)), expiryTime); err != nil {
-						return fmt.Errorf("failed to send delete notification: %v", err)
-					}
-				}
+			}
+
+			j.skipResource(ctx, obj, counter, SkipReasonNotExpired, source, fmt.Sprintf("TTL %s expires on %s", ruleTTL, expiryTime.Format(time.RFC3339)))
+			observeTimeToExpiry(obj, expiryTime)
+			lead := j.notificationLead()
+			if rule.notificationLead > 0 {
+				lead = rule.notificationLead
+			}
+			notified, err := j.notifyBeforeDeletion(ctx, obj, fmt.Sprintf("rule %s, TTL %s from %s", rule.ID, ruleTTL, deploymentTime.Format(time.RFC3339)), expiryTime, lead)
+			if err != nil {
+				return err
+			}
+			if notified {
+				j.countRule(counter, rule.ID, ruleStatNotified)
 			}
 
 			// Only apply the first matching rule
-			break
+			return nil
 		}
 	}
 
-	return nil
+	if foreverSource != "" {
+		j.skipResource(ctx, obj, counter, SkipReasonUnlimitedTTL, foreverSource, "unlimited TTL")
+		return nil
+	}
+	return j.handleNamespaceTTL(ctx, obj, counter, SkipReasonNoMatchingRule, "no TTL annotation or matching rule")
+}
+
+// warnRuleConflicts logs a warning if several rules with differing TTLs match a
+// resource, naming the rule that takes effect: the first matching rule with a
+// limited TTL, or else the first matching rule with an unlimited TTL
+func (j *Janitor) warnRuleConflicts(obj metav1.Object, rules []Rule, resourceType ResourceType, resourceMap, context map[string]interface{}) {
+	var matched []string
+	ttls := make(map[string]bool)
+	winner := ""
+	foreverWinner := ""
+	for _, rule := range rules {
+		if !rule.MatchesResource(resourceType, resourceMap, context) {
+			continue
+		}
+		matched = append(matched, fmt.Sprintf("%s (ttl %s)", rule.ID, rule.TTL))
+		ttls[rule.TTL] = true
+
+		ttlDuration, err := ParseTTL(rule.TTL)
+		if err != nil {
+			continue
+		}
+		if ttlDuration < 0 {
+			if foreverWinner == "" {
+				foreverWinner = rule.ID
+			}
+		} else if winner == "" {
+			winner = rule.ID
+		}
+	}
+
+	if len(ttls) < 2 {
+		return
+	}
+	if winner == "" {
+		winner = foreverWinner
+	}
+
+	kind := "Unknown"
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		kind = u.GetKind()
+	}
+	log.Printf("Warning: conflicting rules match %s %s/%s: %s, rule %s takes effect",
+		kind, obj.GetNamespace(), obj.GetName(), strings.Join(matched, ", "), winner)
+}
+
+// handleNamespaceTTL applies the TTL annotation of the containing namespace, or
+// else the TTL of its namespace policy, to a resource without its own TTL or
+// matching rule. The resource is skipped with the given reason if the
+// namespace has neither.
+func (j *Janitor) handleNamespaceTTL(ctx context.Context, obj metav1.Object, counter map[string]int, skipReason, reason string) error {
+	// Namespaces and cluster-scoped resources have no containing namespace
+	if _, ok := obj.(*corev1.Namespace); ok || obj.GetNamespace() == "" {
+		j.skipResource(ctx, obj, counter, skipReason, "-", reason)
+		return nil
+	}
+
+	ttl, ok := j.namespaceAnnotations(ctx, obj.GetNamespace())[TTLAnnotation]
+	if !ok {
+		if np := j.namespacePolicy(ctx, obj); np != nil && np.TTL != "" {
+			j.debugLog("Resource %s/%s gets TTL %s from the namespace policy", obj.GetNamespace(), obj.GetName(), np.TTL)
+			return j.applyTTL(ctx, obj, counter, np.TTL, "namespace policy TTL",
+				fmt.Sprintf("policy ConfigMap %s (ttl %s)", j.config.PolicyConfigMap, np.TTL))
+		}
+		j.skipResource(ctx, obj, counter, skipReason, "-", reason)
+		return nil
+	}
+
+	j.debugLog("Resource %s/%s inherits TTL %s from its namespace", obj.GetNamespace(), obj.GetName(), ttl)
+	return j.applyTTL(ctx, obj, counter, ttl, "namespace TTL",
+		fmt.Sprintf("namespace annotation %s=%s", TTLAnnotation, ttl))
+}
+
+// namespaceAnnotations returns the annotations of a namespace
+func (j *Janitor) namespaceAnnotations(ctx context.Context, name string) map[string]string {
+	return j.namespaceMeta(ctx, name).Annotations
+}
+
+// namespaceMeta returns the labels and annotations of a namespace. Namespaces
+// are cached for the duration of a cleanup run.
+func (j *Janitor) namespaceMeta(ctx context.Context, name string) metav1.ObjectMeta {
+	j.namespaceMutex.Lock()
+	defer j.namespaceMutex.Unlock()
+
+	if meta, ok := j.namespaceCache[name]; ok {
+		return meta
+	}
+
+	var meta metav1.ObjectMeta
+	ns, err := j.client.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		j.debugLog("Failed to get namespace %s: %v", name, err)
+	} else {
+		meta = metav1.ObjectMeta{Labels: ns.Labels, Annotations: ns.Annotations}
+	}
+
+	if j.namespaceCache == nil {
+		j.namespaceCache = make(map[string]metav1.ObjectMeta)
+	}
+	j.namespaceCache[name] = meta
+	return meta
 }
 
 // objectToMap converts a Kubernetes object to a map for JMESPath evaluation
@@ -766,19 +1696,67 @@ func (j *Janitor) objectToMap(obj metav1.Object) (map[string]interface{}, error)
 		return nil, fmt.Errorf("failed to unmarshal object: %v", err)
 	}
 
+	// Listed typed objects carry no type information, which rules need to
+	// match on the kind
+	if _, ok := result["kind"]; !ok {
+		gvk := objectGVK(obj)
+		result["apiVersion"] = gvk.GroupVersion().String()
+		result["kind"] = gvk.Kind
+	}
+
 	return result, nil
 }
 
-func (j *Janitor) deleteResource(ctx context.Context, obj metav1.Object) error {
-	if j.config.DryRun {
-		// Get kind using type assertion
-		kind := "Unknown"
-		if u, ok := obj.(*unstructured.Unstructured); ok {
-			kind = u.GetKind()
-		} else if _, ok := obj.(*corev1.Namespace); ok {
-			kind = "Namespace"
+// errResourceGone is returned by deleteResource if the resource no longer
+// exists, e.g. because its namespace was deleted during the run
+var errResourceGone = errors.New("resource no longer exists")
+
+// errNotDeletable is returned when the resolved resource of an object doesn't
+// support delete, e.g. because its kind is served by a virtual resource
+var errNotDeletable = errors.New("resource does not support delete")
+
+// errPaused is returned by deleteResource while deletions are paused
+var errPaused = errors.New("deletions are paused")
+
+// errNotConfirmed is returned by deleteResource if a namespace contains
+// resources that are not one of the confirmed deletions
+var errNotConfirmed = errors.New("deletion is not confirmed")
+
+// deleteResource deletes a resource, recording the reason and the ID of the
+// matching rule, if any, in the audit log
+func (j *Janitor) deleteResource(ctx context.Context, obj metav1.Object, reason, ruleID string) (err error) {
+	// Get kind using type assertion
+	kind := "Unknown"
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		kind = u.GetKind()
+	} else if _, ok := obj.(*corev1.Namespace); ok {
+		kind = "Namespace"
+	}
+
+	ctx, span := j.startSpan(ctx, "deleteResource", attrKind.String(kind),
+		attrNamespace.String(obj.GetNamespace()), attrName.String(obj.GetName()))
+	defer func() { endSpan(span, err) }()
+
+	if !j.deletionAllowed(obj) {
+		log.Printf("Error: refusing to delete %s %s/%s, %s is not in the allowed kinds %v",
+			kind, obj.GetNamespace(), obj.GetName(), j.counterName(obj), j.config.AllowedKinds)
+		return fmt.Errorf("deletion of %s is not allowed", j.counterName(obj))
+	}
+
+	if j.paused.Load() {
+		log.Printf("**PAUSED**: Would delete %s %s/%s", kind, obj.GetNamespace(), obj.GetName())
+		return errPaused
+	}
+
+	if _, ok := obj.(*corev1.Namespace); ok && j.config.DeleteNamespaceContents {
+		if err := j.deleteNamespaceContents(ctx, obj.GetName()); errors.Is(err, errNotConfirmed) {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("failed to delete contents of namespace %s: %v", obj.GetName(), err)
 		}
+	}
 
+	if j.dryRun() && !j.dryRunServer() {
 		log.Printf("**DRY-RUN**: Would delete %s %s/%s",
 			kind,
 			obj.GetNamespace(),
@@ -787,56 +1765,345 @@ func (j *Janitor) deleteResource(ctx context.Context, obj metav1.Object) error {
 		return nil
 	}
 
-	// Get GVR using type assertion
-	var gvr schema.GroupVersionResource
+	gvr := j.gvrFor(obj)
+	if !j.supportsDelete(gvr) {
+		j.debugLog("%s %s/%s resolves to %s, which doesn't support delete, skipping",
+			kind, obj.GetNamespace(), obj.GetName(), gvr)
+		return errNotDeletable
+	}
+
+	deleteOptions := metav1.DeleteOptions{
+		PropagationPolicy: &[]metav1.DeletionPropagation{metav1.DeletePropagationBackground}[0],
+	}
+
+	if j.dryRunServer() {
+		// Let the API server run admission without persisting the deletion,
+		// so that webhook denials are reported as errors
+		deleteOptions.DryRun = []string{metav1.DryRunAll}
+		log.Printf("**DRY-RUN**: Sending server-side dry-run delete for %s %s/%s",
+			kind,
+			obj.GetNamespace(),
+			obj.GetName())
+	}
+
+	if j.isForbidden(gvr) {
+		return errTypeForbidden
+	}
+	release, err := j.acquireDeleteSlot(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to delete resource: %v", err)
+	}
+	var deleteErr error
+	if j.shouldEvict(obj) {
+		deleteErr = j.evictPod(ctx, obj, deleteOptions)
+	} else if obj.GetNamespace() != "" {
+		j.infoLog("Deleting namespaced resource %s/%s", obj.GetNamespace(), obj.GetName())
+		deleteErr = j.dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), deleteOptions)
+	} else {
+		j.infoLog("Deleting cluster-scoped resource %s", obj.GetName())
+		deleteErr = j.dynamicClient.Resource(gvr).Delete(ctx, obj.GetName(), deleteOptions)
+	}
+	release()
+	if apierrors.IsNotFound(deleteErr) {
+		j.trackDeleteResult(obj, deleteErr)
+		j.debugLog("%s %s/%s no longer exists, skipping", kind, obj.GetNamespace(), obj.GetName())
+		return errResourceGone
+	}
+	if apierrors.IsForbidden(deleteErr) {
+		verb := "delete"
+		if j.shouldEvict(obj) {
+			verb = "evict"
+		}
+		j.markForbidden(gvr, verb, deleteErr)
+		return errTypeForbidden
+	}
+	if j.dryRunServer() {
+		if deleteErr != nil {
+			return fmt.Errorf("server-side dry-run delete failed: %v", deleteErr)
+		}
+		return nil
+	}
+
+	j.trackDeleteResult(obj, deleteErr)
+	if deleteErr != nil {
+		return fmt.Errorf("failed to delete resource: %v", deleteErr)
+	}
+
+	if err := j.writeAuditLog(obj, reason, ruleID); err != nil {
+		log.Printf("Failed to write audit log entry for %s %s/%s: %v", kind, obj.GetNamespace(), obj.GetName(), err)
+	}
+
+	if j.config.WaitAfterDelete > 0 {
+		j.infoLog("Waiting %d seconds after delete", j.config.WaitAfterDelete)
+		timer := time.NewTimer(time.Duration(j.config.WaitAfterDelete) * time.Second)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// The resource is already deleted, only stop waiting so that
+			// shutdown is not held up
+			j.debugLog("Stopped waiting after delete: %v", ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// acquireDeleteSlot waits until fewer than MaxConcurrentDeletes delete calls
+// are in flight and returns a function releasing the slot. Only the API
+// calls are limited, so that namespace contents can be deleted while holding
+// no slot and the workers keep evaluating resources in the meantime.
+func (j *Janitor) acquireDeleteSlot(ctx context.Context) (func(), error) {
+	if j.config.MaxConcurrentDeletes <= 0 {
+		return func() {}, nil
+	}
+	j.deleteSlotsOnce.Do(func() {
+		j.deleteSlots = make(chan struct{}, j.config.MaxConcurrentDeletes)
+	})
+
+	select {
+	case j.deleteSlots <- struct{}{}:
+		return func() { <-j.deleteSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// deleteNamespaceContents deletes the resources within a namespace that match
+// the configured filters, so that the namespace deletion is not held up by
+// its contents. The contents are recorded as decisions, so that they are part
+// of the planned deletions, and a resource that is not one of the confirmed
+// deletions keeps the namespace.
+func (j *Janitor) deleteNamespaceContents(ctx context.Context, namespace string) error {
+	resourceTypes, err := j.getResourceTypes()
+	if err != nil {
+		return fmt.Errorf("failed to get resource types: %v", err)
+	}
+
+	j.infoLog("Deleting contents of namespace %s", namespace)
+	j.sortByDeleteOrder(resourceTypes)
+	for _, resourceType := range resourceTypes {
+		if !resourceType.Namespaced || !j.shouldProcessResourceType(resourceType) {
+			continue
+		}
+
+		resources, err := j.listNamespacedResources(ctx, resourceType, namespace)
+		if errors.Is(err, errTypeForbidden) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, obj := range resources {
+			if !j.matchesResourceFilter(obj) {
+				continue
+			}
+			if !j.deletionConfirmed(obj) {
+				log.Printf("Not deleting namespace %s, %s %s/%s is not one of the confirmed deletions",
+					namespace, objectGVK(obj).Kind, obj.GetNamespace(), obj.GetName())
+				return errNotConfirmed
+			}
+			reason := fmt.Sprintf("contents of expired namespace %s", namespace)
+			j.recordDecision(obj, "namespace "+namespace, DecisionDelete, reason)
+			err := j.deleteResource(ctx, obj, reason, "")
+			if errors.Is(err, errTypeForbidden) {
+				break
+			}
+			if errors.Is(err, errPaused) {
+				return nil
+			}
+			if err != nil && !errors.Is(err, errResourceGone) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// clampTTL caps a TTL at the configured max TTL. It returns the capped TTL
+// and true if clamping occurred.
+func (j *Janitor) clampTTL(obj metav1.Object, ttl time.Duration) (time.Duration, bool) {
+	if j.config.MaxTTL == "" {
+		return ttl, false
+	}
+
+	maxTTL, err := ParseTTL(j.config.MaxTTL)
+	if err != nil || maxTTL < 0 {
+		log.Printf("Warning: ignoring invalid max TTL %q", j.config.MaxTTL)
+		return ttl, false
+	}
+
+	// A TTL of -1 means "forever" and is only kept if explicitly allowed
+	if ttl < 0 && j.config.AllowForeverTTL {
+		return ttl, false
+	}
+
+	if ttl >= 0 && ttl <= maxTTL {
+		return ttl, false
+	}
+
+	original := TTLUnlimited
+	if ttl >= 0 {
+		original = FormatDuration(ttl)
+	}
+	log.Printf("Clamping TTL of %s/%s from %s to max TTL %s",
+		obj.GetNamespace(), obj.GetName(), original, FormatDuration(maxTTL))
+	return maxTTL, true
+}
+
+// SetDiscoveryClient sets the discovery client used to find the resource
+// types, instead of the discovery client of the Kubernetes client
+func (j *Janitor) SetDiscoveryClient(client discovery.DiscoveryInterface) {
+	j.discovery = client
+}
+
+// discoveryClient returns the discovery client set with SetDiscoveryClient,
+// or the discovery client of the Kubernetes client
+func (j *Janitor) discoveryClient() discovery.DiscoveryInterface {
+	if j.discovery != nil {
+		return j.discovery
+	}
+	return j.client.Discovery()
+}
+
+// getResourceTypes discovers the resource types to process and remembers
+// their plural names and that they support delete. How long discovery took
+// and how many types it found is logged and recorded as metrics.
+func (j *Janitor) getResourceTypes() ([]ResourceType, error) {
+	start := time.Now()
+	discovered, err := discoverResourceTypes(j.discoveryClient(), j.config.APIPreferences, "delete", "list")
+	if err != nil {
+		return nil, err
+	}
+	resourceTypes := discovered["delete"]
+	duration := time.Since(start)
+	discoveryDuration.Set(duration.Seconds())
+	discoveredResourceTypes.Set(float64(len(resourceTypes)))
+	j.infoLog("Discovered %d resource types in %v", len(resourceTypes), duration.Round(time.Millisecond))
+
+	plurals := make(map[schema.GroupVersionKind]string, len(resourceTypes))
+	clusterScopedKinds := make(map[schema.GroupVersionKind]bool)
+	deletableResources := make(map[schema.GroupVersionResource]bool, len(resourceTypes))
+	for _, rt := range resourceTypes {
+		gvk := schema.GroupVersionKind{Group: rt.Group, Version: rt.Version, Kind: rt.Kind}
+		plurals[gvk] = rt.Plural
+		deletableResources[schema.GroupVersionResource{Group: rt.Group, Version: rt.Version, Resource: rt.Plural}] = true
+		if !rt.Namespaced {
+			clusterScopedKinds[gvk] = true
+		}
+	}
+
+	j.pluralsMutex.Lock()
+	j.plurals = plurals
+	j.clusterScopedKinds = clusterScopedKinds
+	j.deletableResources = deletableResources
+	j.listableTypes = discovered["list"]
+	j.pluralsMutex.Unlock()
+	return resourceTypes, nil
+}
+
+// gvrFor determines the GroupVersionResource of an object, using the plural
+// name from discovery if the object's kind was discovered
+func (j *Janitor) gvrFor(obj metav1.Object) schema.GroupVersionResource {
+	gvr := resourceGVR(obj)
+
+	j.pluralsMutex.Lock()
+	defer j.pluralsMutex.Unlock()
+	if plural, ok := j.plurals[objectGVK(obj)]; ok {
+		gvr.Resource = plural
+	}
+	return gvr
+}
+
+// supportsDelete checks if discovery lists a resource with the delete verb.
+// GetResourceTypes only returns such resources, but an object's kind may still
+// resolve to a resource that isn't deletable, e.g. if discovery doesn't serve
+// the kind in that version. Resources are assumed deletable until the first
+// discovery.
+func (j *Janitor) supportsDelete(gvr schema.GroupVersionResource) bool {
+	j.pluralsMutex.Lock()
+	defer j.pluralsMutex.Unlock()
+	return j.deletableResources == nil || j.deletableResources[gvr]
+}
+
+// resourceTypeFor determines the resource type of an object from discovery.
+// Kinds missing from discovery get a guessed plural and are namespaced if the
+// object has a namespace.
+func (j *Janitor) resourceTypeFor(obj metav1.Object) ResourceType {
+	gvk := objectGVK(obj)
+	gvr := j.gvrFor(obj)
+
+	j.pluralsMutex.Lock()
+	_, discovered := j.plurals[gvk]
+	clusterScoped := j.clusterScopedKinds[gvk]
+	j.pluralsMutex.Unlock()
+
+	namespaced := obj.GetNamespace() != ""
+	if discovered {
+		namespaced = !clusterScoped
+	}
+	return ResourceType{
+		Group:      gvk.Group,
+		Version:    gvk.Version,
+		Kind:       gvk.Kind,
+		Plural:     gvr.Resource,
+		Namespaced: namespaced,
+	}
+}
 
+// resourceGVR determines the GroupVersionResource of an object using type
+// assertion, guessing the plural name from the kind
+func resourceGVR(obj metav1.Object) schema.GroupVersionResource {
 	if u, ok := obj.(*unstructured.Unstructured); ok {
 		gvk := u.GroupVersionKind()
-		gvr = schema.GroupVersionResource{
+		return schema.GroupVersionResource{
 			Group:    gvk.Group,
 			Version:  gvk.Version,
 			Resource: strings.ToLower(gvk.Kind) + "s",
 		}
-	} else if _, ok := obj.(*corev1.Namespace); ok {
+	}
+
+	if _, ok := obj.(*corev1.Namespace); ok {
 		// Handle namespace objects specifically
-		gvr = schema.GroupVersionResource{
+		return schema.GroupVersionResource{
 			Group:    "",
 			Version:  "v1",
 			Resource: "namespaces",
 		}
-	} else {
-		// Default to core/v1 if we can't determine the GVR
-		gvr = schema.GroupVersionResource{
-			Group:    "",
-			Version:  "v1",
-			Resource: strings.ToLower("Unknown") + "s",
-		}
 	}
 
-	deleteOptions := metav1.DeleteOptions{
-		PropagationPolicy: &[]metav1.DeletionPropagation{metav1.DeletePropagationBackground}[0],
+	// Default to core/v1 if we can't determine the GVR
+	return schema.GroupVersionResource{
+		Group:    "",
+		Version:  "v1",
+		Resource: strings.ToLower("Unknown") + "s",
 	}
+}
 
-	if obj.GetNamespace() != "" {
-		j.infoLog("Deleting namespaced resource %s/%s", obj.GetNamespace(), obj.GetName())
-		err := j.dynamicClient.Resource(gvr).Namespace(obj.GetNamespace()).Delete(ctx, obj.GetName(), deleteOptions)
-		if err != nil {
-			return fmt.Errorf("failed to delete resource: %v", err)
-		}
-	} else {
-		j.infoLog("Deleting cluster-scoped resource %s", obj.GetName())
-		err := j.dynamicClient.Resource(gvr).Delete(ctx, obj.GetName(), deleteOptions)
-		if err != nil {
-			return fmt.Errorf("failed to delete resource: %v", err)
-		}
+// objectGVK determines the GroupVersionKind of an object using type assertion
+func objectGVK(obj metav1.Object) schema.GroupVersionKind {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GroupVersionKind()
 	}
 
-	if j.config.WaitAfterDelete > 0 {
-		j.infoLog("Waiting %d seconds after delete", j.config.WaitAfterDelete)
-		time.Sleep(time.Duration(j.config.WaitAfterDelete) * time.Second)
+	if _, ok := obj.(*corev1.Namespace); ok {
+		return schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
 	}
 
-	return nil
+	return schema.GroupVersionKind{Kind: "Unknown"}
+}
+
+// counterName returns the resource type name used in counters, qualified
+// with the API group for resources outside of the core group. The plural
+// from discovery is used, so that irregular plurals are reported correctly.
+func (j *Janitor) counterName(obj metav1.Object) string {
+	gvr := j.gvrFor(obj)
+	name := gvr.Resource
+	if gvr.Group != "" {
+		name += "." + gvr.Group
+	}
+	return name
 }
 
 func (j *Janitor) wasNotified(obj metav1.Object) bool {
@@ -861,16 +2128,44 @@ func (j *Janitor) handleResource(ctx context.Context, resource metav1.Object, co
 
 	j.debugLog("Processing resource: %s/%s/%s", kind, resource.GetNamespace(), resource.GetName())
 
-	if !j.matchesResourceFilter(resource) {
-		j.debugLog("Resource %s/%s/%s does not match filters, skipping",
-			kind, resource.GetNamespace(), resource.GetName())
+	if reason := j.filterSkipReason(resource); reason != "" {
+		j.debugLog("Resource %s/%s/%s does not match filters (%s), skipping",
+			kind, resource.GetNamespace(), resource.GetName(), reason)
+		j.countSkip(counter, reason)
 		return nil
 	}
 
 	// Increment counter with mutex protection
 	j.counterMutex.Lock()
-	defer j.counterMutex.Unlock()
-	counter["resources-processed"]++
+	counter[processedCounter]++
+	j.counterMutex.Unlock()
+
+	skipReason, reason, err := j.protectionSkipReason(ctx, resource)
+	if err != nil {
+		return fmt.Errorf("failed to check protection: %v", err)
+	}
+	if skipReason != "" {
+		j.infoLog("Resource %s/%s/%s is protected (%s), skipping",
+			kind, resource.GetNamespace(), resource.GetName(), reason)
+		j.skipResource(ctx, resource, counter, skipReason, "-", reason)
+		return nil
+	}
+
+	// The expires annotation takes precedence over the TTL annotation and rules,
+	// so that only a single deletion path runs for a resource
+	if _, hasExpiry := resource.GetAnnotations()[ExpiryAnnotation]; hasExpiry {
+		if _, hasTTL := resource.GetAnnotations()[TTLAnnotation]; hasTTL {
+			j.debugLog("Resource %s/%s/%s has both %s and %s annotations, ignoring TTL",
+				kind, resource.GetNamespace(), resource.GetName(), ExpiryAnnotation, TTLAnnotation)
+		}
+
+		j.debugLog("Checking expiry for resource: %s/%s/%s",
+			kind, resource.GetNamespace(), resource.GetName())
+		if err := j.handleExpiry(ctx, resource, counter); err != nil {
+			return fmt.Errorf("failed to handle expiry: %v", err)
+		}
+		return nil
+	}
 
 	j.debugLog("Checking TTL for resource: %s/%s/%s",
 		kind, resource.GetNamespace(), resource.GetName())
@@ -879,21 +2174,23 @@ func (j *Janitor) handleResource(ctx context.Context, resource metav1.Object, co
 		return fmt.Errorf("failed to handle TTL: %v", err)
 	}
 
-	j.debugLog("Checking expiry for resource: %s/%s/%s",
-		kind, resource.GetNamespace(), resource.GetName())
-	if err := j.handleExpiry(ctx, resource, counter); err != nil {
-		return fmt.Errorf("failed to handle expiry: %v", err)
-	}
-
 	return nil
 }
 
 func (j *Janitor) cleanupNamespaces(ctx context.Context, counter map[string]int) error {
-	if !stringInSlice("namespaces", j.config.IncludeResources) &&
-		!stringInSlice("all", j.config.IncludeResources) {
+	if include := j.resourceFilters().include; !stringInSlice("namespaces", include) &&
+		!stringInSlice("all", include) {
 		j.debugLog("Namespaces not included in resources to process, skipping")
 		return nil
 	}
+	if !j.shouldProcessGroup("") {
+		j.debugLog("Core API group not included, skipping namespaces")
+		return nil
+	}
+	if j.config.Namespace != "" {
+		j.debugLog("Processing only namespace %s, skipping namespaces", j.config.Namespace)
+		return nil
+	}
 
 	j.debugLog("Listing all namespaces")
 	namespaces, err := j.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
@@ -906,10 +2203,12 @@ func (j *Janitor) cleanupNamespaces(ctx context.Context, counter map[string]int)
 	var filteredNamespaces []metav1.Object
 	for i := range namespaces.Items {
 		ns := &namespaces.Items[i]
-		if j.matchesResourceFilter(ns) {
-			filteredNamespaces = append(filteredNamespaces, ns)
-		} else {
+		if !j.matchesResourceFilter(ns) {
 			j.debugLog("Namespace %s does not match filters, skipping", ns.Name)
+		} else if !j.isDue(ns.Name) {
+			j.debugLog("Namespace %s is not due for processing, skipping", ns.Name)
+		} else {
+			filteredNamespaces = append(filteredNamespaces, ns)
 		}
 	}
 
@@ -919,28 +2218,94 @@ func (j *Janitor) cleanupNamespaces(ctx context.Context, counter map[string]int)
 	return nil
 }
 
-// processResourcesInParallel processes resources in parallel using worker pool
+// Number of times resources that failed to be processed are requeued within a
+// run, and the delay before requeueing them
+var (
+	requeueAttempts = 1
+	requeueDelay    = time.Second
+)
+
+// processResourcesInParallel processes resources in parallel using worker pool.
+// Resources that fail to be processed, e.g. due to a transient API error, are
+// requeued within the run before they are counted as errors.
 func (j *Janitor) processResourcesInParallel(ctx context.Context, resources []metav1.Object, counter map[string]int, alreadySeen map[string]bool) {
 	if len(resources) == 0 {
 		return
 	}
+	j.processResourceStream(ctx, sendAll(resources), counter, alreadySeen)
+}
+
+// sendAll returns a producer for processResourceStream that sends the given
+// resources
+func sendAll(resources []metav1.Object) func(send func(metav1.Object) bool) {
+	return func(send func(metav1.Object) bool) {
+		for _, resource := range resources {
+			if !send(resource) {
+				return
+			}
+		}
+	}
+}
+
+// processResourceStream processes the resources that produce sends in
+// parallel using a worker pool, while produce is still running. Sending
+// blocks while all workers are busy, so that the resources are never all held
+// in memory at once, and fails once the run is cancelled. Resources that fail
+// to be processed are requeued like in processResourcesInParallel.
+func (j *Janitor) processResourceStream(ctx context.Context, produce func(send func(metav1.Object) bool), counter map[string]int, alreadySeen map[string]bool) {
+	failed := j.processResourceBatch(ctx, produce, counter, alreadySeen, false)
+	for attempt := 1; attempt <= requeueAttempts && len(failed) > 0 && ctx.Err() == nil; attempt++ {
+		j.infoLog("Requeueing %d resources that failed to be processed (attempt %d/%d)", len(failed), attempt, requeueAttempts)
+		timer := time.NewTimer(requeueDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			continue
+		}
+
+		// The requeued resources were already counted as processed
+		requeueCounter := make(map[string]int)
+		failed = j.processResourceBatch(ctx, sendAll(failed), requeueCounter, alreadySeen, true)
+		j.counterMutex.Lock()
+		for k, v := range requeueCounter {
+			if k != processedCounter {
+				counter[k] += v
+			}
+		}
+		j.counterMutex.Unlock()
+	}
+
+	for _, resource := range failed {
+		log.Printf("Giving up on %s %s/%s for this run", objectGVK(resource).Kind, resource.GetNamespace(), resource.GetName())
+		j.countError(counter)
+	}
+}
 
+// processResourceBatch processes the resources that produce sends in parallel
+// using worker pool and returns the resources that failed to be processed.
+// Already seen resources are skipped unless they are requeued.
+func (j *Janitor) processResourceBatch(ctx context.Context, produce func(send func(metav1.Object) bool), counter map[string]int, alreadySeen map[string]bool, requeued bool) []metav1.Object {
 	// Use a mutex to protect alreadySeen map
 	var alreadySeenMutex sync.Mutex
 
+	var failed []metav1.Object
+	var failedMutex sync.Mutex
+
 	// Create a wait group to wait for all workers to finish
 	var wg sync.WaitGroup
 
-	// Create a channel for resources
-	resourceCh := make(chan metav1.Object, len(resources))
-
 	// Determine number of workers
 	numWorkers := j.config.Parallelism
 	if numWorkers <= 0 {
 		numWorkers = 1
 	}
 
-	j.debugLog("Processing %d resources with %d workers", len(resources), numWorkers)
+	// Create a channel for resources, bounded so that the producer only runs
+	// ahead of the workers by a resource per worker
+	resourceCh := make(chan metav1.Object, numWorkers)
+
+	j.debugLog("Processing resources with %d workers", numWorkers)
 
 	// Start workers
 	for i := 0; i < numWorkers; i++ {
@@ -950,16 +2315,19 @@ func (j *Janitor) processResourcesInParallel(ctx context.Context, resources []me
 			j.debugLog("Worker %d started", workerID)
 
 			for resource := range resourceCh {
+				// Drain the remaining resources once the run is cancelled
+				if ctx.Err() != nil {
+					continue
+				}
+
 				// Check if already processed
 				alreadySeenMutex.Lock()
-				kind := "Unknown"
-				if u, ok := resource.(*unstructured.Unstructured); ok {
-					kind = u.GetKind()
-				} else if _, ok := resource.(*corev1.Namespace); ok {
-					kind = "Namespace"
-				}
-				key := fmt.Sprintf("%s/%s/%s", kind, resource.GetNamespace(), resource.GetName())
-				seen := alreadySeen[key]
+				gvk := objectGVK(resource)
+				kind := gvk.Kind
+				// Include the API group and version so that same-kind resources
+				// of different groups are never conflated
+				key := fmt.Sprintf("%s/%s/%s/%s/%s", gvk.Group, gvk.Version, kind, resource.GetNamespace(), resource.GetName())
+				seen := alreadySeen[key] && !requeued
 				if !seen {
 					alreadySeen[key] = true
 				}
@@ -975,6 +2343,9 @@ func (j *Janitor) processResourcesInParallel(ctx context.Context, resources []me
 				if err := j.handleResource(ctx, resource, counter, alreadySeen); err != nil {
 					log.Printf("Worker %d: Error handling %s %s/%s: %v",
 						workerID, kind, resource.GetNamespace(), resource.GetName(), err)
+					failedMutex.Lock()
+					failed = append(failed, resource)
+					failedMutex.Unlock()
 				}
 			}
 
@@ -983,13 +2354,20 @@ func (j *Janitor) processResourcesInParallel(ctx context.Context, resources []me
 	}
 
 	// Send resources to channel
-	for _, resource := range resources {
-		resourceCh <- resource
-	}
+	produce(func(resource metav1.Object) bool {
+		select {
+		case resourceCh <- resource:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
 
 	// Close channel and wait for workers to finish
 	close(resourceCh)
 	wg.Wait()
+
+	return failed
 }
 
 func (j *Janitor) logCleanupSummary(counter map[string]int) {
@@ -1004,6 +2382,7 @@ func (j *Janitor) logCleanupSummary(counter map[string]int) {
 	for k, v := range counter {
 		stats = append(stats, fmt.Sprintf("%s=%d", k, v))
 	}
+	sort.Strings(stats)
 
 	log.Printf("Clean up run completed: %s", strings.Join(stats, ", "))
 
@@ -1017,15 +2396,127 @@ func (j *Janitor) logCleanupSummary(counter map[string]int) {
 
 // matchesResourceFilter checks if a resource matches the configured filters
 func (j *Janitor) matchesResourceFilter(obj metav1.Object) bool {
-	// Get kind using type assertion
-	kind := "Unknown"
-	if u, ok := obj.(*unstructured.Unstructured); ok {
-		kind = u.GetKind()
-	} else if ns, ok := obj.(*corev1.Namespace); ok {
-		kind = "Namespace"
-		_ = ns // avoid unused variable warning
+	return j.filterSkipReason(obj) == ""
+}
+
+// filterSkipReason returns the reason a resource is excluded by the configured
+// filters, or an empty string if it matches them
+func (j *Janitor) filterSkipReason(obj metav1.Object) string {
+	if reason := j.scopeSkipReason(obj); reason != "" {
+		return reason
+	}
+
+	for _, selector := range j.config.ExcludeLabels {
+		if matchesExcludeSelector(selector, obj.GetLabels()) {
+			return SkipReasonExcludedLabel
+		}
+	}
+	for _, selector := range j.config.ExcludeAnnotations {
+		if matchesExcludeSelector(selector, obj.GetAnnotations()) {
+			return SkipReasonExcludedAnnotation
+		}
+	}
+	if j.excludedByJMESPath(obj) {
+		return SkipReasonExcludedJMESPath
+	}
+	if !j.inCreationWindow(obj) {
+		return SkipReasonCreationWindow
 	}
 
+	return ""
+}
+
+// inCreationWindow checks if a resource was created within the window of
+// --created-after and --created-before, e.g. to only clean up the leftovers of
+// a migration. Resources are in the window if none is configured.
+func (j *Janitor) inCreationWindow(obj metav1.Object) bool {
+	created := obj.GetCreationTimestamp().Time
+	if j.config.CreatedBefore != "" {
+		before, err := ParseExpiry(j.config.CreatedBefore)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid created-before %q", j.config.CreatedBefore)
+		} else if !created.Before(before) {
+			return false
+		}
+	}
+	if j.config.CreatedAfter != "" {
+		after, err := ParseExpiry(j.config.CreatedAfter)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid created-after %q", j.config.CreatedAfter)
+		} else if !created.After(after) {
+			return false
+		}
+	}
+	return true
+}
+
+// excludedByJMESPath checks if any of the configured exclude expressions is
+// true for a resource. A resource that cannot be evaluated is excluded, so
+// that a broken expression never leads to deletions.
+func (j *Janitor) excludedByJMESPath(obj metav1.Object) bool {
+	if len(j.config.excludeExprs) == 0 {
+		return false
+	}
+
+	resourceMap, err := j.objectToMap(obj)
+	if err != nil {
+		log.Printf("Warning: excluding %s/%s, failed to evaluate exclude-jmespath: %v", obj.GetNamespace(), obj.GetName(), err)
+		return true
+	}
+	for i, expr := range j.config.excludeExprs {
+		result, err := expr.Search(resourceMap)
+		if err != nil {
+			log.Printf("Warning: excluding %s/%s, failed to evaluate exclude-jmespath %q: %v",
+				obj.GetNamespace(), obj.GetName(), j.config.ExcludeJMESPaths[i], err)
+			return true
+		}
+		if isTruthy(result) {
+			return true
+		}
+	}
+	return false
+}
+
+// includesClusterResource checks if a cluster-scoped resource type is
+// included, either because all cluster-scoped resources are or because it is
+// one of the listed types. No cluster-scoped resources are included when a
+// single namespace is processed.
+func (j *Janitor) includesClusterResource(plural, group string) bool {
+	if !j.config.IncludeClusterResources || j.config.Namespace != "" {
+		return false
+	}
+	cluster := j.resourceFilters().cluster
+	if len(cluster) == 0 {
+		return true
+	}
+	for _, name := range cluster {
+		if matchesResourceName(name, plural, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesExcludeSelector checks if all comma-separated key=value pairs of an
+// exclude selector match the given labels or annotations. A key without a
+// value matches any value.
+func matchesExcludeSelector(selector string, values map[string]string) bool {
+	for _, pair := range strings.Split(selector, ",") {
+		key, value, hasValue := strings.Cut(pair, "=")
+		actual, ok := values[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// scopeSkipReason returns the reason a resource is outside of the configured
+// resource types and namespaces, or an empty string if it is within them
+func (j *Janitor) scopeSkipReason(obj metav1.Object) string {
+	gvk := objectGVK(obj)
+	kind := gvk.Kind
+
 	namespace := obj.GetNamespace()
 	name := obj.GetName()
 
@@ -1033,59 +2524,73 @@ func (j *Janitor) matchesResourceFilter(obj metav1.Object) bool {
 		namespace = name
 	}
 
-	resourceType := strings.ToLower(kind) + "s"
+	// The discovered plural, which may be irregular, e.g. ingresses
+	resourceType := j.resourceTypeFor(obj).Plural
 
 	// Check if resource type is explicitly excluded
-	for _, excluded := range j.config.ExcludeResources {
-		if excluded == resourceType {
-			return false
+	filters := j.resourceFilters()
+	for _, excluded := range filters.exclude {
+		if matchesResourceName(excluded, resourceType, gvk.Group) {
+			return SkipReasonExcludedResource
 		}
 	}
 
 	// Check if resource type is included
 	resourceIncluded := false
-	for _, included := range j.config.IncludeResources {
-		if included == "all" || included == resourceType {
+	for _, included := range filters.include {
+		if included == "all" || matchesResourceName(included, resourceType, gvk.Group) {
 			resourceIncluded = true
 			break
 		}
 	}
 
 	if !resourceIncluded {
-		return false
+		return SkipReasonExcludedResource
 	}
 
 	// Handle namespaces specially
 	if kind == "Namespace" {
+		if j.config.Namespace != "" {
+			return SkipReasonClusterResource
+		}
 		for _, excluded := range j.config.ExcludeNamespaces {
 			if excluded == name {
-				return false
+				return SkipReasonExcludedNamespace
 			}
 		}
 		for _, included := range j.config.IncludeNamespaces {
 			if included == "all" || included == name {
-				return true
+				return ""
 			}
 		}
-		return false
+		return SkipReasonExcludedNamespace
 	}
 
 	// Handle cluster-scoped vs namespaced resources
 	if namespace == "" {
-		return j.config.IncludeClusterResources
+		if !j.includesClusterResource(resourceType, gvk.Group) {
+			return SkipReasonClusterResource
+		}
+		return ""
 	}
 
 	// Check namespace filters
+	if j.config.Namespace != "" {
+		if namespace != j.config.Namespace {
+			return SkipReasonExcludedNamespace
+		}
+		return ""
+	}
 	for _, excluded := range j.config.ExcludeNamespaces {
 		if excluded == namespace {
-			return false
+			return SkipReasonExcludedNamespace
 		}
 	}
 	for _, included := range j.config.IncludeNamespaces {
 		if included == "all" || included == namespace {
-			return true
+			return ""
 		}
 	}
 
-	return false
+	return SkipReasonExcludedNamespace
 }
@@ -1,18 +1,48 @@
 package janitor
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
+// newUnstructuredPod creates an unstructured Pod so that the janitor can determine its kind
+func newUnstructuredPod(name, namespace string, created time.Time, annotations map[string]string) *unstructured.Unstructured {
+	pod := &unstructured.Unstructured{}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetName(name)
+	pod.SetNamespace(namespace)
+	pod.SetCreationTimestamp(metav1.NewTime(created))
+	pod.SetAnnotations(annotations)
+	return pod
+}
+
 func TestJanitorCleanup(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -591,8 +621,2110 @@ func TestWebhookNotifications(t *testing.T) {
 	defer os.Unsetenv("WEBHOOK_URL")
 
 	// Test notification
-	err := SendWebhookNotification("Test notification message")
+	err := SendWebhookNotification(nil, "Test notification message")
 	if err != nil {
 		t.Errorf("SendWebhookNotification() error = %v", err)
 	}
 }
+
+func TestClampTTL(t *testing.T) {
+	tests := []struct {
+		name            string
+		maxTTL          string
+		allowForeverTTL bool
+		ttl             time.Duration
+		want            time.Duration
+		wantClamped     bool
+	}{
+		{
+			name:        "no max TTL configured",
+			maxTTL:      "",
+			ttl:         9999 * 7 * 24 * time.Hour,
+			want:        9999 * 7 * 24 * time.Hour,
+			wantClamped: false,
+		},
+		{
+			name:        "TTL below max is kept",
+			maxTTL:      "1w",
+			ttl:         24 * time.Hour,
+			want:        24 * time.Hour,
+			wantClamped: false,
+		},
+		{
+			name:        "TTL above max is clamped",
+			maxTTL:      "1w",
+			ttl:         9999 * 7 * 24 * time.Hour,
+			want:        7 * 24 * time.Hour,
+			wantClamped: true,
+		},
+		{
+			name:        "forever is clamped by default",
+			maxTTL:      "1w",
+			ttl:         -1,
+			want:        7 * 24 * time.Hour,
+			wantClamped: true,
+		},
+		{
+			name:            "forever is kept when allowed",
+			maxTTL:          "1w",
+			allowForeverTTL: true,
+			ttl:             -1,
+			want:            -1,
+			wantClamped:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				config: &Config{
+					MaxTTL:          tt.maxTTL,
+					AllowForeverTTL: tt.allowForeverTTL,
+				},
+			}
+
+			pod := newUnstructuredPod("test-pod", "default", time.Now(), nil)
+			got, clamped := j.clampTTL(pod, tt.ttl)
+			if got != tt.want {
+				t.Errorf("clampTTL() = %v, want %v", got, tt.want)
+			}
+			if clamped != tt.wantClamped {
+				t.Errorf("clampTTL() clamped = %v, want %v", clamped, tt.wantClamped)
+			}
+		})
+	}
+}
+
+func TestHandleTTLClampsToMaxTTL(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun: true,
+			MaxTTL: "1w",
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	pod := newUnstructuredPod("test-pod", "default", time.Now().Add(-2*7*24*time.Hour), map[string]string{
+		TTLAnnotation: "9999w",
+	})
+
+	counter := make(map[string]int)
+	if err := j.handleTTL(context.Background(), pod, counter); err != nil {
+		t.Fatalf("handleTTL() error = %v", err)
+	}
+
+	if counter["pods-deleted"] != 1 {
+		t.Errorf("Expected TTL to be clamped and pod to be deleted, got counter %v", counter)
+	}
+}
+
+func TestHandleResourceExpiryTakesPrecedenceOverTTL(t *testing.T) {
+	pod := newUnstructuredPod("test-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{
+		TTLAnnotation:    "1h",
+		ExpiryAnnotation: time.Now().Add(-1 * time.Hour).Format(time.RFC3339),
+	})
+
+	clientset := fake.NewSimpleClientset()
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod.DeepCopy()),
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	counter := make(map[string]int)
+	if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+		t.Fatalf("handleResource() error = %v", err)
+	}
+
+	if counter["pods-deleted"] != 1 {
+		t.Errorf("Expected exactly one deletion to be counted, got %d", counter["pods-deleted"])
+	}
+
+	events, err := clientset.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("Expected exactly one event, got %d", len(events.Items))
+	}
+	if events.Items[0].Reason != "ExpiryTimeReached" {
+		t.Errorf("Expected event reason ExpiryTimeReached, got %s", events.Items[0].Reason)
+	}
+}
+
+func TestHandleExpiryDeleteNotification(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiry     time.Time
+		wantNotify bool
+	}{
+		{
+			name:       "expiry within notification lead time",
+			expiry:     time.Now().Add(30 * time.Minute),
+			wantNotify: true,
+		},
+		{
+			name:       "expiry beyond notification lead time",
+			expiry:     time.Now().Add(3 * time.Hour),
+			wantNotify: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("test-pod", "default", time.Now().Add(-1*time.Hour), map[string]string{
+				ExpiryAnnotation: tt.expiry.Format(time.RFC3339),
+			})
+
+			clientset := fake.NewSimpleClientset()
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod.DeepCopy())
+			j := &Janitor{
+				client:        clientset,
+				dynamicClient: dynamicClient,
+				config: &Config{
+					DeleteNotification: 3600,
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			// Handle the resource twice to verify the notification is only sent once
+			for i := 0; i < 2; i++ {
+				if err := j.handleExpiry(context.Background(), pod, make(map[string]int)); err != nil {
+					t.Fatalf("handleExpiry() error = %v", err)
+				}
+			}
+
+			events, err := clientset.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("Failed to list events: %v", err)
+			}
+
+			wantEvents := 0
+			if tt.wantNotify {
+				wantEvents = 1
+			}
+			if len(events.Items) != wantEvents {
+				t.Fatalf("Expected %d notification events, got %d", wantEvents, len(events.Items))
+			}
+			if tt.wantNotify && events.Items[0].Reason != "DeleteNotification" {
+				t.Errorf("Expected event reason DeleteNotification, got %s", events.Items[0].Reason)
+			}
+
+			// Verify the notified annotation was persisted in the cluster
+			stored, err := dynamicClient.Resource(resourceGVR(pod)).Namespace("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get pod: %v", err)
+			}
+			_, persisted := stored.GetAnnotations()[NotifiedAnnotation]
+			if persisted != tt.wantNotify {
+				t.Errorf("Expected notified annotation persisted = %v, got %v", tt.wantNotify, persisted)
+			}
+		})
+	}
+}
+
+func TestProcessResourcesSameKindDifferentGroups(t *testing.T) {
+	var resources []metav1.Object
+	for _, group := range []string{"example.com", "other.example.com"} {
+		widget := &unstructured.Unstructured{}
+		widget.SetAPIVersion(group + "/v1")
+		widget.SetKind("Widget")
+		widget.SetName("test-widget")
+		widget.SetNamespace("default")
+		widget.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-2 * time.Hour)))
+		widget.SetAnnotations(map[string]string{TTLAnnotation: "1h"})
+		resources = append(resources, widget)
+	}
+
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			Parallelism:       2,
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	counter := make(map[string]int)
+	j.processResourcesInParallel(context.Background(), resources, counter, make(map[string]bool))
+
+	if counter["resources-processed"] != 2 {
+		t.Errorf("Expected both widgets to be processed, got %d", counter["resources-processed"])
+	}
+	for _, name := range []string{"widgets.example.com-deleted", "widgets.other.example.com-deleted"} {
+		if counter[name] != 1 {
+			t.Errorf("Expected counter %s to be 1, got counter %v", name, counter)
+		}
+	}
+}
+
+func TestShouldProcessResourceTypeGroupQualified(t *testing.T) {
+	networkingIngresses := ResourceType{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress", Plural: "ingresses", Namespaced: true}
+	customIngresses := ResourceType{Group: "example.com", Version: "v1", Kind: "Ingress", Plural: "ingresses", Namespaced: true}
+	pods := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+
+	tests := []struct {
+		name         string
+		include      []string
+		exclude      []string
+		resourceType ResourceType
+		want         bool
+	}{
+		{
+			name:         "bare plural matches any group",
+			include:      []string{"ingresses"},
+			resourceType: customIngresses,
+			want:         true,
+		},
+		{
+			name:         "group-qualified include matches its group",
+			include:      []string{"ingresses.networking.k8s.io"},
+			resourceType: networkingIngresses,
+			want:         true,
+		},
+		{
+			name:         "group-qualified include does not match other groups",
+			include:      []string{"ingresses.networking.k8s.io"},
+			resourceType: customIngresses,
+			want:         false,
+		},
+		{
+			name:         "group-qualified exclude only excludes its group",
+			include:      []string{"all"},
+			exclude:      []string{"ingresses.example.com"},
+			resourceType: networkingIngresses,
+			want:         true,
+		},
+		{
+			name:         "group-qualified exclude excludes its group",
+			include:      []string{"all"},
+			exclude:      []string{"ingresses.example.com"},
+			resourceType: customIngresses,
+			want:         false,
+		},
+		{
+			name:         "core resources match the bare plural",
+			include:      []string{"pods"},
+			resourceType: pods,
+			want:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				config: &Config{
+					IncludeResources: tt.include,
+					ExcludeResources: tt.exclude,
+				},
+			}
+			if got := j.shouldProcessResourceType(tt.resourceType); got != tt.want {
+				t.Errorf("shouldProcessResourceType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesResourceFilterGroupQualified(t *testing.T) {
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("example.com/v1")
+	widget.SetKind("Widget")
+	widget.SetName("test-widget")
+	widget.SetNamespace("default")
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    bool
+	}{
+		{
+			name:    "group-qualified include",
+			include: []string{"widgets.example.com"},
+			want:    true,
+		},
+		{
+			name:    "include for another group",
+			include: []string{"widgets.other.example.com"},
+			want:    false,
+		},
+		{
+			name:    "group-qualified exclude",
+			include: []string{"all"},
+			exclude: []string{"widgets.example.com"},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				config: &Config{
+					IncludeResources:  tt.include,
+					ExcludeResources:  tt.exclude,
+					IncludeNamespaces: []string{"all"},
+				},
+			}
+			if got := j.matchesResourceFilter(widget); got != tt.want {
+				t.Errorf("matchesResourceFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldProcessResourceTypeGroups(t *testing.T) {
+	jobs := ResourceType{Group: "batch", Version: "v1", Kind: "Job", Plural: "jobs", Namespaced: true}
+	widgets := ResourceType{Group: "example.com", Version: "v1", Kind: "Widget", Plural: "widgets", Namespaced: true}
+	pods := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+
+	tests := []struct {
+		name          string
+		includeGroups []string
+		excludeGroups []string
+		customOnly    bool
+		builtinOnly   bool
+		include       []string
+		want          map[string]bool
+	}{
+		{
+			name:          "include only batch",
+			includeGroups: []string{"batch"},
+			include:       []string{"all"},
+			want:          map[string]bool{"jobs": true, "widgets": false, "pods": false},
+		},
+		{
+			name:          "exclude example.com",
+			includeGroups: []string{"all"},
+			excludeGroups: []string{"example.com"},
+			include:       []string{"all"},
+			want:          map[string]bool{"jobs": true, "widgets": false, "pods": true},
+		},
+		{
+			name:          "core group addressed as core",
+			includeGroups: []string{"core"},
+			include:       []string{"all"},
+			want:          map[string]bool{"jobs": false, "widgets": false, "pods": true},
+		},
+		{
+			name:          "composes with resource filters",
+			includeGroups: []string{"batch", "example.com"},
+			include:       []string{"jobs", "pods"},
+			want:          map[string]bool{"jobs": true, "widgets": false, "pods": false},
+		},
+		{
+			name:          "custom resources only",
+			includeGroups: []string{"all"},
+			customOnly:    true,
+			include:       []string{"all"},
+			want:          map[string]bool{"jobs": false, "widgets": true, "pods": false},
+		},
+		{
+			name:          "built-in resources only",
+			includeGroups: []string{"all"},
+			builtinOnly:   true,
+			include:       []string{"all"},
+			want:          map[string]bool{"jobs": true, "widgets": false, "pods": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				config: &Config{
+					IncludeResources:     tt.include,
+					IncludeGroups:        tt.includeGroups,
+					ExcludeGroups:        tt.excludeGroups,
+					CustomResourcesOnly:  tt.customOnly,
+					BuiltinResourcesOnly: tt.builtinOnly,
+				},
+			}
+			for _, rt := range []ResourceType{jobs, widgets, pods} {
+				if got := j.shouldProcessResourceType(rt); got != tt.want[rt.Plural] {
+					t.Errorf("shouldProcessResourceType(%s) = %v, want %v", rt.Plural, got, tt.want[rt.Plural])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleResourceNamespaceTTL(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "temp",
+			Annotations: map[string]string{TTLAnnotation: "1h"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+	)
+	j := &Janitor{
+		client: clientset,
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		wantDeleted bool
+	}{
+		{"inherits namespace TTL", "temp", nil, true},
+		{"inherits namespace TTL with other annotations", "temp", map[string]string{"team": "a"}, true},
+		{"own TTL takes precedence", "temp", map[string]string{TTLAnnotation: "forever"}, false},
+		{"namespace without TTL", "default", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := make(map[string]int)
+			pod := newUnstructuredPod("pod", tt.namespace, old, tt.annotations)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+			if deleted := counter["pods-deleted"] == 1; deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+
+	// Namespaces are only fetched once per run
+	gets := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "get" && action.GetResource().Resource == "namespaces" {
+			gets++
+		}
+	}
+	if gets != 2 {
+		t.Errorf("Expected each namespace to be fetched once, got %d gets", gets)
+	}
+}
+
+func TestDeleteNamespaceContentsFirst(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "namespaces", Kind: "Namespace", Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName("temp")
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetName("settings")
+	configMap.SetNamespace("temp")
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "pods"}:       "PodList",
+			{Version: "v1", Resource: "configmaps"}: "ConfigMapList",
+		},
+		ns, configMap,
+		newUnstructuredPod("pod-1", "temp", time.Now(), nil),
+		newUnstructuredPod("pod-2", "temp", time.Now(), nil),
+		newUnstructuredPod("other-pod", "default", time.Now(), nil),
+	)
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			DeleteNamespaceContents: true,
+			IncludeResources:        []string{"all"},
+			ExcludeResources:        []string{"configmaps"},
+			IncludeNamespaces:       []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "temp"}}
+	if err := j.deleteResource(context.Background(), namespace, "", ""); err != nil {
+		t.Fatalf("deleteResource() error = %v", err)
+	}
+
+	var deleted []string
+	for _, action := range dynamicClient.Actions() {
+		if del, ok := action.(k8stesting.DeleteAction); ok {
+			deleted = append(deleted, del.GetResource().Resource+"/"+del.GetName())
+		}
+	}
+
+	want := []string{"pods/pod-1", "pods/pod-2", "namespaces/temp"}
+	if strings.Join(deleted, ",") != strings.Join(want, ",") {
+		t.Errorf("Deleted %v, want %v", deleted, want)
+	}
+
+	// The contents are part of the planned deletions
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("temp").Create(context.Background(),
+		newUnstructuredPod("pod-3", "temp", time.Now(), nil), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create pod: %v", err)
+	}
+	j.planning.Store(true)
+	if err := j.deleteResource(context.Background(), namespace, "", ""); err != nil {
+		t.Fatalf("deleteResource() error = %v", err)
+	}
+	j.planning.Store(false)
+	planned := append(j.decisions, Decision{Kind: "Namespace", Name: "temp", Decision: DecisionDelete})
+	if len(planned) != 2 || planned[0].Name != "pod-3" {
+		t.Fatalf("Expected the contents of the namespace to be planned, got %+v", planned)
+	}
+
+	// Contents that were not confirmed keep the namespace
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("temp").Create(context.Background(),
+		newUnstructuredPod("pod-4", "temp", time.Now(), nil), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create pod: %v", err)
+	}
+	j.ConfirmDeletions(planned)
+	dynamicClient.ClearActions()
+	if err := j.deleteResource(context.Background(), namespace, "", ""); !errors.Is(err, errNotConfirmed) {
+		t.Fatalf("deleteResource() error = %v, want %v", err, errNotConfirmed)
+	}
+	for _, action := range dynamicClient.Actions() {
+		if del, ok := action.(k8stesting.DeleteAction); ok && del.GetName() != "pod-3" {
+			t.Errorf("Expected only the confirmed pod to be deleted, got %s", del.GetName())
+		}
+	}
+}
+
+func TestNewWithClients(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"},
+		newUnstructuredPod("expired-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"}),
+		newUnstructuredPod("valid-pod", "default", time.Now(), map[string]string{TTLAnnotation: "1h"}),
+	)
+
+	config := NewConfig()
+	config.IncludeResources = []string{"all"}
+	config.IncludeNamespaces = []string{"all"}
+	config.NotifyBackends = nil
+
+	j, err := NewWithClients(config, clientset, dynamicClient)
+	if err != nil {
+		t.Fatalf("NewWithClients() error = %v", err)
+	}
+
+	result, err := j.CleanUp(context.Background())
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if result.Deleted["pods"] != 1 {
+		t.Errorf("Expected 1 deleted pod, got %v", result.Deleted)
+	}
+
+	pods, err := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list pods: %v", err)
+	}
+	if len(pods.Items) != 1 || pods.Items[0].GetName() != "valid-pod" {
+		t.Errorf("Expected only valid-pod to remain, got %d pods", len(pods.Items))
+	}
+}
+
+func TestGVRForUsesDiscoveredPlural(t *testing.T) {
+	client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "networking.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "ingresses", Kind: "Ingress", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	j := &Janitor{config: &Config{}}
+	j.SetDiscoveryClient(client)
+	if _, err := j.getResourceTypes(); err != nil {
+		t.Fatalf("getResourceTypes() error = %v", err)
+	}
+
+	ingress := &unstructured.Unstructured{}
+	ingress.SetAPIVersion("networking.k8s.io/v1")
+	ingress.SetKind("Ingress")
+	if got := j.gvrFor(ingress).Resource; got != "ingresses" {
+		t.Errorf("gvrFor(Ingress).Resource = %q, want ingresses", got)
+	}
+
+	// Kinds missing from discovery fall back to the guessed plural
+	widget := &unstructured.Unstructured{}
+	widget.SetAPIVersion("example.com/v1")
+	widget.SetKind("Widget")
+	if got := j.gvrFor(widget).Resource; got != "widgets" {
+		t.Errorf("gvrFor(Widget).Resource = %q, want widgets", got)
+	}
+}
+
+func TestDeleteResourceWithoutDeleteVerb(t *testing.T) {
+	client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "example.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "widgetviews", Kind: "WidgetView", Namespaced: true, Verbs: []string{"get", "list"}},
+			},
+		},
+	}
+
+	expired := map[string]string{TTLAnnotation: "1h"}
+	widgetView := newUnstructuredPod("view", "default", time.Now().Add(-2*time.Hour), expired)
+	widgetView.SetAPIVersion("example.com/v1")
+	widgetView.SetKind("WidgetView")
+	pod := newUnstructuredPod("pod", "default", time.Now().Add(-2*time.Hour), expired)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), widgetView.DeepCopy(), pod.DeepCopy())
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+	j.SetDiscoveryClient(client)
+	if _, err := j.getResourceTypes(); err != nil {
+		t.Fatalf("getResourceTypes() error = %v", err)
+	}
+
+	counter := make(map[string]int)
+	for _, obj := range []*unstructured.Unstructured{widgetView, pod} {
+		if err := j.handleResource(context.Background(), obj, counter, make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource(%s) error = %v", obj.GetKind(), err)
+		}
+	}
+
+	for _, action := range dynamicClient.Actions() {
+		if action.GetVerb() == "delete" && action.GetResource().Resource != "pods" {
+			t.Errorf("Expected no delete of resources without the delete verb, got a delete of %s", action.GetResource().Resource)
+		}
+	}
+	result := newCleanupResult(counter)
+	if result.Errors != 0 || result.Skipped[SkipReasonNotDeletable] != 1 || result.Deleted["pods"] != 1 {
+		t.Errorf("Expected the pod to be deleted and the widget view to be skipped, got %+v", result)
+	}
+}
+
+func TestCleanUpRunTimeout(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-1"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-2"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-3"}},
+	)
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"})
+
+	// Simulate a slow API server
+	lists := 0
+	dynamicClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lists++
+		time.Sleep(100 * time.Millisecond)
+		return false, nil, nil
+	})
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			RunTimeout:        150 * time.Millisecond,
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	start := time.Now()
+	if _, err := j.CleanUp(context.Background()); err == nil {
+		t.Fatal("Expected CleanUp() to fail when the run timeout is exceeded")
+	}
+	if lists >= 3 {
+		t.Errorf("Expected the run to be cancelled before listing all namespaces, got %d lists", lists)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("Expected the run to stop at the deadline, took %v", elapsed)
+	}
+}
+
+func TestHandleTTLLastActivity(t *testing.T) {
+	tests := []struct {
+		name         string
+		lastActivity string
+		wantDeleted  bool
+	}{
+		{
+			name:         "recently touched resource is spared",
+			lastActivity: time.Now().Add(-10 * time.Minute).Format(time.RFC3339),
+			wantDeleted:  false,
+		},
+		{
+			name:         "activity before the deployment time is ignored",
+			lastActivity: time.Now().Add(-3 * time.Hour).Format(time.RFC3339),
+			wantDeleted:  true,
+		},
+		{
+			name:         "invalid activity time is ignored",
+			lastActivity: "yesterday",
+			wantDeleted:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				client: fake.NewSimpleClientset(),
+				config: &Config{
+					DryRun:                 true,
+					IncludeResources:       []string{"all"},
+					IncludeNamespaces:      []string{"all"},
+					LastActivityAnnotation: "example.com/last-activity",
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			counter := make(map[string]int)
+			pod := newUnstructuredPod("pod", "default", time.Now().Add(-2*time.Hour), map[string]string{
+				TTLAnnotation:               "1h",
+				"example.com/last-activity": tt.lastActivity,
+			})
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+			if deleted := counter["pods-deleted"] == 1; deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestDeleteResourceServerDryRun(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-dry-run=server"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if !config.DryRun || !config.DryRunServer {
+		t.Fatalf("Expected server-side dry run, got DryRun=%v DryRunServer=%v", config.DryRun, config.DryRunServer)
+	}
+
+	pod := newUnstructuredPod("pod", "default", time.Now(), nil)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+	var deleteOptions []metav1.DeleteOptions
+	j := &Janitor{
+		client:        fake.NewSimpleClientset(),
+		dynamicClient: deleteOptionsRecorder{Interface: dynamicClient, options: &deleteOptions},
+		config:        config,
+		cache:         make(map[string]interface{}),
+	}
+
+	if err := j.deleteResource(context.Background(), pod, "", ""); err != nil {
+		t.Fatalf("deleteResource() error = %v", err)
+	}
+
+	if len(deleteOptions) != 1 {
+		t.Fatalf("Expected one delete request, got %d", len(deleteOptions))
+	}
+	if dryRun := deleteOptions[0].DryRun; !reflect.DeepEqual(dryRun, []string{metav1.DryRunAll}) {
+		t.Errorf("Expected DryRun %v in the delete options, got %v", []string{metav1.DryRunAll}, dryRun)
+	}
+
+	// Admission webhook denials are surfaced as errors
+	dynamicClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("admission webhook denied the request")
+	})
+	if err := j.deleteResource(context.Background(), pod, "", ""); err == nil {
+		t.Error("Expected the webhook denial to be returned")
+	}
+}
+
+// deleteOptionsRecorder records the options of namespaced deletes, which the
+// fake dynamic client does not keep in its actions
+type deleteOptionsRecorder struct {
+	dynamic.Interface
+	options *[]metav1.DeleteOptions
+}
+
+func (r deleteOptionsRecorder) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return deleteOptionsResource{NamespaceableResourceInterface: r.Interface.Resource(gvr), options: r.options}
+}
+
+type deleteOptionsResource struct {
+	dynamic.NamespaceableResourceInterface
+	options *[]metav1.DeleteOptions
+}
+
+func (r deleteOptionsResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return deleteOptionsNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), options: r.options}
+}
+
+type deleteOptionsNamespacedResource struct {
+	dynamic.ResourceInterface
+	options *[]metav1.DeleteOptions
+}
+
+func (r deleteOptionsNamespacedResource) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	*r.options = append(*r.options, options)
+	return r.ResourceInterface.Delete(ctx, name, options, subresources...)
+}
+
+func TestDeleteResourceMaxConcurrentDeletes(t *testing.T) {
+	const maxConcurrentDeletes = 2
+
+	var pods []runtime.Object
+	for i := 0; i < 8; i++ {
+		pods = append(pods, newUnstructuredPod(fmt.Sprintf("pod-%d", i), "default", time.Now(), nil))
+	}
+	tracker := &concurrencyTracker{}
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		dynamicClient: slowDeleteClient{
+			Interface: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pods...),
+			tracker:   tracker,
+		},
+		config: &Config{MaxConcurrentDeletes: maxConcurrentDeletes},
+		cache:  make(map[string]interface{}),
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(pods))
+	for _, pod := range pods {
+		wg.Add(1)
+		go func(pod metav1.Object) {
+			defer wg.Done()
+			errs <- j.deleteResource(context.Background(), pod, "", "")
+		}(pod.(metav1.Object))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("deleteResource() error = %v", err)
+		}
+	}
+
+	if got := tracker.max.Load(); got > maxConcurrentDeletes {
+		t.Errorf("Expected at most %d concurrent deletes, got %d", maxConcurrentDeletes, got)
+	}
+	if got := tracker.max.Load(); got < 2 {
+		t.Errorf("Expected deletes to run concurrently, got at most %d at once", got)
+	}
+}
+
+// concurrencyTracker records the highest number of calls in flight at once
+type concurrencyTracker struct {
+	inFlight atomic.Int32
+	max      atomic.Int32
+}
+
+func (c *concurrencyTracker) track() func() {
+	n := c.inFlight.Add(1)
+	for {
+		highest := c.max.Load()
+		if n <= highest || c.max.CompareAndSwap(highest, n) {
+			break
+		}
+	}
+	return func() { c.inFlight.Add(-1) }
+}
+
+// slowDeleteClient holds namespaced deletes for a while to track how many of
+// them run at once, as the fake dynamic client serializes all its calls
+type slowDeleteClient struct {
+	dynamic.Interface
+	tracker *concurrencyTracker
+}
+
+func (c slowDeleteClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return slowDeleteResource{NamespaceableResourceInterface: c.Interface.Resource(gvr), tracker: c.tracker}
+}
+
+type slowDeleteResource struct {
+	dynamic.NamespaceableResourceInterface
+	tracker *concurrencyTracker
+}
+
+func (r slowDeleteResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return slowDeleteNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), tracker: r.tracker}
+}
+
+type slowDeleteNamespacedResource struct {
+	dynamic.ResourceInterface
+	tracker *concurrencyTracker
+}
+
+func (r slowDeleteNamespacedResource) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	done := r.tracker.track()
+	defer done()
+	time.Sleep(20 * time.Millisecond)
+	return r.ResourceInterface.Delete(ctx, name, options, subresources...)
+}
+
+func TestDeleteResourceWaitAfterDeleteCancelled(t *testing.T) {
+	pod := newUnstructuredPod("pod", "default", time.Now(), nil)
+	j := &Janitor{
+		client:        fake.NewSimpleClientset(),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod),
+		config:        &Config{WaitAfterDelete: 60},
+		cache:         make(map[string]interface{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := j.deleteResource(ctx, pod, "", ""); err != nil {
+		t.Fatalf("deleteResource() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected deleteResource to return promptly after cancellation, took %v", elapsed)
+	}
+}
+
+func TestHandleResourceExcludeSelectors(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{
+		"-include-resources", "all",
+		"-include-namespaces", "all",
+		"-exclude-label", "environment=production",
+		"-exclude-label", "backup=true,tier=db",
+		"-exclude-annotation", "janitor/keep",
+		"-exclude-jmespath", "metadata.labels.critical == 'true'",
+	}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		wantSkip    string
+	}{
+		{
+			name:     "production label",
+			labels:   map[string]string{"environment": "production"},
+			wantSkip: SkipReasonExcludedLabel,
+		},
+		{
+			name:     "all labels of a selector",
+			labels:   map[string]string{"backup": "true", "tier": "db"},
+			wantSkip: SkipReasonExcludedLabel,
+		},
+		{
+			name:   "only some labels of a selector",
+			labels: map[string]string{"backup": "true", "tier": "web"},
+		},
+		{
+			name:        "annotation key with any value",
+			annotations: map[string]string{"janitor/keep": "until friday"},
+			wantSkip:    SkipReasonExcludedAnnotation,
+		},
+		{
+			name:     "matching JMESPath expression",
+			labels:   map[string]string{"critical": "true"},
+			wantSkip: SkipReasonExcludedJMESPath,
+		},
+		{
+			name:   "other labels",
+			labels: map[string]string{"environment": "staging", "critical": "false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("pod", "default", time.Now().Add(-2*time.Hour), nil)
+			pod.SetLabels(tt.labels)
+			annotations := map[string]string{TTLAnnotation: "1h"}
+			for k, v := range tt.annotations {
+				annotations[k] = v
+			}
+			pod.SetAnnotations(annotations)
+
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+			j := &Janitor{
+				client:        fake.NewSimpleClientset(),
+				dynamicClient: dynamicClient,
+				config:        config,
+				cache:         make(map[string]interface{}),
+			}
+
+			counter := make(map[string]int)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			result := newCleanupResult(counter)
+			if tt.wantSkip != "" {
+				if result.Skipped[tt.wantSkip] != 1 || result.Processed != 0 || len(dynamicClient.Actions()) != 0 {
+					t.Errorf("Expected the expired pod to be untouched with reason %s, got %+v and actions %v", tt.wantSkip, result, dynamicClient.Actions())
+				}
+			} else if result.Deleted["pods"] != 1 {
+				t.Errorf("Expected the pod to be deleted, got %+v", result)
+			}
+		})
+	}
+
+	config.ExcludeLabels = []string{"=production"}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for an exclude selector without a key")
+	}
+
+	config.ExcludeLabels = nil
+	config.ExcludeJMESPaths = []string{"metadata.labels.critical =="}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for an invalid exclude JMESPath expression")
+	}
+}
+
+func TestHandleResourceCreationWindow(t *testing.T) {
+	now := time.Now()
+	config := &Config{
+		IncludeResources:  []string{"all"},
+		IncludeNamespaces: []string{"all"},
+		CreatedAfter:      now.Add(-30 * 24 * time.Hour).Format(time.RFC3339),
+		CreatedBefore:     now.Add(-7 * 24 * time.Hour).Format(time.RFC3339),
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		created  time.Time
+		wantSkip bool
+	}{
+		{name: "created within the window", created: now.Add(-10 * 24 * time.Hour)},
+		{name: "created before the window", created: now.Add(-60 * 24 * time.Hour), wantSkip: true},
+		{name: "created after the window", created: now.Add(-2 * time.Hour), wantSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("pod", "default", tt.created, map[string]string{TTLAnnotation: "1h"})
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+			j := &Janitor{
+				client:        fake.NewSimpleClientset(),
+				dynamicClient: dynamicClient,
+				config:        config,
+				cache:         make(map[string]interface{}),
+			}
+
+			counter := make(map[string]int)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			result := newCleanupResult(counter)
+			if tt.wantSkip {
+				if result.Skipped[SkipReasonCreationWindow] != 1 || result.Processed != 0 || len(dynamicClient.Actions()) != 0 {
+					t.Errorf("Expected the expired pod to be untouched with reason %s, got %+v and actions %v", SkipReasonCreationWindow, result, dynamicClient.Actions())
+				}
+			} else if result.Deleted["pods"] != 1 {
+				t.Errorf("Expected the pod to be deleted, got %+v", result)
+			}
+		})
+	}
+
+	config.CreatedAfter, config.CreatedBefore = config.CreatedBefore, config.CreatedAfter
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for a created-after time that is not before created-before")
+	}
+
+	config.CreatedAfter = "last week"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for an invalid created-after time")
+	}
+}
+
+func TestHandleResourceWithoutCreationTimestamp(t *testing.T) {
+	rule := Rule{ID: "test-pods", Resources: []string{"pods"}, JMESPath: "metadata.labels.environment == 'test'", TTL: "1h"}
+	if err := rule.ValidateAndCompile(); err != nil {
+		t.Fatalf("Failed to compile rule: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		labels      map[string]string
+		wantDeleted bool
+	}{
+		{
+			name:        "TTL annotation",
+			annotations: map[string]string{TTLAnnotation: "1h"},
+		},
+		{
+			name:   "matching rule",
+			labels: map[string]string{"environment": "test"},
+		},
+		{
+			name: "deployment time annotation",
+			annotations: map[string]string{
+				TTLAnnotation: "1h",
+				"deployed-at": time.Now().Add(-2 * time.Hour).Format(time.RFC3339),
+			},
+			wantDeleted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("pod", "default", time.Time{}, tt.annotations)
+			pod.SetLabels(tt.labels)
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+			j := &Janitor{
+				client:        fake.NewSimpleClientset(),
+				dynamicClient: dynamicClient,
+				config: &Config{
+					IncludeResources:         []string{"all"},
+					IncludeNamespaces:        []string{"all"},
+					DeploymentTimeAnnotation: "deployed-at",
+					Rules:                    []Rule{rule},
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			counter := make(map[string]int)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			result := newCleanupResult(counter)
+			if tt.wantDeleted {
+				if result.Deleted["pods"] != 1 {
+					t.Errorf("Expected the pod to be deleted, got %+v", result)
+				}
+				return
+			}
+			if result.Skipped[SkipReasonNoCreationTimestamp] != 1 || result.Deleted["pods"] != 0 || len(dynamicClient.Actions()) != 0 {
+				t.Errorf("Expected the pod without creation timestamp to be kept, got %+v and actions %v", result, dynamicClient.Actions())
+			}
+			if !strings.Contains(buf.String(), "Warning: not deleting default/pod") {
+				t.Errorf("Expected a warning, got %q", buf.String())
+			}
+		})
+	}
+}
+
+func TestCleanUpPaused(t *testing.T) {
+	for _, paused := range []bool{true, false} {
+		objects := []runtime.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}}
+		if paused {
+			objects = append(objects, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "pause", Namespace: "kube-janitor"}})
+		}
+		clientset := fake.NewSimpleClientset(objects...)
+		clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{
+					{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+				},
+			},
+		}
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"},
+			newUnstructuredPod("expired-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"}),
+		)
+
+		config := NewConfig()
+		config.NotifyBackends = nil
+		if paused {
+			// A paused run doesn't mark resources either
+			config.SoftDeleteGrace = time.Hour
+		}
+		j, err := NewWithClients(config, clientset, dynamicClient)
+		if err != nil {
+			t.Fatalf("NewWithClients() error = %v", err)
+		}
+		result, err := j.CleanUp(context.Background())
+		if err != nil {
+			t.Fatalf("CleanUp() error = %v", err)
+		}
+
+		deletes, patches := 0, 0
+		for _, action := range dynamicClient.Actions() {
+			switch action.GetVerb() {
+			case "delete":
+				deletes++
+			case "patch", "update":
+				patches++
+			}
+		}
+		if paused && (deletes != 0 || patches != 0) {
+			t.Errorf("Expected no deletions or marks while paused, got %d deletions and %d marks", deletes, patches)
+		}
+		if paused && (result.Deleted["pods"] != 0 || result.Skipped[SkipReasonPaused] != 1) {
+			t.Errorf("Expected the expired pod to be skipped as paused and not counted as deleted, got %+v", result)
+		}
+		if !paused && deletes != 1 {
+			t.Errorf("Expected the expired pod to be deleted when not paused, got %d deletions", deletes)
+		}
+	}
+}
+
+func TestTTLBaseFields(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-ttl-base-fields", "jobs = status.completionTime, pods=status.containerStatuses[].state.terminated.finishedAt,", "-dry-run"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	wantFields := map[string]string{
+		"jobs": "status.completionTime",
+		"pods": "status.containerStatuses[].state.terminated.finishedAt",
+	}
+	if !reflect.DeepEqual(config.TTLBaseFields, wantFields) {
+		t.Fatalf("Expected TTL base fields %v, got %v", wantFields, config.TTLBaseFields)
+	}
+
+	newJob := func(completionTime string) *unstructured.Unstructured {
+		job := &unstructured.Unstructured{}
+		job.SetAPIVersion("batch/v1")
+		job.SetKind("Job")
+		job.SetName("job")
+		job.SetNamespace("default")
+		job.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-3 * time.Hour)))
+		job.SetAnnotations(map[string]string{TTLAnnotation: "1h"})
+		if completionTime != "" {
+			unstructured.SetNestedField(job.Object, completionTime, "status", "completionTime")
+		}
+		return job
+	}
+	newPod := func(finishedAt ...string) *unstructured.Unstructured {
+		pod := newUnstructuredPod("pod", "default", time.Now().Add(-3*time.Hour), map[string]string{TTLAnnotation: "1h"})
+		var statuses []interface{}
+		for _, f := range finishedAt {
+			statuses = append(statuses, map[string]interface{}{
+				"state": map[string]interface{}{"terminated": map[string]interface{}{"finishedAt": f}},
+			})
+		}
+		unstructured.SetNestedSlice(pod.Object, statuses, "status", "containerStatuses")
+		return pod
+	}
+
+	tests := []struct {
+		name        string
+		obj         *unstructured.Unstructured
+		wantDeleted bool
+	}{
+		{
+			name:        "recently completed job is kept",
+			obj:         newJob(time.Now().Add(-30 * time.Minute).Format(time.RFC3339)),
+			wantDeleted: false,
+		},
+		{
+			name:        "job completed more than the TTL ago is deleted",
+			obj:         newJob(time.Now().Add(-2 * time.Hour).Format(time.RFC3339)),
+			wantDeleted: true,
+		},
+		{
+			name:        "job without completion time falls back to creation time",
+			obj:         newJob(""),
+			wantDeleted: true,
+		},
+		{
+			name:        "pod uses the latest finished container",
+			obj:         newPod(time.Now().Add(-2*time.Hour).Format(time.RFC3339), time.Now().Add(-10*time.Minute).Format(time.RFC3339)),
+			wantDeleted: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				client: fake.NewSimpleClientset(),
+				config: config,
+				cache:  make(map[string]interface{}),
+			}
+
+			counter := make(map[string]int)
+			if err := j.handleResource(context.Background(), tt.obj, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+			deleted := newCleanupResult(counter).Deleted[j.counterName(tt.obj)] == 1
+			if deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestIncludeClusterResourcesList(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "persistentvolumes", Kind: "PersistentVolume", Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "rbac.authorization.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "clusterroles", Kind: "ClusterRole", Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "storage.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "storageclasses", Kind: "StorageClass", Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	newClusterResource := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-2 * time.Hour)))
+		obj.SetAnnotations(map[string]string{TTLAnnotation: "1h"})
+		return obj
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "persistentvolumes"}:                                "PersistentVolumeList",
+			{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}: "ClusterRoleList",
+			{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"}:          "StorageClassList",
+		},
+		newClusterResource("v1", "PersistentVolume", "volume"),
+		newClusterResource("rbac.authorization.k8s.io/v1", "ClusterRole", "role"),
+		newClusterResource("storage.k8s.io/v1", "StorageClass", "standard"),
+	)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-include-cluster-resources=clusterroles.rbac.authorization.k8s.io,storageclasses"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+	config.NotifyBackends = nil
+
+	j, err := NewWithClients(config, clientset, dynamicClient)
+	if err != nil {
+		t.Fatalf("NewWithClients() error = %v", err)
+	}
+	result, err := j.CleanUp(context.Background())
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+
+	// Irregular plurals like storageclasses are matched by their discovered name
+	want := map[string]int{"clusterroles.rbac.authorization.k8s.io": 1, "storageclasses.storage.k8s.io": 1}
+	if !reflect.DeepEqual(result.Deleted, want) {
+		t.Errorf("Expected deletions %v, got %v", want, result.Deleted)
+	}
+
+	// The bare flag still includes all cluster-scoped resources
+	if err := fs.Parse([]string{"-include-cluster-resources"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if !config.IncludeClusterResources || len(config.ClusterResources) != 0 {
+		t.Errorf("Expected all cluster resources to be included, got %v", config.ClusterResources)
+	}
+
+	// Spaces and empty items of the list are dropped
+	if err := fs.Parse([]string{"-include-cluster-resources= pv, ,clusterroles"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if want := []string{"pv", "clusterroles"}; !reflect.DeepEqual(config.ClusterResources, want) {
+		t.Errorf("Expected cluster resources %v, got %v", want, config.ClusterResources)
+	}
+	if err := fs.Parse([]string{"-include-cluster-resources=,"}); err == nil {
+		t.Error("Expected an error for an empty list of cluster resources")
+	}
+}
+
+func TestResourceFiltersUseDiscoveredPlural(t *testing.T) {
+	policyGVK := schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"}
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"networkpolicies"},
+			IncludeNamespaces: []string{"all"},
+			TTLBaseFields:     map[string]string{"networkpolicies": "metadata.annotations.applied-at"},
+		},
+		cache:   make(map[string]interface{}),
+		plurals: map[schema.GroupVersionKind]string{policyGVK: "networkpolicies"},
+	}
+
+	policy := newUnstructuredPod("policy", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	policy.SetAPIVersion("networking.k8s.io/v1")
+	policy.SetKind("NetworkPolicy")
+
+	if field := j.ttlBaseField(policy); field != "metadata.annotations.applied-at" {
+		t.Errorf("ttlBaseField() = %q, want the field configured for networkpolicies", field)
+	}
+
+	counter := make(map[string]int)
+	if err := j.handleResource(context.Background(), policy, counter, make(map[string]bool)); err != nil {
+		t.Fatalf("handleResource() error = %v", err)
+	}
+	if result := newCleanupResult(counter); result.Deleted["networkpolicies.networking.k8s.io"] != 1 {
+		t.Errorf("Expected the included network policy to be deleted, got %+v", result)
+	}
+}
+
+func TestCounterNameUsesDiscoveredPlural(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1"},
+		{
+			GroupVersion: "networking.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	policy := &unstructured.Unstructured{}
+	policy.SetAPIVersion("networking.k8s.io/v1")
+	policy.SetKind("NetworkPolicy")
+	policy.SetName("deny-all")
+	policy.SetNamespace("default")
+	policy.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-2 * time.Hour)))
+	policy.SetAnnotations(map[string]string{TTLAnnotation: "1h"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}: "NetworkPolicyList",
+		},
+		policy,
+	)
+
+	config := NewConfig()
+	config.NotifyBackends = nil
+	j, err := NewWithClients(config, clientset, dynamicClient)
+	if err != nil {
+		t.Fatalf("NewWithClients() error = %v", err)
+	}
+	result, err := j.CleanUp(context.Background())
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+
+	want := map[string]int{"networkpolicies.networking.k8s.io": 1}
+	if !reflect.DeepEqual(result.Deleted, want) {
+		t.Errorf("Expected deletions %v, got %v", want, result.Deleted)
+	}
+}
+
+func TestNamespaceFlag(t *testing.T) {
+	expired := map[string]string{TTLAnnotation: "1h"}
+	created := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: expired, CreationTimestamp: created}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "persistentvolumes", Kind: "PersistentVolume", Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	newResource := func(kind, namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetNamespace(namespace)
+		obj.SetCreationTimestamp(created)
+		obj.SetAnnotations(expired)
+		return obj
+	}
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			configMaps: "ConfigMapList",
+			{Version: "v1", Resource: "persistentvolumes"}: "PersistentVolumeList",
+		},
+		newResource("ConfigMap", "team-a", "config"),
+		newResource("ConfigMap", "kube-system", "config"),
+		newResource("PersistentVolume", "", "volume"),
+	)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-namespace", "kube-system", "-include-namespaces", "team-a", "-include-cluster-resources"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+	config.NotifyBackends = nil
+
+	j, err := NewWithClients(config, clientset, dynamicClient)
+	if err != nil {
+		t.Fatalf("NewWithClients() error = %v", err)
+	}
+	result, err := j.CleanUp(context.Background())
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+
+	want := map[string]int{"configmaps": 1}
+	if !reflect.DeepEqual(result.Deleted, want) {
+		t.Errorf("Expected deletions %v, got %v", want, result.Deleted)
+	}
+	if _, err := dynamicClient.Resource(configMaps).Namespace("kube-system").Get(context.Background(), "config", metav1.GetOptions{}); err == nil {
+		t.Error("Expected the ConfigMap in kube-system to be deleted")
+	}
+	if _, err := dynamicClient.Resource(configMaps).Namespace("team-a").Get(context.Background(), "config", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the ConfigMap in team-a to be kept: %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(context.Background(), "team-a", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the namespace team-a to be kept: %v", err)
+	}
+}
+
+func TestListNamespacedResourcesRetry(t *testing.T) {
+	defer func(backoff time.Duration) { listRetryBackoff = backoff }(listRetryBackoff)
+	listRetryBackoff = time.Millisecond
+
+	tests := []struct {
+		name        string
+		failures    int
+		wantErr     bool
+		wantLists   int
+		wantFailure float64
+	}{
+		{name: "transient failure is retried", failures: 1, wantLists: 2},
+		{name: "persistent failure gives up", failures: 10, wantErr: true, wantLists: listAttempts, wantFailure: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("pod", "retry-"+tt.name, time.Now(), nil)
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"}, pod)
+
+			lists := 0
+			dynamicClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				lists++
+				if lists <= tt.failures {
+					return true, nil, fmt.Errorf("connection reset")
+				}
+				return false, nil, nil
+			})
+
+			j := &Janitor{dynamicClient: dynamicClient, config: &Config{}}
+			podType := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+			resources, err := j.listNamespacedResources(context.Background(), podType, pod.GetNamespace())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listNamespacedResources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(resources) != 1 {
+				t.Errorf("Expected 1 resource, got %d", len(resources))
+			}
+			if lists != tt.wantLists {
+				t.Errorf("Expected %d lists, got %d", tt.wantLists, lists)
+			}
+			if got := testutil.ToFloat64(listFailures.WithLabelValues("Pod", pod.GetNamespace())); got != tt.wantFailure {
+				t.Errorf("list failures = %v, want %v", got, tt.wantFailure)
+			}
+		})
+	}
+}
+
+func TestProcessResourcesRequeue(t *testing.T) {
+	defer func(delay time.Duration) { requeueDelay = delay }(requeueDelay)
+	requeueDelay = time.Millisecond
+
+	tests := []struct {
+		name        string
+		failures    int
+		wantDeletes int
+		wantDeleted int
+		wantErrors  int
+	}{
+		{name: "transient failure is requeued", failures: 1, wantDeletes: 2, wantDeleted: 1},
+		{name: "persistent failure gives up", failures: 10, wantDeletes: requeueAttempts + 1, wantErrors: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("expired", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+
+			deletes := 0
+			dynamicClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				deletes++
+				if deletes <= tt.failures {
+					return true, nil, fmt.Errorf("etcdserver: request timed out")
+				}
+				return false, nil, nil
+			})
+
+			// The fake clientset doesn't generate event names, so that the
+			// event of the requeued resource would collide
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			j := &Janitor{
+				client:        clientset,
+				dynamicClient: dynamicClient,
+				config: &Config{
+					IncludeResources:  []string{"all"},
+					IncludeNamespaces: []string{"all"},
+					Parallelism:       2,
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			counter := make(map[string]int)
+			j.processResourcesInParallel(context.Background(), []metav1.Object{pod}, counter, make(map[string]bool))
+
+			if deletes != tt.wantDeletes {
+				t.Errorf("Expected %d deletes, got %d", tt.wantDeletes, deletes)
+			}
+			result := newCleanupResult(counter)
+			if result.Processed != 1 || result.Deleted["pods"] != tt.wantDeleted || result.Errors != tt.wantErrors {
+				t.Errorf("Expected 1 processed, %d deleted and %d errors, got %+v", tt.wantDeleted, tt.wantErrors, result)
+			}
+		})
+	}
+}
+
+func TestCleanupNamespaceDeletedMidRun(t *testing.T) {
+	defer func(delay time.Duration) { requeueDelay = delay }(requeueDelay)
+	requeueDelay = time.Millisecond
+
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	expired := map[string]string{TTLAnnotation: "1h"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsGVR: "PodList"},
+		newUnstructuredPod("web", "live", time.Now().Add(-2*time.Hour), expired),
+		newUnstructuredPod("web", "vanishing", time.Now().Add(-2*time.Hour), expired),
+	)
+	notFound := func(namespace string) error {
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, namespace)
+	}
+
+	// The gone namespace is deleted before its pods are listed, the vanishing
+	// namespace after they were listed
+	dynamicClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "gone" {
+			return true, nil, notFound("gone")
+		}
+		return false, nil, nil
+	})
+	dynamicClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "vanishing" {
+			return true, nil, apierrors.NewNotFound(podsGVR.GroupResource(), "web")
+		}
+		return false, nil, nil
+	})
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "live"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "gone"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "vanishing"}},
+	)
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetNamespace() == "vanishing" {
+			return true, nil, notFound("vanishing")
+		}
+		return false, nil, nil
+	})
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	counter := make(map[string]int)
+	podType := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+	if err := j.cleanupResourceType(context.Background(), podType, counter, make(map[string]bool)); err != nil {
+		t.Fatalf("cleanupResourceType() error = %v", err)
+	}
+
+	result := newCleanupResult(counter)
+	if result.Errors != 0 || result.Deleted["pods"] != 1 {
+		t.Errorf("Expected the pod in the live namespace to be deleted without errors, got %+v", result)
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("live").Get(context.Background(), "web", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the pod in the live namespace to be deleted, got %v", err)
+	}
+	if got := testutil.ToFloat64(listFailures.WithLabelValues("Pod", "gone")); got != 0 {
+		t.Errorf("Expected no list failures for the deleted namespace, got %v", got)
+	}
+}
+
+func TestCleanupStreamsResourcesToWorkers(t *testing.T) {
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	var objects []runtime.Object
+	for _, ns := range []string{"team-a", "team-b"} {
+		for i := 0; i < 3; i++ {
+			objects = append(objects, newUnstructuredPod(fmt.Sprintf("pod-%d", i), ns, time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"}))
+		}
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsGVR: "PodList"}, objects...)
+	deleted := make(chan struct{})
+	var deletedOnce sync.Once
+	dynamicClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deletedOnce.Do(func() { close(deleted) })
+		return false, nil, nil
+	})
+
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+	)
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	// Listing the second namespace waits for the first delete, which only
+	// happens in time if the pods of the first namespace are already processed
+	gate := &listGate{namespace: "team-b", release: deleted}
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: gatedListClient{Interface: dynamicClient, gate: gate},
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			Parallelism:       2,
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	counter := make(map[string]int)
+	podType := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+	if err := j.cleanupResourceType(context.Background(), podType, counter, make(map[string]bool)); err != nil {
+		t.Fatalf("cleanupResourceType() error = %v", err)
+	}
+
+	if !gate.released.Load() {
+		t.Error("Expected resources to be processed before all namespaces were listed")
+	}
+	result := newCleanupResult(counter)
+	if result.Processed != len(objects) || result.Deleted["pods"] != len(objects) || result.Errors != 0 {
+		t.Errorf("Expected all %d pods to be processed and deleted, got %+v", len(objects), result)
+	}
+}
+
+// listGate holds the list of a namespace until release is closed, or gives up
+// after a while
+type listGate struct {
+	namespace string
+	release   <-chan struct{}
+	released  atomic.Bool
+}
+
+// gatedListClient holds namespaced lists at a gate, as the fake dynamic client
+// serializes all its calls and reactors can't block
+type gatedListClient struct {
+	dynamic.Interface
+	gate *listGate
+}
+
+func (c gatedListClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return gatedListResource{NamespaceableResourceInterface: c.Interface.Resource(gvr), gate: c.gate}
+}
+
+type gatedListResource struct {
+	dynamic.NamespaceableResourceInterface
+	gate *listGate
+}
+
+func (r gatedListResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return gatedListNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(namespace), namespace: namespace, gate: r.gate}
+}
+
+type gatedListNamespacedResource struct {
+	dynamic.ResourceInterface
+	namespace string
+	gate      *listGate
+}
+
+func (r gatedListNamespacedResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if r.namespace == r.gate.namespace {
+		select {
+		case <-r.gate.release:
+			r.gate.released.Store(true)
+		case <-time.After(5 * time.Second):
+		}
+	}
+	return r.ResourceInterface.List(ctx, opts)
+}
+
+func TestCleanUpDeletesPodsBeforePVCs(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	expired := map[string]string{TTLAnnotation: "1h"}
+	pvc := &unstructured.Unstructured{}
+	pvc.SetAPIVersion("v1")
+	pvc.SetKind("PersistentVolumeClaim")
+	pvc.SetName("data")
+	pvc.SetNamespace("default")
+	pvc.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-2 * time.Hour)))
+	pvc.SetAnnotations(expired)
+	pod := newUnstructuredPod("web", "default", time.Now().Add(-2*time.Hour), expired)
+	if err := unstructured.SetNestedSlice(pod.Object, []interface{}{
+		map[string]interface{}{
+			"name":                  "data",
+			"persistentVolumeClaim": map[string]interface{}{"claimName": "data"},
+		},
+	}, "spec", "volumes"); err != nil {
+		t.Fatalf("Failed to set volumes: %v", err)
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Version: "v1", Resource: "pods"}:                   "PodList",
+			{Version: "v1", Resource: "persistentvolumeclaims"}: "PersistentVolumeClaimList",
+		},
+		pvc, pod,
+	)
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+
+	config := NewConfig()
+	config.NotifyBackends = nil
+	j, err := NewWithClients(config, clientset, dynamicClient)
+	if err != nil {
+		t.Fatalf("NewWithClients() error = %v", err)
+	}
+	if _, err := j.CleanUp(context.Background()); err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+
+	var deleted []string
+	for _, action := range dynamicClient.Actions() {
+		if del, ok := action.(k8stesting.DeleteAction); ok {
+			deleted = append(deleted, del.GetResource().Resource+"/"+del.GetName())
+		}
+	}
+	want := []string{"pods/web", "persistentvolumeclaims/data"}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Errorf("Deleted %v, want %v", deleted, want)
+	}
+}
+
+func TestSortByDeleteOrder(t *testing.T) {
+	resourceTypes := []ResourceType{
+		{Group: "apps", Version: "v1", Kind: "Deployment", Plural: "deployments", Namespaced: true},
+		{Version: "v1", Kind: "PersistentVolume", Plural: "persistentvolumes"},
+		{Version: "v1", Kind: "PersistentVolumeClaim", Plural: "persistentvolumeclaims", Namespaced: true},
+		{Version: "v1", Kind: "ConfigMap", Plural: "configmaps", Namespaced: true},
+		{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true},
+	}
+
+	tests := []struct {
+		name        string
+		deleteOrder []string
+		want        []string
+	}{
+		{
+			name:        "default order",
+			deleteOrder: strings.Split(defaultDeleteOrder, ","),
+			want:        []string{"pods", "persistentvolumeclaims", "persistentvolumes", "deployments", "configmaps"},
+		},
+		{
+			name:        "group-qualified order",
+			deleteOrder: []string{"deployments.apps", "pods"},
+			want:        []string{"deployments", "pods", "persistentvolumes", "persistentvolumeclaims", "configmaps"},
+		},
+		{
+			name: "no order",
+			want: []string{"deployments", "persistentvolumes", "persistentvolumeclaims", "configmaps", "pods"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{config: &Config{DeleteOrder: tt.deleteOrder}}
+			sorted := append([]ResourceType(nil), resourceTypes...)
+			j.sortByDeleteOrder(sorted)
+
+			var got []string
+			for _, rt := range sorted {
+				got = append(got, rt.Plural)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortByDeleteOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateEventAggregatesRepeats(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	j := &Janitor{
+		client: clientset,
+		config: &Config{},
+	}
+	pod := newUnstructuredPod("web", "default", time.Now(), nil)
+	pod.SetUID("uid-1")
+
+	// The same event in several runs is counted instead of repeated
+	for i := 0; i < 3; i++ {
+		if err := j.createEvent(context.Background(), pod, "Pod default/web will be deleted", "DeleteNotification"); err != nil {
+			t.Fatalf("createEvent() error = %v", err)
+		}
+	}
+	if err := j.createEvent(context.Background(), pod, "Pod default/web expired", "TTLExpired"); err != nil {
+		t.Fatalf("createEvent() error = %v", err)
+	}
+
+	events, err := clientset.CoreV1().Events("default").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Failed to list events: %v", err)
+	}
+	counts := make(map[string]int32)
+	for _, event := range events.Items {
+		counts[event.Reason] += event.Count
+	}
+	if len(events.Items) != 2 || counts["DeleteNotification"] != 3 || counts["TTLExpired"] != 1 {
+		t.Errorf("Expected a DeleteNotification event with count 3 and a TTLExpired event, got %d events with counts %v", len(events.Items), counts)
+	}
+}
+
+func TestCleanUpDryRunOnceThenApply(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"},
+		newUnstructuredPod("expired-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"}),
+	)
+
+	config := NewConfig()
+	config.NotifyBackends = nil
+	config.DryRunOnceThenApply = true
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	j, err := NewWithClients(config, clientset, dynamicClient)
+	if err != nil {
+		t.Fatalf("NewWithClients() error = %v", err)
+	}
+
+	deletes := func() int {
+		n := 0
+		for _, action := range dynamicClient.Actions() {
+			if action.GetVerb() == "delete" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The first run only plans the deletion
+	result, err := j.CleanUp(context.Background())
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if got := deletes(); got != 0 {
+		t.Errorf("Expected no deletions in the first run, got %d", got)
+	}
+	if result.Deleted["pods"] != 1 {
+		t.Errorf("Expected the first run to plan the deletion of the pod, got %+v", result)
+	}
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "create" && action.GetResource().Resource == "events" {
+			t.Errorf("Expected no events in the first run, got %v", action)
+		}
+	}
+
+	// The second run deletes
+	if _, err := j.CleanUp(context.Background()); err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if got := deletes(); got != 1 {
+		t.Errorf("Expected the pod to be deleted in the second run, got %d deletions", got)
+	}
+
+	config.DryRun = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected dry-run-once-then-apply to be rejected together with dry-run")
+	}
+}
@@ -0,0 +1,56 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// keepEventInterval is the minimum time between two keep events for the same
+// resource and skip reason
+const keepEventInterval = 24 * time.Hour
+
+// emitKeepEvent creates an event explaining why a resource is kept if keep
+// events are enabled. The event is deduplicated by resource and skip reason,
+// so that resources kept in every run don't flood the cluster with events.
+func (j *Janitor) emitKeepEvent(ctx context.Context, obj metav1.Object, skipReason, reason string) {
+	if !j.config.EventOnKeep {
+		return
+	}
+
+	gvk := objectGVK(obj)
+	key := fmt.Sprintf("%s/%s/%s/%s/%s", gvk.Group, gvk.Kind, obj.GetNamespace(), obj.GetName(), skipReason)
+	now := time.Now()
+
+	j.keepEventsMutex.Lock()
+	if last, ok := j.keepEvents[key]; ok && now.Sub(last) < keepEventInterval {
+		j.keepEventsMutex.Unlock()
+		return
+	}
+	if j.keepEvents == nil {
+		j.keepEvents = make(map[string]time.Time)
+	}
+	j.keepEvents[key] = now
+	j.keepEventsMutex.Unlock()
+
+	message := fmt.Sprintf("Kept by kube-janitor (%s): %s", skipReason, reason)
+	if err := j.createEvent(ctx, obj, message, "Retained"); err != nil {
+		log.Printf("Failed to create keep event for %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+	}
+}
+
+// pruneKeepEvents forgets the keep events older than keepEventInterval, which
+// no longer suppress new events
+func (j *Janitor) pruneKeepEvents(now time.Time) {
+	j.keepEventsMutex.Lock()
+	defer j.keepEventsMutex.Unlock()
+
+	for key, last := range j.keepEvents {
+		if now.Sub(last) >= keepEventInterval {
+			delete(j.keepEvents, key)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package janitor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestEventOnKeep(t *testing.T) {
+	tests := []struct {
+		name        string
+		eventOnKeep bool
+		wantEvents  int
+	}{
+		{name: "disabled by default"},
+		{name: "one event per resource and reason", eventOnKeep: true, wantEvents: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			var events []*corev1.Event
+			// The fake clientset does not generate event names, so record the
+			// events instead of creating them
+			clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				events = append(events, action.(k8stesting.CreateAction).GetObject().(*corev1.Event))
+				return true, nil, nil
+			})
+
+			j := &Janitor{
+				client: clientset,
+				config: &Config{
+					IncludeResources:  []string{"all"},
+					IncludeNamespaces: []string{"all"},
+					EventOnKeep:       tt.eventOnKeep,
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			noTTL := newUnstructuredPod("no-ttl", "default", time.Now(), nil)
+			notExpired := newUnstructuredPod("not-expired", "default", time.Now(), map[string]string{TTLAnnotation: "1h"})
+			// Resources kept in every run only get a single event
+			for i := 0; i < 3; i++ {
+				for _, pod := range []*unstructured.Unstructured{noTTL, notExpired} {
+					if err := j.handleResource(context.Background(), pod, make(map[string]int), make(map[string]bool)); err != nil {
+						t.Fatalf("handleResource() error = %v", err)
+					}
+				}
+			}
+
+			if len(events) != tt.wantEvents {
+				t.Fatalf("Expected %d events, got %d", tt.wantEvents, len(events))
+			}
+			for _, event := range events {
+				if event.Reason != "Retained" || event.Type != "Normal" {
+					t.Errorf("Unexpected event %s/%s", event.Type, event.Reason)
+				}
+			}
+			if tt.wantEvents > 0 {
+				if event := events[0]; event.InvolvedObject.Name != "no-ttl" || !strings.Contains(event.Message, SkipReasonNoTTL) {
+					t.Errorf("Expected a no-ttl event for the pod without TTL, got %q for %s", event.Message, event.InvolvedObject.Name)
+				}
+				if event := events[1]; event.InvolvedObject.Name != "not-expired" || !strings.Contains(event.Message, SkipReasonNotExpired) {
+					t.Errorf("Expected a not-expired event for the pod with TTL, got %q for %s", event.Message, event.InvolvedObject.Name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package janitor
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// metricsNamespace prefixes the names of all janitor metrics
+const metricsNamespace = "kube_janitor"
+
+// metricsRegistry holds the janitor metrics served on /metrics
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	timeToExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "time_to_expiry_seconds",
+		Help:      "Time until a resource expires and will be deleted.",
+	}, []string{"kind", "namespace", "name"})
+
+	persistentDeleteFailures = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "persistent_delete_failures",
+		Help:      "Number of resources that failed to be deleted at least the delete failure threshold times in a row.",
+	})
+
+	runsWithoutDeletions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "runs_without_deletions",
+		Help:      "Number of consecutive cleanup runs that deleted nothing.",
+	})
+
+	quiet = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "quiet",
+		Help:      "Whether the number of consecutive runs without deletions reached the quiet run threshold (1) or not (0).",
+	})
+
+	managedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_resources",
+		Help:      "Number of resources that matched the filters in the latest run, whether or not they were deleted.",
+	}, []string{"kind", "namespace"})
+
+	ruleMatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rule_matches_total",
+		Help:      "Number of times a rule matched a resource.",
+	}, []string{"rule"})
+
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "build_info",
+		Help:      "Version, commit and build date of the running janitor, always 1.",
+	}, []string{"version", "commit", "build_date"})
+
+	forbiddenResourceTypes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "forbidden_resource_types",
+		Help:      "Resource types skipped in the latest run because the janitor is not permitted to list or delete them, always 1.",
+	}, []string{"resource", "verb"})
+
+	listFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "list_failures_total",
+		Help:      "Number of resource lists that failed after all retries, skipping the resources of a kind in a namespace for a run.",
+	}, []string{"kind", "namespace"})
+
+	discoveryDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "discovery_duration_seconds",
+		Help:      "Time the latest discovery of the resource types took.",
+	})
+
+	discoveredResourceTypes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "discovered_resource_types",
+		Help:      "Number of resource types found by the latest discovery.",
+	})
+)
+
+// metricsCollectors are all janitor metrics, registered on metricsRegistry
+var metricsCollectors = []prometheus.Collector{
+	timeToExpiry,
+	persistentDeleteFailures,
+	runsWithoutDeletions,
+	quiet,
+	managedResources,
+	ruleMatches,
+	listFailures,
+	discoveryDuration,
+	discoveredResourceTypes,
+	forbiddenResourceTypes,
+	buildInfo,
+}
+
+func init() {
+	metricsRegistry.MustRegister(metricsCollectors...)
+}
+
+// SetMetricsCluster adds a cluster label with the given context name to all
+// janitor metrics, so that the metrics of several clusters can be told apart
+// in a shared dashboard. Must be called before the metrics are served.
+func SetMetricsCluster(cluster string) {
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.Registerer(registry)
+	if cluster != "" {
+		registerer = prometheus.WrapRegistererWith(prometheus.Labels{"cluster": cluster}, registry)
+	}
+	registerer.MustRegister(metricsCollectors...)
+	metricsRegistry = registry
+}
+
+// metricsHandler serves the janitor metrics in the Prometheus text format,
+// or in the OpenMetrics format if the scraper asks for it
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// SetBuildInfo records the version, commit and build date of the running
+// janitor in the build info metric
+func SetBuildInfo(version, commit, buildDate string) {
+	buildInfo.Reset()
+	buildInfo.WithLabelValues(version, commit, buildDate).Set(1)
+}
+
+// observeTimeToExpiry records the time until a resource expires
+func observeTimeToExpiry(obj metav1.Object, expiryTime time.Time) {
+	timeToExpiry.WithLabelValues(objectGVK(obj).Kind, obj.GetNamespace(), obj.GetName()).
+		Set(time.Until(expiryTime).Seconds())
+}
+
+// resetTimeToExpiry removes the time to expiry of all resources in a
+// namespace, so that deleted resources do not linger. The empty namespace
+// stands for cluster-scoped resources.
+func resetTimeToExpiry(namespace string) {
+	timeToExpiry.DeletePartialMatch(prometheus.Labels{"namespace": namespace})
+}
+
+// observeManagedResources records the number of resources of a kind in a
+// namespace that matched the filters. Namespaces without matching resources
+// are removed, so that the gauge does not grow with every namespace.
+func observeManagedResources(kind, namespace string, count int) {
+	if count == 0 {
+		managedResources.DeleteLabelValues(kind, namespace)
+		return
+	}
+	managedResources.WithLabelValues(kind, namespace).Set(float64(count))
+}
+
+// resetManagedResources removes the number of managed resources of a kind in
+// all namespaces
+func resetManagedResources(kind string) {
+	managedResources.DeletePartialMatch(prometheus.Labels{"kind": kind})
+}
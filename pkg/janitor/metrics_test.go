@@ -0,0 +1,178 @@
+package janitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTimeToExpiryGauge(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	pod := newUnstructuredPod("metrics-valid-pod", "metrics", time.Now().Add(-30*time.Minute), map[string]string{TTLAnnotation: "1h"})
+	if err := j.handleResource(context.Background(), pod, make(map[string]int), make(map[string]bool)); err != nil {
+		t.Fatalf("handleResource() error = %v", err)
+	}
+
+	got := testutil.ToFloat64(timeToExpiry.WithLabelValues("Pod", "metrics", "metrics-valid-pod"))
+	if got < 29*60 || got > 30*60 {
+		t.Errorf("time to expiry = %v, want about 1800 seconds", got)
+	}
+
+	server := httptest.NewServer(NewServeMux(&Config{}, nil))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `kube_janitor_time_to_expiry_seconds{kind="Pod",name="metrics-valid-pod",namespace="metrics"}`) {
+		t.Errorf("Expected the gauge on /metrics, got:\n%s", body)
+	}
+
+	// The gauge is cleared when the namespace is processed again
+	resetTimeToExpiry("metrics")
+	if timeToExpiry.DeleteLabelValues("Pod", "metrics", "metrics-valid-pod") {
+		t.Error("Expected the time to expiry to be removed after reset")
+	}
+}
+
+func TestManagedResourcesGauge(t *testing.T) {
+	excluded := newUnstructuredPod("excluded", "managed", time.Now(), nil)
+	excluded.SetLabels(map[string]string{"janitor": "skip"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"},
+		newUnstructuredPod("web", "managed", time.Now(), nil),
+		newUnstructuredPod("worker", "managed", time.Now(), nil),
+		excluded,
+	)
+
+	j := &Janitor{
+		client: fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "managed"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "empty"}},
+		),
+		dynamicClient: dynamicClient,
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			ExcludeLabels:     []string{"janitor=skip"},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	podType := ResourceType{Version: "v1", Kind: "Pod", Plural: "pods", Namespaced: true}
+	if err := j.cleanupResourceType(context.Background(), podType, make(map[string]int), make(map[string]bool)); err != nil {
+		t.Fatalf("cleanupResourceType() error = %v", err)
+	}
+
+	if got := testutil.ToFloat64(managedResources.WithLabelValues("Pod", "managed")); got != 2 {
+		t.Errorf("managed resources = %v, want 2", got)
+	}
+	// Namespaces without managed resources are not exported
+	if managedResources.DeleteLabelValues("Pod", "empty") {
+		t.Error("Expected no managed resources for the empty namespace")
+	}
+}
+
+func TestDiscoveryMetrics(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "componentstatuses", Kind: "ComponentStatus", Verbs: []string{"list"}},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	j := &Janitor{client: clientset, config: &Config{}}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	resourceTypes, err := j.getResourceTypes()
+	if err != nil {
+		t.Fatalf("getResourceTypes() error = %v", err)
+	}
+
+	if got := testutil.ToFloat64(discoveredResourceTypes); got != float64(len(resourceTypes)) || got == 0 {
+		t.Errorf("discovered resource types = %v, want %d", got, len(resourceTypes))
+	}
+	if got := testutil.ToFloat64(discoveryDuration); got < 0 {
+		t.Errorf("discovery duration = %v, want a non-negative duration", got)
+	}
+	if !strings.Contains(buf.String(), fmt.Sprintf("Discovered %d resource types in ", len(resourceTypes))) {
+		t.Errorf("Expected the discovery to be logged, got %q", buf.String())
+	}
+}
+
+func TestBuildInfoMetric(t *testing.T) {
+	// The values main sets from its -ldflags variables
+	SetBuildInfo("v1.2.3", "0123abc", "2024-05-01T12:00:00Z")
+	SetBuildInfo("v1.2.4", "4567def", "2024-06-01T12:00:00Z")
+
+	server := httptest.NewServer(NewServeMux(&Config{}, nil))
+	defer server.Close()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("Content-Type = %q, want OpenMetrics", contentType)
+	}
+	if !strings.HasSuffix(string(body), "# EOF\n") {
+		t.Errorf("Expected the OpenMetrics EOF marker, got:\n%s", body)
+	}
+	want := `kube_janitor_build_info{build_date="2024-06-01T12:00:00Z",commit="4567def",version="v1.2.4"} 1`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("Expected %s on /metrics, got:\n%s", want, body)
+	}
+	// Only the latest build info is exported
+	if strings.Contains(string(body), `version="v1.2.3"`) {
+		t.Errorf("Expected the previous build info to be removed, got:\n%s", body)
+	}
+}
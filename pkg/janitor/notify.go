@@ -0,0 +1,85 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Supported notification backends
+const (
+	NotifyBackendWebhook   = "webhook"
+	NotifyBackendSNS       = "sns"
+	NotifyBackendSMTP      = "smtp"
+	NotifyBackendPagerDuty = "pagerduty"
+)
+
+// notificationSubject is used as subject by backends that support one
+const notificationSubject = "kube-janitor delete notification"
+
+// Notifier sends delete notifications to a notification backend
+type Notifier interface {
+	Send(message WebhookMessage) error
+}
+
+// MultiNotifier sends notifications to multiple backends
+type MultiNotifier []Notifier
+
+// Send sends the message to all backends and returns the combined errors
+func (m MultiNotifier) Send(message WebhookMessage) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Send(message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewNotifier creates a notifier for the configured notification backends
+func NewNotifier(config *Config) (Notifier, error) {
+	var notifiers MultiNotifier
+	for _, backend := range config.NotifyBackends {
+		switch backend {
+		case NotifyBackendWebhook:
+			notifiers = append(notifiers, &DefaultWebhookClient{
+				URL:             config.webhookURL(),
+				Secret:          config.WebhookSecret,
+				SignatureHeader: config.WebhookSignatureHeader,
+			})
+			for _, target := range config.WebhookTargets {
+				notifiers = append(notifiers, &DefaultWebhookClient{
+					URL:             target.URL,
+					Format:          target.Format,
+					Headers:         target.Headers,
+					Secret:          config.WebhookSecret,
+					SignatureHeader: config.WebhookSignatureHeader,
+				})
+			}
+		case NotifyBackendSNS:
+			notifier, err := NewSNSNotifier(context.Background(), config.SNSTopicARN)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create SNS notifier: %v", err)
+			}
+			notifiers = append(notifiers, notifier)
+		case NotifyBackendSMTP:
+			notifiers = append(notifiers, &SMTPNotifier{
+				Host:     config.SMTPHost,
+				From:     config.SMTPFrom,
+				To:       config.SMTPTo,
+				Username: config.SMTPUsername,
+				Password: config.SMTPPassword,
+				TLS:      config.SMTPTLS,
+			})
+		case NotifyBackendPagerDuty:
+			notifiers = append(notifiers, &PagerDutyNotifier{RoutingKey: config.PagerDutyRoutingKey})
+		default:
+			return nil, fmt.Errorf("unknown notification backend %q", backend)
+		}
+	}
+
+	if len(notifiers) == 1 {
+		return notifiers[0], nil
+	}
+	return notifiers, nil
+}
@@ -0,0 +1,209 @@
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type recordingNotifier struct {
+	messages []WebhookMessage
+	err      error
+}
+
+func (r *recordingNotifier) Send(message WebhookMessage) error {
+	r.messages = append(r.messages, message)
+	return r.err
+}
+
+func TestMultiNotifierSend(t *testing.T) {
+	first := &recordingNotifier{err: errors.New("backend unavailable")}
+	second := &recordingNotifier{}
+
+	err := MultiNotifier{first, second}.Send(WebhookMessage{Message: "test message"})
+	if err == nil {
+		t.Error("Expected error from failing backend, got nil")
+	}
+
+	// A failing backend must not prevent the other backends from being notified
+	for i, notifier := range []*recordingNotifier{first, second} {
+		if len(notifier.messages) != 1 || notifier.messages[0].Message != "test message" {
+			t.Errorf("Expected backend %d to receive the message, got %v", i, notifier.messages)
+		}
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []string
+		wantErr  bool
+	}{
+		{
+			name:     "webhook backend",
+			backends: []string{NotifyBackendWebhook},
+			wantErr:  false,
+		},
+		{
+			name:     "webhook and pagerduty backends",
+			backends: []string{NotifyBackendWebhook, NotifyBackendPagerDuty},
+			wantErr:  false,
+		},
+		{
+			name:     "unknown backend",
+			backends: []string{"carrier-pigeon"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewNotifier(&Config{NotifyBackends: tt.backends})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSendDeleteNotificationToMultipleTargets(t *testing.T) {
+	var received [2]int32
+	var servers []*httptest.Server
+	for i := range received {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&received[i], 1)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+		servers = append(servers, server)
+	}
+
+	// A failing target must not prevent the others from being notified
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	config := &Config{
+		NotifyBackends: []string{NotifyBackendWebhook},
+		WebhookTargets: []WebhookTarget{
+			{URL: failing.URL},
+			{URL: servers[0].URL},
+			{URL: servers[1].URL, Format: WebhookFormatSlack},
+		},
+	}
+	notifier, err := NewNotifier(config)
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	pod := newUnstructuredPod("test-pod", "default", time.Now(), nil)
+	j := &Janitor{
+		client:        fake.NewSimpleClientset(),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod.DeepCopy()),
+		config:        config,
+		cache:         make(map[string]interface{}),
+		notifier:      notifier,
+	}
+
+	if err := j.sendDeleteNotification(context.Background(), pod, "test", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("sendDeleteNotification() error = %v", err)
+	}
+
+	for i := range received {
+		if got := atomic.LoadInt32(&received[i]); got != 1 {
+			t.Errorf("Expected target %d to receive 1 notification, got %d", i, got)
+		}
+	}
+}
+
+func TestContextNameFields(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var events []*corev1.Event
+	clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		events = append(events, action.(k8stesting.CreateAction).GetObject().(*corev1.Event))
+		return true, nil, nil
+	})
+
+	expiring := newUnstructuredPod("expiring", "default", time.Now().Add(-30*time.Minute), map[string]string{TTLAnnotation: "1h"})
+	expired := newUnstructuredPod("expired", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	auditLog, err := openAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("openAuditLog() error = %v", err)
+	}
+	notifier := &recordingNotifier{}
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), expiring.DeepCopy(), expired.DeepCopy()),
+		config: &Config{
+			IncludeResources:   []string{"all"},
+			IncludeNamespaces:  []string{"all"},
+			DeleteNotification: 3600,
+			ContextName:        "prod-eu",
+		},
+		cache:    make(map[string]interface{}),
+		notifier: notifier,
+		auditLog: auditLog,
+	}
+	defer j.Close()
+
+	for _, pod := range []*unstructured.Unstructured{expiring, expired} {
+		if err := j.handleResource(context.Background(), pod, make(map[string]int), make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource(%s) error = %v", pod.GetName(), err)
+		}
+	}
+
+	if len(notifier.messages) != 1 || notifier.messages[0].Cluster != "prod-eu" {
+		t.Errorf("Expected a notification for cluster prod-eu, got %+v", notifier.messages)
+	}
+	if len(events) == 0 {
+		t.Fatal("Expected events to be created")
+	}
+	for _, event := range events {
+		if got := event.Annotations[ClusterAnnotation]; got != "prod-eu" {
+			t.Errorf("Expected event %q to carry the cluster annotation, got %q", event.Reason, got)
+		}
+	}
+
+	data, err := os.ReadFile(auditLog.Name())
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("Invalid audit log %q: %v", data, err)
+	}
+	if entry.Name != "expired" || entry.Cluster != "prod-eu" {
+		t.Errorf("Expected an audit log entry for cluster prod-eu, got %+v", entry)
+	}
+
+	SetMetricsCluster("prod-eu")
+	defer SetMetricsCluster("")
+	server := httptest.NewServer(NewServeMux(&Config{}, nil))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if want := `kube_janitor_time_to_expiry_seconds{cluster="prod-eu",kind="Pod",name="expiring",namespace="default"}`; !strings.Contains(string(body), want) {
+		t.Errorf("Expected %s on /metrics, got:\n%s", want, body)
+	}
+}
@@ -0,0 +1,229 @@
+package janitor
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// scheduledNotification is a delete notification that is due between two
+// runs, sent by the notification scheduler at its lead time
+type scheduledNotification struct {
+	key        string
+	obj        metav1.Object
+	reason     string
+	expiryTime time.Time
+	notifyAt   time.Time
+
+	// The TTL and expiry annotations when the notification was scheduled,
+	// the notification is dropped if they change before it is sent
+	ttl     string
+	expires string
+
+	index int
+}
+
+// notificationQueue is a priority queue of scheduled notifications, ordered
+// by the time they are due
+type notificationQueue []*scheduledNotification
+
+func (q notificationQueue) Len() int           { return len(q) }
+func (q notificationQueue) Less(i, k int) bool { return q[i].notifyAt.Before(q[k].notifyAt) }
+
+func (q notificationQueue) Swap(i, k int) {
+	q[i], q[k] = q[k], q[i]
+	q[i].index = i
+	q[k].index = k
+}
+
+func (q *notificationQueue) Push(x interface{}) {
+	n := x.(*scheduledNotification)
+	n.index = len(*q)
+	*q = append(*q, n)
+}
+
+func (q *notificationQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return n
+}
+
+// notificationKey identifies a resource in the notification queue
+func notificationKey(obj metav1.Object) string {
+	gvk := objectGVK(obj)
+	return fmt.Sprintf("%s/%s/%s/%s", gvk.Group, gvk.Kind, obj.GetNamespace(), obj.GetName())
+}
+
+// RunNotificationScheduler sends the delete notifications that become due
+// between two runs at their lead time, until the context is cancelled. Runs
+// only scan for expiring resources every interval, so without the scheduler
+// a notification may be sent up to an interval late, or not at all if the
+// --delete-notification lead time is shorter than the interval.
+func (j *Janitor) RunNotificationScheduler(ctx context.Context) {
+	j.notificationsMutex.Lock()
+	j.notificationsWake = make(chan struct{}, 1)
+	wake := j.notificationsWake
+	j.notificationsMutex.Unlock()
+
+	defer func() {
+		j.notificationsMutex.Lock()
+		defer j.notificationsMutex.Unlock()
+		j.notificationsWake = nil
+		j.notifications = nil
+		j.notificationIndex = nil
+	}()
+
+	for {
+		// Without queued notifications only a wake up or cancellation ends the wait
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if wait, ok := j.nextNotificationIn(time.Now()); ok {
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-wake:
+		case <-timerC:
+			j.sendDueNotifications(ctx, time.Now())
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// scheduleNotification queues the delete notification of a resource that is
+// due before the next run of its namespace, replacing any notification queued
+// for the resource before. Nothing is queued unless the notification
+// scheduler is running.
+func (j *Janitor) scheduleNotification(obj metav1.Object, reason string, expiryTime, notifyAt time.Time) {
+	if notifyAt.After(time.Now().Add(j.namespaceIntervalOf(obj.GetNamespace()))) {
+		return
+	}
+
+	j.notificationsMutex.Lock()
+	defer j.notificationsMutex.Unlock()
+
+	if j.notificationsWake == nil {
+		return
+	}
+
+	key := notificationKey(obj)
+	if n, ok := j.notificationIndex[key]; ok {
+		heap.Remove(&j.notifications, n.index)
+	}
+	if j.notificationIndex == nil {
+		j.notificationIndex = make(map[string]*scheduledNotification)
+	}
+
+	annotations := obj.GetAnnotations()
+	n := &scheduledNotification{
+		key:        key,
+		obj:        obj,
+		reason:     reason,
+		expiryTime: expiryTime,
+		notifyAt:   notifyAt,
+		ttl:        annotations[TTLAnnotation],
+		expires:    annotations[ExpiryAnnotation],
+	}
+	heap.Push(&j.notifications, n)
+	j.notificationIndex[key] = n
+	j.debugLog("Scheduled delete notification for %s/%s at %s", obj.GetNamespace(), obj.GetName(), notifyAt)
+
+	// Wake up the scheduler in case the notification is due earliest
+	select {
+	case j.notificationsWake <- struct{}{}:
+	default:
+	}
+}
+
+// unscheduleNotification removes the queued notification of a resource, e.g.
+// because a run already sent it
+func (j *Janitor) unscheduleNotification(obj metav1.Object) {
+	j.notificationsMutex.Lock()
+	defer j.notificationsMutex.Unlock()
+
+	key := notificationKey(obj)
+	if n, ok := j.notificationIndex[key]; ok {
+		heap.Remove(&j.notifications, n.index)
+		delete(j.notificationIndex, key)
+	}
+}
+
+// nextNotificationIn returns how long to wait for the earliest queued
+// notification, and false if none is queued
+func (j *Janitor) nextNotificationIn(now time.Time) (time.Duration, bool) {
+	j.notificationsMutex.Lock()
+	defer j.notificationsMutex.Unlock()
+
+	if len(j.notifications) == 0 {
+		return 0, false
+	}
+	if wait := j.notifications[0].notifyAt.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// sendDueNotifications sends all queued notifications that are due at now
+func (j *Janitor) sendDueNotifications(ctx context.Context, now time.Time) {
+	for {
+		j.notificationsMutex.Lock()
+		if len(j.notifications) == 0 || j.notifications[0].notifyAt.After(now) {
+			j.notificationsMutex.Unlock()
+			return
+		}
+		n := heap.Pop(&j.notifications).(*scheduledNotification)
+		delete(j.notificationIndex, n.key)
+		j.notificationsMutex.Unlock()
+
+		if err := j.sendScheduledNotification(ctx, n); err != nil {
+			log.Printf("Failed to send scheduled delete notification for %s/%s: %v",
+				n.obj.GetNamespace(), n.obj.GetName(), err)
+		}
+	}
+}
+
+// sendScheduledNotification sends a queued notification if the resource still
+// exists, was not notified yet and still expires the same way. Other changes
+// are picked up by the next run.
+func (j *Janitor) sendScheduledNotification(ctx context.Context, n *scheduledNotification) error {
+	gvr := j.gvrFor(n.obj)
+	current, err := j.dynamicClient.Resource(gvr).Namespace(n.obj.GetNamespace()).Get(ctx, n.obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		j.debugLog("Resource %s/%s no longer exists, dropping its delete notification", n.obj.GetNamespace(), n.obj.GetName())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get resource: %v", err)
+	}
+
+	annotations := current.GetAnnotations()
+	if uid := n.obj.GetUID(); uid != "" && current.GetUID() != uid {
+		j.debugLog("Resource %s/%s was recreated, dropping its delete notification", n.obj.GetNamespace(), n.obj.GetName())
+		return nil
+	}
+	if annotations[TTLAnnotation] != n.ttl || annotations[ExpiryAnnotation] != n.expires {
+		j.debugLog("Expiry of resource %s/%s changed, dropping its delete notification", n.obj.GetNamespace(), n.obj.GetName())
+		return nil
+	}
+	if j.wasNotified(current) {
+		return nil
+	}
+
+	j.infoLog("Sending scheduled delete notification for resource %s/%s (%s)", n.obj.GetNamespace(), n.obj.GetName(), n.reason)
+	return j.sendDeleteNotification(ctx, current, n.reason, n.expiryTime)
+}
@@ -0,0 +1,186 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// channelNotifier passes notifications on to a channel, so that tests can wait
+// for notifications sent by the scheduler
+type channelNotifier chan WebhookMessage
+
+func (c channelNotifier) Send(message WebhookMessage) error {
+	c <- message
+	return nil
+}
+
+func TestNotificationScheduler(t *testing.T) {
+	const lead = 60 * time.Second
+
+	tests := []struct {
+		name      string
+		change    func(t *testing.T, dynamicClient *dynamicfake.FakeDynamicClient)
+		wantFired bool
+	}{
+		{
+			name:      "notification fires at its lead time",
+			wantFired: true,
+		},
+		{
+			name: "notification is dropped if the TTL changed",
+			change: func(t *testing.T, dynamicClient *dynamicfake.FakeDynamicClient) {
+				pods := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("default")
+				pod, err := pods.Get(context.Background(), "pod", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Failed to get pod: %v", err)
+				}
+				pod.SetAnnotations(map[string]string{TTLAnnotation: "2h"})
+				if _, err := pods.Update(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("Failed to update pod: %v", err)
+				}
+			},
+		},
+		{
+			name: "notification is dropped if the resource is gone",
+			change: func(t *testing.T, dynamicClient *dynamicfake.FakeDynamicClient) {
+				pods := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("default")
+				if err := pods.Delete(context.Background(), "pod", metav1.DeleteOptions{}); err != nil {
+					t.Fatalf("Failed to delete pod: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The pod expires just after the lead time, so its notification is
+			// due in a second or two, long before the next run. Creation
+			// timestamps only have a precision of seconds.
+			created := time.Now().Add(lead - time.Hour).Truncate(time.Second).Add(2 * time.Second)
+			pod := newUnstructuredPod("pod", "default", created, map[string]string{TTLAnnotation: "1h"})
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod.DeepCopy())
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, nil
+			})
+
+			notifications := make(channelNotifier, 1)
+			j := &Janitor{
+				client:        clientset,
+				dynamicClient: dynamicClient,
+				config: &Config{
+					Interval:           3600,
+					DeleteNotification: int(lead.Seconds()),
+					IncludeResources:   []string{"all"},
+					IncludeNamespaces:  []string{"all"},
+				},
+				cache:    make(map[string]interface{}),
+				notifier: notifications,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan struct{})
+			go func() {
+				j.RunNotificationScheduler(ctx)
+				close(done)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			// Wait for the scheduler to accept notifications
+			for deadline := time.Now().Add(time.Second); ; {
+				j.notificationsMutex.Lock()
+				running := j.notificationsWake != nil
+				j.notificationsMutex.Unlock()
+				if running {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("Notification scheduler did not start")
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			// The run finds the notification not yet due and schedules it
+			if err := j.handleResource(ctx, pod, make(map[string]int), make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+			select {
+			case message := <-notifications:
+				t.Fatalf("Expected no notification during the run, got %q", message.Message)
+			default:
+			}
+			if tt.change != nil {
+				tt.change(t, dynamicClient)
+			}
+
+			select {
+			case message := <-notifications:
+				if !tt.wantFired {
+					t.Fatalf("Expected the notification to be dropped, got %q", message.Message)
+				}
+				if message.Name != "pod" || message.Namespace != "default" {
+					t.Errorf("Expected a notification for default/pod, got %+v", message)
+				}
+				if wait := time.Until(created.Add(time.Hour)); wait > lead {
+					t.Errorf("Expected the notification within the lead time, %v before expiry", wait)
+				}
+			case <-time.After(3 * time.Second):
+				if tt.wantFired {
+					t.Fatal("Expected the scheduled notification to fire between runs")
+				}
+			}
+
+			if tt.wantFired {
+				pods := dynamicClient.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("default")
+				current, err := pods.Get(context.Background(), "pod", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("Failed to get pod: %v", err)
+				}
+				if !j.wasNotified(current) {
+					t.Errorf("Expected the %s annotation to be persisted", NotifiedAnnotation)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleNotificationBeyondNextRun(t *testing.T) {
+	j := &Janitor{
+		config:            &Config{Interval: 60},
+		notificationsWake: make(chan struct{}, 1),
+	}
+	pod := newUnstructuredPod("pod", "default", time.Now(), nil)
+
+	// Notifications due after the next run are left to that run
+	j.scheduleNotification(pod, "test", time.Now().Add(2*time.Hour), time.Now().Add(time.Hour))
+	if _, ok := j.nextNotificationIn(time.Now()); ok {
+		t.Error("Expected no notification to be scheduled beyond the next run")
+	}
+
+	// Scheduling a resource again replaces its notification
+	j.scheduleNotification(pod, "test", time.Now().Add(time.Hour), time.Now().Add(30*time.Second))
+	j.scheduleNotification(pod, "test", time.Now().Add(time.Hour), time.Now().Add(10*time.Second))
+	if len(j.notifications) != 1 {
+		t.Fatalf("Expected 1 scheduled notification, got %d", len(j.notifications))
+	}
+	if wait, _ := j.nextNotificationIn(time.Now()); wait > 10*time.Second {
+		t.Errorf("Expected the latest notification time, next notification in %v", wait)
+	}
+
+	j.unscheduleNotification(pod)
+	if _, ok := j.nextNotificationIn(time.Now()); ok {
+		t.Error("Expected the notification to be unscheduled")
+	}
+}
@@ -0,0 +1,89 @@
+package janitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyEvent is an Events API v2 event
+type PagerDutyEvent struct {
+	RoutingKey  string                `json:"routing_key"`
+	EventAction string                `json:"event_action"`
+	DedupKey    string                `json:"dedup_key"`
+	Payload     PagerDutyEventPayload `json:"payload"`
+}
+
+// PagerDutyEventPayload holds the details of a PagerDuty event
+type PagerDutyEventPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	Group         string            `json:"group,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// PagerDutyNotifier triggers PagerDuty incidents for delete notifications
+type PagerDutyNotifier struct {
+	RoutingKey string
+	URL        string // defaults to PagerDutyEventsURL
+}
+
+// Send triggers a PagerDuty event for the message. Repeated notifications for
+// the same resource share a dedup key and are grouped into one incident.
+func (n *PagerDutyNotifier) Send(message WebhookMessage) error {
+	source := message.Cluster
+	if source == "" {
+		source = "kube-janitor"
+	}
+
+	event := PagerDutyEvent{
+		RoutingKey:  n.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    pagerDutyDedupKey(message),
+		Payload: PagerDutyEventPayload{
+			Summary:   message.Message,
+			Source:    source,
+			Severity:  "warning",
+			Component: message.Kind,
+			Group:     message.Namespace,
+			CustomDetails: map[string]string{
+				"kind":      message.Kind,
+				"namespace": message.Namespace,
+				"name":      message.Name,
+			},
+		},
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal PagerDuty event: %v", err)
+	}
+
+	url := n.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("failed to send PagerDuty event: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("PagerDuty returned non-success status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// pagerDutyDedupKey derives the dedup key from the resource kind, namespace and name
+func pagerDutyDedupKey(message WebhookMessage) string {
+	return fmt.Sprintf("kube-janitor/%s/%s/%s", message.Kind, message.Namespace, message.Name)
+}
@@ -0,0 +1,61 @@
+package janitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPagerDutyNotifierSend(t *testing.T) {
+	var event PagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	notifier := &PagerDutyNotifier{RoutingKey: "test-routing-key", URL: server.URL}
+	err := notifier.Send(WebhookMessage{
+		Message:   "Pod default/test-pod will be deleted",
+		Kind:      "Pod",
+		Namespace: "default",
+		Name:      "test-pod",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if event.RoutingKey != "test-routing-key" {
+		t.Errorf("Expected routing key test-routing-key, got %s", event.RoutingKey)
+	}
+	if event.EventAction != "trigger" {
+		t.Errorf("Expected event action trigger, got %s", event.EventAction)
+	}
+	if event.DedupKey != "kube-janitor/Pod/default/test-pod" {
+		t.Errorf("Expected dedup key kube-janitor/Pod/default/test-pod, got %s", event.DedupKey)
+	}
+	if event.Payload.Summary != "Pod default/test-pod will be deleted" {
+		t.Errorf("Expected summary %q, got %q", "Pod default/test-pod will be deleted", event.Payload.Summary)
+	}
+	if event.Payload.Source == "" || event.Payload.Severity == "" {
+		t.Errorf("Expected source and severity to be set, got %+v", event.Payload)
+	}
+}
+
+func TestPagerDutyNotifierSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	notifier := &PagerDutyNotifier{RoutingKey: "test-routing-key", URL: server.URL}
+	if err := notifier.Send(WebhookMessage{Message: "test message"}); err == nil {
+		t.Error("Expected error for non-success status, got nil")
+	}
+}
@@ -0,0 +1,29 @@
+package janitor
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// updatePaused checks whether the pause ConfigMap exists and pauses deletions
+// for the current run if it does. If the ConfigMap can't be checked, the run
+// is not paused.
+func (j *Janitor) updatePaused(ctx context.Context) {
+	paused := false
+	if j.config.PauseConfigMap != "" {
+		namespace, name, _ := strings.Cut(j.config.PauseConfigMap, "/")
+		_, err := j.client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			paused = true
+			log.Printf("Clean up is paused by ConfigMap %s, resources will not be deleted", j.config.PauseConfigMap)
+		case !apierrors.IsNotFound(err):
+			log.Printf("Warning: failed to check pause ConfigMap %s: %v", j.config.PauseConfigMap, err)
+		}
+	}
+	j.paused.Store(paused)
+}
@@ -0,0 +1,151 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicyConfigMapKey is the key of the policy in the policy ConfigMap
+const PolicyConfigMapKey = "policy.yaml"
+
+// Policy defines the default TTL and rules of namespaces in one place, so
+// that platform teams don't need to annotate each namespace
+type Policy struct {
+	Namespaces []NamespacePolicy `yaml:"namespaces"`
+}
+
+// NamespacePolicy defines the default TTL and rules of the resources in the
+// matching namespaces
+type NamespacePolicy struct {
+	// Name of the namespace, and comma-separated key=value labels the
+	// namespace must all have, in the format of --exclude-label. At least one
+	// of them is required.
+	Namespace string `yaml:"namespace"`
+	Labels    string `yaml:"labels"`
+
+	// TTL of resources without TTL annotation or matching rule, like the
+	// janitor/ttl annotation of the namespace
+	TTL string `yaml:"ttl"`
+
+	// Rules evaluated after the rules of the rules file
+	Rules []Rule `yaml:"rules"`
+}
+
+// ParsePolicy parses and validates a namespace policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %v", err)
+	}
+
+	for i := range policy.Namespaces {
+		if err := policy.Namespaces[i].validate(); err != nil {
+			return nil, fmt.Errorf("invalid namespace policy #%d: %v", i, err)
+		}
+	}
+
+	return &policy, nil
+}
+
+// validate validates the namespace policy and compiles its rules
+func (p *NamespacePolicy) validate() error {
+	if p.Namespace == "" && p.Labels == "" {
+		return fmt.Errorf("namespace or labels are required")
+	}
+	if p.Labels != "" {
+		for _, pair := range strings.Split(p.Labels, ",") {
+			if key, _, _ := strings.Cut(pair, "="); key == "" {
+				return fmt.Errorf("invalid labels %q: keys must not be empty", p.Labels)
+			}
+		}
+	}
+	if p.TTL == "" && len(p.Rules) == 0 {
+		return fmt.Errorf("ttl or rules are required")
+	}
+	if p.TTL != "" {
+		if _, err := ParseTTL(p.TTL); err != nil {
+			return fmt.Errorf("invalid TTL %q: %v", p.TTL, err)
+		}
+	}
+	for i := range p.Rules {
+		if err := p.Rules[i].ValidateAndCompile(); err != nil {
+			return fmt.Errorf("invalid rule #%d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// forNamespace returns the first namespace policy matching the namespace, or
+// nil if none matches
+func (p *Policy) forNamespace(name string, labels map[string]string) *NamespacePolicy {
+	for i := range p.Namespaces {
+		np := &p.Namespaces[i]
+		if np.Namespace != "" && np.Namespace != name {
+			continue
+		}
+		if np.Labels != "" && !matchesExcludeSelector(np.Labels, labels) {
+			continue
+		}
+		return np
+	}
+	return nil
+}
+
+// updatePolicy loads the namespace policy from the policy ConfigMap for the
+// current run. If the ConfigMap can't be read or the policy is invalid, the
+// policy of the previous run stays in effect.
+func (j *Janitor) updatePolicy(ctx context.Context) {
+	if j.config.PolicyConfigMap == "" {
+		return
+	}
+
+	namespace, name, _ := strings.Cut(j.config.PolicyConfigMap, "/")
+	cm, err := j.client.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		log.Printf("Warning: policy ConfigMap %s not found, no namespace policy applies", j.config.PolicyConfigMap)
+		j.policy.Store(nil)
+		return
+	}
+	if err != nil {
+		log.Printf("Warning: failed to get policy ConfigMap %s, keeping the previous policy: %v", j.config.PolicyConfigMap, err)
+		return
+	}
+
+	policy, err := ParsePolicy([]byte(cm.Data[PolicyConfigMapKey]))
+	if err != nil {
+		log.Printf("Warning: invalid policy in ConfigMap %s, keeping the previous policy: %v", j.config.PolicyConfigMap, err)
+		return
+	}
+	j.policy.Store(policy)
+	j.debugLog("Loaded %d namespace policies from ConfigMap %s", len(policy.Namespaces), j.config.PolicyConfigMap)
+}
+
+// namespacePolicy returns the namespace policy of the namespace containing a
+// resource, or nil if none applies. Namespaces and cluster-scoped resources
+// have no namespace policy.
+func (j *Janitor) namespacePolicy(ctx context.Context, obj metav1.Object) *NamespacePolicy {
+	if obj.GetNamespace() == "" {
+		return nil
+	}
+	policy := j.policy.Load()
+	if policy == nil {
+		return nil
+	}
+	return policy.forNamespace(obj.GetNamespace(), j.namespaceMeta(ctx, obj.GetNamespace()).Labels)
+}
+
+// rulesFor returns the rules to evaluate for a resource: the rules of the
+// rules file followed by the rules of its namespace policy
+func (j *Janitor) rulesFor(ctx context.Context, obj metav1.Object) []Rule {
+	np := j.namespacePolicy(ctx, obj)
+	if np == nil || len(np.Rules) == 0 {
+		return j.config.Rules
+	}
+	return append(append([]Rule{}, j.config.Rules...), np.Rules...)
+}
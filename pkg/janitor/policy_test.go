@@ -0,0 +1,151 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testPolicy = `
+namespaces:
+- namespace: ci
+  ttl: 1d
+- labels: environment=development
+  ttl: 7d
+  rules:
+  - id: short-lived-pods
+    resources:
+    - pods
+    jmespath: "metadata.labels.short == 'true'"
+    ttl: 1h
+`
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr bool
+	}{
+		{name: "valid policy", policy: testPolicy},
+		{name: "empty policy", policy: ""},
+		{name: "no namespace or labels", policy: "namespaces:\n- ttl: 1d\n", wantErr: true},
+		{name: "no TTL or rules", policy: "namespaces:\n- namespace: ci\n", wantErr: true},
+		{name: "invalid TTL", policy: "namespaces:\n- namespace: ci\n  ttl: soon\n", wantErr: true},
+		{name: "label without key", policy: "namespaces:\n- labels: =development\n  ttl: 1d\n", wantErr: true},
+		{name: "invalid rule", policy: "namespaces:\n- namespace: ci\n  rules:\n  - id: Invalid\n    ttl: 1d\n", wantErr: true},
+		{name: "invalid YAML", policy: "namespaces: [", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.policy))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicyConfigMap(t *testing.T) {
+	policyConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "kube-janitor"},
+		Data:       map[string]string{PolicyConfigMapKey: testPolicy},
+	}
+	clientset := fake.NewSimpleClientset(
+		policyConfigMap,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ci"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev", Labels: map[string]string{"environment": "development"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "annotated",
+			Labels:      map[string]string{"environment": "development"},
+			Annotations: map[string]string{TTLAnnotation: "30d"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}},
+	)
+	j := &Janitor{
+		client: clientset,
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			PolicyConfigMap:   "kube-janitor/policy",
+		},
+		cache: make(map[string]interface{}),
+	}
+	j.updatePolicy(context.Background())
+
+	tests := []struct {
+		name       string
+		namespace  string
+		age        time.Duration
+		labels     map[string]string
+		wantDelete bool
+		wantSkip   string
+	}{
+		{name: "policy TTL by namespace name", namespace: "ci", age: 2 * 24 * time.Hour, wantDelete: true},
+		{name: "policy TTL by namespace labels", namespace: "dev", age: 10 * 24 * time.Hour, wantDelete: true},
+		{name: "policy TTL not expired", namespace: "dev", age: 2 * 24 * time.Hour, wantSkip: SkipReasonNotExpired},
+		{name: "policy rule", namespace: "dev", age: 2 * time.Hour, labels: map[string]string{"short": "true"}, wantDelete: true},
+		{name: "namespace annotation takes precedence", namespace: "annotated", age: 10 * 24 * time.Hour, wantSkip: SkipReasonNotExpired},
+		{name: "no matching policy", namespace: "prod", age: 10 * 24 * time.Hour, wantSkip: SkipReasonNoTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newUnstructuredPod("pod", tt.namespace, time.Now().Add(-tt.age), nil)
+			pod.SetLabels(tt.labels)
+			counter := make(map[string]int)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			result := newCleanupResult(counter)
+			if tt.wantDelete {
+				if result.Deleted["pods"] != 1 {
+					t.Errorf("Expected the pod to be deleted, got %+v", result)
+				}
+			} else if result.Skipped[tt.wantSkip] != 1 || result.Deleted["pods"] != 0 {
+				t.Errorf("Expected the pod to be skipped with reason %s, got %+v", tt.wantSkip, result)
+			}
+			if tt.labels != nil && result.Rules["short-lived-pods"].Deleted != 1 {
+				t.Errorf("Expected the pod to be deleted by the policy rule, got %+v", result.Rules)
+			}
+		})
+	}
+
+	// The policy is reloaded every run, an invalid policy keeps the previous one
+	pod := newUnstructuredPod("pod", "ci", time.Now().Add(-2*24*time.Hour), nil)
+	policyConfigMap.Data[PolicyConfigMapKey] = "namespaces:\n- namespace: ci\n"
+	if _, err := clientset.CoreV1().ConfigMaps("kube-janitor").Update(context.Background(), policyConfigMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update policy ConfigMap: %v", err)
+	}
+	j.updatePolicy(context.Background())
+	if np := j.namespacePolicy(context.Background(), pod); np == nil || np.TTL != "1d" {
+		t.Errorf("Expected the previous policy to stay in effect, got %+v", np)
+	}
+
+	policyConfigMap.Data[PolicyConfigMapKey] = "namespaces:\n- namespace: ci\n  ttl: 3d\n"
+	if _, err := clientset.CoreV1().ConfigMaps("kube-janitor").Update(context.Background(), policyConfigMap, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update policy ConfigMap: %v", err)
+	}
+	j.updatePolicy(context.Background())
+	counter := make(map[string]int)
+	if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+		t.Fatalf("handleResource() error = %v", err)
+	}
+	if result := newCleanupResult(counter); result.Skipped[SkipReasonNotExpired] != 1 {
+		t.Errorf("Expected the reloaded policy TTL to apply, got %+v", result)
+	}
+
+	if err := clientset.CoreV1().ConfigMaps("kube-janitor").Delete(context.Background(), "policy", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete policy ConfigMap: %v", err)
+	}
+	j.updatePolicy(context.Background())
+	if j.policy.Load() != nil {
+		t.Error("Expected no policy once the ConfigMap is deleted")
+	}
+}
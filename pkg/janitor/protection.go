@@ -0,0 +1,92 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// protectionSkipReason returns the skip reason and a description of why a
+// resource is protected from deletion, or empty strings if it is not
+// protected. Besides old resources, only pods can be protected.
+func (j *Janitor) protectionSkipReason(ctx context.Context, obj metav1.Object) (string, string, error) {
+	if j.config.ProtectOlderThan != "" {
+		maxAge, err := ParseTTL(j.config.ProtectOlderThan)
+		if err != nil {
+			log.Printf("Warning: ignoring invalid protect-older-than %q", j.config.ProtectOlderThan)
+		} else if created := obj.GetCreationTimestamp(); maxAge >= 0 && !created.IsZero() && time.Since(created.Time) > maxAge {
+			return SkipReasonProtectedAge, fmt.Sprintf("older than %s", j.config.ProtectOlderThan), nil
+		}
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u.GetKind() != "Pod" || u.GroupVersionKind().Group != "" {
+		return "", "", nil
+	}
+
+	if len(j.config.ProtectedPriorityClasses) > 0 {
+		priorityClass, _, _ := unstructured.NestedString(u.Object, "spec", "priorityClassName")
+		if priorityClass != "" && stringInSlice(priorityClass, j.config.ProtectedPriorityClasses) {
+			return SkipReasonProtectedPriority, fmt.Sprintf("protected priority class %s", priorityClass), nil
+		}
+	}
+
+	if j.config.RespectPDBs {
+		pdbs, err := j.podDisruptionBudgets(ctx, obj.GetNamespace())
+		if err != nil {
+			return "", "", err
+		}
+		for _, pdb := range pdbs {
+			if pdb.Status.DisruptionsAllowed > 0 {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+			if err != nil || selector.Empty() {
+				continue
+			}
+			if selector.Matches(labels.Set(obj.GetLabels())) {
+				return SkipReasonPodDisruptionBudget, fmt.Sprintf("PodDisruptionBudget %s allows no disruptions", pdb.Name), nil
+			}
+		}
+	}
+
+	return "", "", nil
+}
+
+// deletionAllowed checks whether the type of a resource may be deleted at
+// all. When allowed kinds are configured, nothing else is ever deleted, no
+// matter which annotation or rule expired the resource.
+func (j *Janitor) deletionAllowed(obj metav1.Object) bool {
+	if len(j.config.AllowedKinds) == 0 {
+		return true
+	}
+	return stringInSlice(j.counterName(obj), j.config.AllowedKinds)
+}
+
+// podDisruptionBudgets returns the PodDisruptionBudgets of a namespace, cached
+// for the duration of a cleanup run
+func (j *Janitor) podDisruptionBudgets(ctx context.Context, namespace string) ([]policyv1.PodDisruptionBudget, error) {
+	j.pdbMutex.Lock()
+	defer j.pdbMutex.Unlock()
+
+	if pdbs, ok := j.pdbCache[namespace]; ok {
+		return pdbs, nil
+	}
+
+	list, err := j.client.PolicyV1().PodDisruptionBudgets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PodDisruptionBudgets in namespace %s: %v", namespace, err)
+	}
+
+	if j.pdbCache == nil {
+		j.pdbCache = make(map[string][]policyv1.PodDisruptionBudget)
+	}
+	j.pdbCache[namespace] = list.Items
+	return list.Items, nil
+}
@@ -0,0 +1,191 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHandleResourceProtectedPods(t *testing.T) {
+	newPDB := func(name, app string, allowed int32) *policyv1.PodDisruptionBudget {
+		return &policyv1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: policyv1.PodDisruptionBudgetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			},
+			Status: policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: allowed},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		priorityClass string
+		app           string
+		wantSkip      string
+	}{
+		{
+			name:          "critical priority pod is skipped",
+			priorityClass: "system-node-critical",
+			app:           "agent",
+			wantSkip:      SkipReasonProtectedPriority,
+		},
+		{
+			name:          "other priority pod is deleted",
+			priorityClass: "low",
+			app:           "agent",
+		},
+		{
+			name:     "pod covered by exhausted PDB is skipped",
+			app:      "database",
+			wantSkip: SkipReasonPodDisruptionBudget,
+		},
+		{
+			name: "pod covered by PDB allowing disruptions is deleted",
+			app:  "web",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				client: fake.NewSimpleClientset(
+					newPDB("database", "database", 0),
+					newPDB("web", "web", 1),
+				),
+				config: &Config{
+					DryRun:                   true,
+					IncludeResources:         []string{"all"},
+					IncludeNamespaces:        []string{"all"},
+					ProtectedPriorityClasses: []string{"system-node-critical", "system-cluster-critical"},
+					RespectPDBs:              true,
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			pod := newUnstructuredPod("pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+			pod.SetLabels(map[string]string{"app": tt.app})
+			if tt.priorityClass != "" {
+				unstructured.SetNestedField(pod.Object, tt.priorityClass, "spec", "priorityClassName")
+			}
+
+			counter := make(map[string]int)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			result := newCleanupResult(counter)
+			if tt.wantSkip != "" {
+				if result.Skipped[tt.wantSkip] != 1 || result.Deleted["pods"] != 0 {
+					t.Errorf("Expected the pod to be skipped with reason %s, got %+v", tt.wantSkip, result)
+				}
+			} else if result.Deleted["pods"] != 1 {
+				t.Errorf("Expected the pod to be deleted, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestHandleResourceProtectOlderThan(t *testing.T) {
+	tests := []struct {
+		name     string
+		age      time.Duration
+		wantSkip bool
+	}{
+		{name: "very old expired resource is protected", age: 200 * 24 * time.Hour, wantSkip: true},
+		{name: "recent expired resource is deleted", age: 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				client: fake.NewSimpleClientset(),
+				config: &Config{
+					DryRun:            true,
+					IncludeResources:  []string{"all"},
+					IncludeNamespaces: []string{"all"},
+					ProtectOlderThan:  "180d",
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			pod := newUnstructuredPod("pod", "default", time.Now().Add(-tt.age), map[string]string{TTLAnnotation: "1h"})
+			counter := make(map[string]int)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			result := newCleanupResult(counter)
+			if tt.wantSkip {
+				if result.Skipped[SkipReasonProtectedAge] != 1 || result.Deleted["pods"] != 0 {
+					t.Errorf("Expected the pod to be protected, got %+v", result)
+				}
+			} else if result.Deleted["pods"] != 1 {
+				t.Errorf("Expected the pod to be deleted, got %+v", result)
+			}
+		})
+	}
+}
+
+func TestDeleteResourceAllowedKinds(t *testing.T) {
+	pod := newUnstructuredPod("expired-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	deployment := newUnstructuredPod("expired-deployment", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	deployment.SetAPIVersion("apps/v1")
+	deployment.SetKind("Deployment")
+
+	tests := []struct {
+		name        string
+		obj         *unstructured.Unstructured
+		gvr         schema.GroupVersionResource
+		wantDeleted bool
+	}{
+		{
+			name:        "allowed kind is deleted",
+			obj:         pod,
+			gvr:         schema.GroupVersionResource{Version: "v1", Resource: "pods"},
+			wantDeleted: true,
+		},
+		{
+			name: "disallowed kind is refused",
+			obj:  deployment,
+			gvr:  schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tt.obj.DeepCopy())
+			j := &Janitor{
+				client:        fake.NewSimpleClientset(),
+				dynamicClient: dynamicClient,
+				config: &Config{
+					IncludeResources:  []string{"all"},
+					IncludeNamespaces: []string{"all"},
+					AllowedKinds:      []string{"pods", "jobs.batch"},
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			err := j.handleResource(context.Background(), tt.obj, make(map[string]int), make(map[string]bool))
+			if tt.wantDeleted && err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+			if !tt.wantDeleted && err == nil {
+				t.Fatal("Expected the deletion to be refused")
+			}
+
+			_, getErr := dynamicClient.Resource(tt.gvr).Namespace("default").Get(context.Background(), tt.obj.GetName(), metav1.GetOptions{})
+			if deleted := apierrors.IsNotFound(getErr); deleted != tt.wantDeleted {
+				t.Errorf("Expected deleted = %v, got get error %v", tt.wantDeleted, getErr)
+			}
+		})
+	}
+}
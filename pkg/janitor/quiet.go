@@ -0,0 +1,29 @@
+package janitor
+
+import "log"
+
+// trackQuietRun counts consecutive runs that deleted nothing and reports the
+// janitor as quiet once the configured threshold is reached, as a janitor that
+// stopped deleting may be caused by a broken rules file or missing
+// permissions. A run with deletions resets the count.
+func (j *Janitor) trackQuietRun(result *CleanupResult) {
+	deleted := 0
+	for _, count := range result.Deleted {
+		deleted += count
+	}
+
+	if deleted > 0 {
+		j.runsWithoutDeletions = 0
+	} else {
+		j.runsWithoutDeletions++
+	}
+	runsWithoutDeletions.Set(float64(j.runsWithoutDeletions))
+
+	if j.config.QuietRunThreshold <= 0 || j.runsWithoutDeletions < j.config.QuietRunThreshold {
+		quiet.Set(0)
+		return
+	}
+	log.Printf("Warning: nothing was deleted in the last %d cleanup runs, check the rules and permissions of the janitor",
+		j.runsWithoutDeletions)
+	quiet.Set(1)
+}
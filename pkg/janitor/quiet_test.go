@@ -0,0 +1,80 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestQuietRuns(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsGVR: "PodList"},
+		newUnstructuredPod("no-ttl", "default", time.Now().Add(-2*time.Hour), nil),
+	)
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			QuietRunThreshold: 3,
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	for i := 1; i <= 3; i++ {
+		if _, err := j.CleanUp(context.Background()); err != nil {
+			t.Fatalf("CleanUp() error = %v", err)
+		}
+
+		if got := testutil.ToFloat64(runsWithoutDeletions); got != float64(i) {
+			t.Errorf("After %d empty runs: runs without deletions = %v, want %d", i, got, i)
+		}
+		want := 0.0
+		if i == 3 {
+			want = 1
+		}
+		if got := testutil.ToFloat64(quiet); got != want {
+			t.Errorf("After %d empty runs: quiet = %v, want %v", i, got, want)
+		}
+	}
+
+	// A run that deletes something resets the count
+	expired := newUnstructuredPod("expired", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Create(context.Background(), expired, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create pod: %v", err)
+	}
+	result, err := j.CleanUp(context.Background())
+	if err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+	if result.Deleted["pods"] != 1 {
+		t.Fatalf("Expected the expired pod to be deleted, got %+v", result)
+	}
+	if got := testutil.ToFloat64(runsWithoutDeletions); got != 0 {
+		t.Errorf("After a run with deletions: runs without deletions = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(quiet); got != 0 {
+		t.Errorf("After a run with deletions: quiet = %v, want 0", got)
+	}
+}
@@ -2,91 +2,246 @@ package janitor
 
 import (
 	"fmt"
-	"k8s.io/client-go/kubernetes"
+	"log"
+	"sort"
 	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
 )
 
+// DefaultAPIPreferences lists resources that are served under different names,
+// each as a chain of group/version/plural keys where the first available key
+// is preferred and the others are dropped
+var DefaultAPIPreferences = [][]string{
+	{"discovery.k8s.io/v1/endpointslices", "v1/endpoints"},
+}
+
 // ResourceType represents a Kubernetes API resource type
 type ResourceType struct {
 	Group      string
 	Version    string
 	Kind       string
 	Plural     string
+	ShortNames []string
 	Namespaced bool
 }
 
-// GetResourceTypes returns all available resource types in the cluster
-func GetResourceTypes(client kubernetes.Interface) ([]ResourceType, error) {
-	resourceTypesMap := make(map[string]ResourceType)
+// GetResourceTypes returns all available resource types in the cluster.
+// apiPreferences are applied before DefaultAPIPreferences to decide between
+// resources served by multiple APIs (see filterDeprecatedAPIs).
+func GetResourceTypes(client discovery.DiscoveryInterface, apiPreferences [][]string) ([]ResourceType, error) {
+	resourceTypes, err := discoverResourceTypes(client, apiPreferences, "delete")
+	if err != nil {
+		return nil, err
+	}
+	return resourceTypes["delete"], nil
+}
 
-	// Get server resources for core API group
-	resources, err := client.Discovery().ServerResourcesForGroupVersion("v1")
+// discoverResourceTypes returns the resource types in the cluster that
+// support each of the given verbs, with the API preferences applied. The API
+// resources are discovered once for all verbs.
+func discoverResourceTypes(client discovery.DiscoveryInterface, apiPreferences [][]string, verbs ...string) (map[string][]ResourceType, error) {
+	// ServerPreferredResources covers the core group as well and returns every
+	// resource once, in the preferred version of its group if it is served there
+	resourceLists, err := discovery.ServerPreferredResources(client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get core API resources: %v", err)
+		failed, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+		if !ok {
+			return nil, fmt.Errorf("failed to discover API resources: %v", err)
+		}
+		if coreErr, ok := failed.Groups[schema.GroupVersion{Version: "v1"}]; ok {
+			return nil, fmt.Errorf("failed to get core API resources: %v", coreErr)
+		}
+		// Unavailable aggregated APIs must not stop the cleanup of everything else
+		log.Printf("Warning: skipping API groups that failed discovery: %v", err)
+	}
+
+	resourceTypes := make(map[string][]ResourceType, len(verbs))
+	for _, verb := range verbs {
+		resourceTypesMap := make(map[string]ResourceType)
+		for _, resources := range resourceLists {
+			gv, err := schema.ParseGroupVersion(resources.GroupVersion)
+			if err != nil {
+				continue
+			}
+
+			for _, r := range resources.APIResources {
+				if strings.Contains(r.Name, "/") || !stringInSlice(verb, r.Verbs) {
+					continue
+				}
+
+				key := fmt.Sprintf("%s/%s", resources.GroupVersion, r.Name)
+				resourceTypesMap[key] = ResourceType{
+					Group:      gv.Group,
+					Version:    gv.Version,
+					Kind:       r.Kind,
+					Plural:     r.Name,
+					ShortNames: r.ShortNames,
+					Namespaced: r.Namespaced,
+				}
+			}
+		}
+
+		// Remove deprecated APIs when newer alternatives exist
+		filterDeprecatedAPIs(resourceTypesMap, apiPreferences)
+
+		// Convert map to slice
+		resourceTypes[verb] = make([]ResourceType, 0, len(resourceTypesMap))
+		for _, rt := range resourceTypesMap {
+			resourceTypes[verb] = append(resourceTypes[verb], rt)
+		}
 	}
 
-	for _, r := range resources.APIResources {
-		if strings.Contains(r.Name, "/") || !stringInSlice("delete", r.Verbs) {
+	return resourceTypes, nil
+}
+
+// filterDeprecatedAPIs removes deprecated API resources when newer alternatives exist.
+// The preference chains (followed by DefaultAPIPreferences) are applied first,
+// then a Kind served by several built-in API groups (e.g. Ingress in
+// extensions/v1beta1 and networking.k8s.io/v1) is only kept in its newest
+// version. Keys preferred by a chain always win over other versions.
+func filterDeprecatedAPIs(resourceTypesMap map[string]ResourceType, preferences [][]string) {
+	preferred := make(map[string]bool)
+	chains := append(append([][]string{}, preferences...), DefaultAPIPreferences...)
+	for _, chain := range chains {
+		found := false
+		for _, key := range chain {
+			if _, ok := resourceTypesMap[key]; !ok {
+				continue
+			}
+			if !found {
+				found = true
+				preferred[key] = true
+				continue
+			}
+			delete(resourceTypesMap, key)
+		}
+	}
+
+	// Group the built-in resource types by Kind, sorted for a deterministic result
+	keysByKind := make(map[string][]string)
+	for key, rt := range resourceTypesMap {
+		if isBuiltinGroup(rt.Group) {
+			keysByKind[rt.Kind] = append(keysByKind[rt.Kind], key)
+		}
+	}
+
+	for _, keys := range keysByKind {
+		if len(keys) < 2 {
 			continue
 		}
+		sort.Strings(keys)
 
-		key := fmt.Sprintf("v1/%s", r.Name)
-		resourceTypesMap[key] = ResourceType{
-			Group:      "",
-			Version:    "v1",
-			Kind:       r.Kind,
-			Plural:     r.Name,
-			Namespaced: r.Namespaced,
+		best := keys[0]
+		for _, key := range keys[1:] {
+			if preferAPI(resourceTypesMap[key], resourceTypesMap[best], preferred[key], preferred[best]) {
+				best = key
+			}
+		}
+		for _, key := range keys {
+			if key != best {
+				delete(resourceTypesMap, key)
+			}
 		}
 	}
+}
 
-	// Get server API groups
-	groups, err := client.Discovery().ServerGroups()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get API groups: %v", err)
+// preferAPI reports whether resource type a is preferred over b for the same Kind
+func preferAPI(a, b ResourceType, aPreferred, bPreferred bool) bool {
+	if aPreferred != bPreferred {
+		return aPreferred
 	}
+	if cmp := version.CompareKubeAwareVersionStrings(a.Version, b.Version); cmp != 0 {
+		return cmp > 0
+	}
+	// Same version: prefer the dedicated group over the core group (e.g. events.k8s.io)
+	return a.Group != "" && b.Group == ""
+}
 
-	for _, group := range groups.Groups {
-		version := group.PreferredVersion
-		resources, err := client.Discovery().ServerResourcesForGroupVersion(version.GroupVersion)
-		if err != nil {
+// isBuiltinGroup checks if an API group is served by Kubernetes itself rather than by a CRD
+func isBuiltinGroup(group string) bool {
+	return group == "" || !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io")
+}
+
+// ResolveShortNames replaces kubectl-style short names (e.g. po, deploy) in a
+// list of resource names with the name of the matching resource type. Names
+// that match neither a resource type nor a short name are returned as unknown.
+func ResolveShortNames(names []string, resourceTypes []ResourceType) (resolved []string, unknown []string) {
+	for _, name := range names {
+		if name == "all" || name == "" {
+			resolved = append(resolved, name)
 			continue
 		}
 
-		for _, r := range resources.APIResources {
-			if strings.Contains(r.Name, "/") || !stringInSlice("delete", r.Verbs) {
-				continue
+		known := false
+		for _, rt := range resourceTypes {
+			if matchesResourceName(name, rt.Plural, rt.Group) {
+				known = true
+				break
 			}
+		}
+		if known {
+			resolved = append(resolved, name)
+			continue
+		}
 
-			key := fmt.Sprintf("%s/%s", version.GroupVersion, r.Name)
-			resourceTypesMap[key] = ResourceType{
-				Group:      group.Name,
-				Version:    version.Version,
-				Kind:       r.Kind,
-				Plural:     r.Name,
-				Namespaced: r.Namespaced,
+		for _, rt := range resourceTypes {
+			if stringInSlice(name, rt.ShortNames) {
+				known = true
+				resolved = append(resolved, qualifiedResourceName(rt))
 			}
 		}
+		if !known {
+			unknown = append(unknown, name)
+			resolved = append(resolved, name)
+		}
 	}
 
-	// Remove deprecated APIs when newer alternatives exist
-	filterDeprecatedAPIs(resourceTypesMap)
+	return resolved, unknown
+}
 
-	// Convert map to slice
-	resourceTypes := make([]ResourceType, 0, len(resourceTypesMap))
-	for _, rt := range resourceTypesMap {
-		resourceTypes = append(resourceTypes, rt)
+// qualifiedResourceName returns the plural of a resource type, qualified
+// with its API group for resources outside of the core group
+func qualifiedResourceName(rt ResourceType) string {
+	if rt.Group == "" {
+		return rt.Plural
 	}
+	return rt.Plural + "." + rt.Group
+}
 
-	return resourceTypes, nil
+// parseResourceKey parses a group/version/plural key as used in API
+// preferences. Core resources omit the group, e.g. v1/events.
+func parseResourceKey(key string) (schema.GroupVersionResource, error) {
+	i := strings.LastIndex(key, "/")
+	if i <= 0 || i == len(key)-1 {
+		return schema.GroupVersionResource{}, fmt.Errorf("%q is not of the form group/version/plural", key)
+	}
+	gv, err := schema.ParseGroupVersion(key[:i])
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("%q is not of the form group/version/plural: %v", key, err)
+	}
+	return gv.WithResource(key[i+1:]), nil
+}
+
+// matchesResourceName checks if a configured resource name refers to the given
+// plural and API group. A bare plural (e.g. ingresses) matches any group, a
+// group-qualified name (e.g. ingresses.networking.k8s.io) only that group.
+func matchesResourceName(name, plural, group string) bool {
+	if name == plural {
+		return true
+	}
+	return group != "" && name == plural+"."+group
 }
 
-// filterDeprecatedAPIs removes deprecated API resources when newer alternatives exist
-func filterDeprecatedAPIs(resourceTypesMap map[string]ResourceType) {
-	// Remove v1/endpoints if discovery.k8s.io/v1/endpointslices exists
-	if _, hasEndpointSlices := resourceTypesMap["discovery.k8s.io/v1/endpointslices"]; hasEndpointSlices {
-		delete(resourceTypesMap, "v1/endpoints")
+// matchesGroupName checks if a configured group name refers to the given API
+// group. The core group is addressed as "core" or as an empty string.
+func matchesGroupName(name, group string) bool {
+	if group == "" && name == "core" {
+		return true
 	}
+	return name == group
 }
 
 func stringInSlice(str string, slice []string) bool {
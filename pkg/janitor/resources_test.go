@@ -1,21 +1,256 @@
 package janitor
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestGetResourceTypes(t *testing.T) {
-	// Skip this test as the fake client doesn't properly support the discovery API
-	t.Skip("Skipping as fake client doesn't properly support discovery API")
+	client := &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", ShortNames: []string{"po"}, Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+				{Name: "bindings", Kind: "Binding", Namespaced: true, Verbs: []string{"create"}},
+				{Name: "namespaces", Kind: "Namespace", Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "networking.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "ingresses", Kind: "Ingress", ShortNames: []string{"ing"}, Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	resourceTypes, err := GetResourceTypes(client, nil)
+	if err != nil {
+		t.Fatalf("GetResourceTypes() error = %v", err)
+	}
+
+	sort.Slice(resourceTypes, func(a, b int) bool { return resourceTypes[a].Plural < resourceTypes[b].Plural })
+	want := []ResourceType{
+		{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress", Plural: "ingresses", ShortNames: []string{"ing"}, Namespaced: true},
+		{Group: "", Version: "v1", Kind: "Namespace", Plural: "namespaces"},
+		{Group: "", Version: "v1", Kind: "Pod", Plural: "pods", ShortNames: []string{"po"}, Namespaced: true},
+	}
+	if !reflect.DeepEqual(resourceTypes, want) {
+		t.Errorf("GetResourceTypes() = %+v, want %+v", resourceTypes, want)
+	}
+}
+
+// failingDiscovery fails the discovery of some group versions
+type failingDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	failing []string
+}
+
+func (d *failingDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	if stringInSlice(groupVersion, d.failing) {
+		return nil, fmt.Errorf("the server is currently unable to handle the request")
+	}
+	return d.FakeDiscovery.ServerResourcesForGroupVersion(groupVersion)
+}
+
+func TestGetResourceTypesPreferredVersions(t *testing.T) {
+	resources := []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			// The first version of a group is the preferred one in the fake discovery
+			GroupVersion: "autoscaling/v2",
+			APIResources: []metav1.APIResource{
+				{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "autoscaling/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "horizontalpodautoscalers", Kind: "HorizontalPodAutoscaler", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "scalers", Kind: "Scaler", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "metrics.example.com/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "samples", Kind: "Sample", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		failing []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "resources are listed once, in the preferred version if served there",
+			want: []string{
+				"autoscaling/v1/scalers",
+				"autoscaling/v2/horizontalpodautoscalers",
+				"metrics.example.com/v1/samples",
+				"v1/configmaps",
+			},
+		},
+		{
+			name:    "failing groups are skipped",
+			failing: []string{"metrics.example.com/v1"},
+			want: []string{
+				"autoscaling/v1/scalers",
+				"autoscaling/v2/horizontalpodautoscalers",
+				"v1/configmaps",
+			},
+		},
+		{
+			name:    "failing core group is an error",
+			failing: []string{"v1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &failingDiscovery{
+				FakeDiscovery: &fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: resources}},
+				failing:       tt.failing,
+			}
+
+			resourceTypes, err := GetResourceTypes(client, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetResourceTypes() error = %v", err)
+			}
+
+			var got []string
+			for _, rt := range resourceTypes {
+				got = append(got, schema.GroupVersion{Group: rt.Group, Version: rt.Version}.String()+"/"+rt.Plural)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetResourceTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
 }
 
 func TestFilterDeprecatedAPIs(t *testing.T) {
 	tests := []struct {
 		name           string
 		resourceTypes  map[string]ResourceType
+		preferences    [][]string
 		expectedKeys   []string
 		unexpectedKeys []string
 	}{
+		{
+			name: "drops the older version of a kind served by two groups",
+			resourceTypes: map[string]ResourceType{
+				"extensions/v1beta1/ingresses": {
+					Group:      "extensions",
+					Version:    "v1beta1",
+					Kind:       "Ingress",
+					Plural:     "ingresses",
+					Namespaced: true,
+				},
+				"networking.k8s.io/v1/ingresses": {
+					Group:      "networking.k8s.io",
+					Version:    "v1",
+					Kind:       "Ingress",
+					Plural:     "ingresses",
+					Namespaced: true,
+				},
+			},
+			expectedKeys:   []string{"networking.k8s.io/v1/ingresses"},
+			unexpectedKeys: []string{"extensions/v1beta1/ingresses"},
+		},
+		{
+			name: "keeps CRDs with the same kind as a built-in resource",
+			resourceTypes: map[string]ResourceType{
+				"networking.k8s.io/v1/ingresses": {
+					Group:      "networking.k8s.io",
+					Version:    "v1",
+					Kind:       "Ingress",
+					Plural:     "ingresses",
+					Namespaced: true,
+				},
+				"example.com/v1alpha1/ingresses": {
+					Group:      "example.com",
+					Version:    "v1alpha1",
+					Kind:       "Ingress",
+					Plural:     "ingresses",
+					Namespaced: true,
+				},
+			},
+			expectedKeys:   []string{"networking.k8s.io/v1/ingresses", "example.com/v1alpha1/ingresses"},
+			unexpectedKeys: []string{},
+		},
+		{
+			name: "configured preference overrides the newest version",
+			resourceTypes: map[string]ResourceType{
+				"extensions/v1beta1/ingresses": {
+					Group:      "extensions",
+					Version:    "v1beta1",
+					Kind:       "Ingress",
+					Plural:     "ingresses",
+					Namespaced: true,
+				},
+				"networking.k8s.io/v1/ingresses": {
+					Group:      "networking.k8s.io",
+					Version:    "v1",
+					Kind:       "Ingress",
+					Plural:     "ingresses",
+					Namespaced: true,
+				},
+			},
+			preferences:    [][]string{{"extensions/v1beta1/ingresses", "networking.k8s.io/v1/ingresses"}},
+			expectedKeys:   []string{"extensions/v1beta1/ingresses"},
+			unexpectedKeys: []string{"networking.k8s.io/v1/ingresses"},
+		},
+		{
+			name: "configured preference overrides the default endpoints preference",
+			resourceTypes: map[string]ResourceType{
+				"v1/endpoints": {
+					Group:      "",
+					Version:    "v1",
+					Kind:       "Endpoints",
+					Plural:     "endpoints",
+					Namespaced: true,
+				},
+				"discovery.k8s.io/v1/endpointslices": {
+					Group:      "discovery.k8s.io",
+					Version:    "v1",
+					Kind:       "EndpointSlice",
+					Plural:     "endpointslices",
+					Namespaced: true,
+				},
+			},
+			preferences:    [][]string{{"v1/endpoints", "discovery.k8s.io/v1/endpointslices"}},
+			expectedKeys:   []string{"v1/endpoints"},
+			unexpectedKeys: []string{"discovery.k8s.io/v1/endpointslices"},
+		},
 		{
 			name: "removes endpoints when endpointslices exist",
 			resourceTypes: map[string]ResourceType{
@@ -96,7 +331,7 @@ func TestFilterDeprecatedAPIs(t *testing.T) {
 				resourceTypesMap[k] = v
 			}
 
-			filterDeprecatedAPIs(resourceTypesMap)
+			filterDeprecatedAPIs(resourceTypesMap, tt.preferences)
 
 			// Check that expected keys are present
 			for _, key := range tt.expectedKeys {
@@ -114,3 +349,57 @@ func TestFilterDeprecatedAPIs(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveShortNames(t *testing.T) {
+	resourceTypes := []ResourceType{
+		{Version: "v1", Kind: "Pod", Plural: "pods", ShortNames: []string{"po"}, Namespaced: true},
+		{Version: "v1", Kind: "Service", Plural: "services", ShortNames: []string{"svc"}, Namespaced: true},
+		{Group: "apps", Version: "v1", Kind: "Deployment", Plural: "deployments", ShortNames: []string{"deploy"}, Namespaced: true},
+		{Version: "v1", Kind: "PersistentVolume", Plural: "persistentvolumes", ShortNames: []string{"pv"}},
+		{Group: "storage.k8s.io", Version: "v1", Kind: "StorageClass", Plural: "storageclasses", ShortNames: []string{"sc"}},
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-include-resources", "po,deploy,pv,sc,nope", "-include-cluster-resources=pv"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+
+	resolved, unknown := ResolveShortNames(config.IncludeResources, resourceTypes)
+	if want := []string{"pods", "deployments.apps", "persistentvolumes", "storageclasses.storage.k8s.io", "nope"}; !reflect.DeepEqual(resolved, want) {
+		t.Errorf("ResolveShortNames() resolved = %v, want %v", resolved, want)
+	}
+	if want := []string{"nope"}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("ResolveShortNames() unknown = %v, want %v", unknown, want)
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	// Names are resolved once, without changing the config
+	j := &Janitor{config: config}
+	j.resolveResourceNames(resourceTypes)
+	j.resolveResourceNames(resourceTypes)
+	if got := strings.Count(buf.String(), "Warning: unknown resource"); got != 1 {
+		t.Errorf("Expected a single warning for the unknown resource, got %d:\n%s", got, buf.String())
+	}
+	if want := []string{"po", "deploy", "pv", "sc", "nope"}; !reflect.DeepEqual(config.IncludeResources, want) {
+		t.Errorf("IncludeResources = %v, want the configured names %v", config.IncludeResources, want)
+	}
+
+	for _, rt := range resourceTypes {
+		want := rt.Plural != "services"
+		if got := j.shouldProcessResourceType(rt); got != want {
+			t.Errorf("shouldProcessResourceType(%s) = %v, want %v", rt.Plural, got, want)
+		}
+	}
+	if !j.includesClusterResource("persistentvolumes", "") {
+		t.Error("Expected the pv short name to include persistentvolumes as cluster resource")
+	}
+	if j.includesClusterResource("storageclasses", "storage.k8s.io") {
+		t.Error("Expected storageclasses not to be included as cluster resource")
+	}
+}
@@ -0,0 +1,143 @@
+package janitor
+
+import (
+	"context"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons for skipping a resource, used as counter and summary keys
+const (
+	SkipReasonExcludedResource    = "excluded-resource"
+	SkipReasonExcludedNamespace   = "excluded-namespace"
+	SkipReasonExcludedLabel       = "excluded-label"
+	SkipReasonExcludedAnnotation  = "excluded-annotation"
+	SkipReasonExcludedJMESPath    = "excluded-jmespath"
+	SkipReasonCreationWindow      = "outside-creation-window"
+	SkipReasonClusterResource     = "cluster-resource"
+	SkipReasonNoTTL               = "no-ttl"
+	SkipReasonNoMatchingRule      = "no-matching-rule"
+	SkipReasonUnlimitedTTL        = "unlimited-ttl"
+	SkipReasonNotExpired          = "not-expired"
+	SkipReasonNoCreationTimestamp = "no-creation-timestamp"
+	SkipReasonSoftDeletePending   = "soft-delete-pending"
+	SkipReasonExpiredGracePending = "expired-grace-pending"
+	SkipReasonProtectedPriority   = "protected-priority"
+	SkipReasonProtectedAge        = "protected-age"
+	SkipReasonPodDisruptionBudget = "pod-disruption-budget"
+	SkipReasonForbidden           = "forbidden"
+	SkipReasonNotDeletable        = "not-deletable"
+	SkipReasonNotConfirmed        = "not-confirmed"
+	SkipReasonPaused              = "paused"
+)
+
+// Counter key prefixes and suffixes
+const (
+	processedCounter     = "resources-processed"
+	deletedCounterSuffix = "-deleted"
+	ruleCounterPrefix    = "rule-"
+	skippedCounterPrefix = "skipped-"
+	errorsCounter        = "errors"
+)
+
+// Per-rule statistics, counted as rule-<id>:<stat>. Rule IDs can't contain
+// colons, so that the rule ID and statistic can be told apart.
+const (
+	ruleStatMatched  = "matched"
+	ruleStatDeleted  = "deleted"
+	ruleStatNotified = "notified"
+)
+
+// RuleStats counts what a rule did in a cleanup run
+type RuleStats struct {
+	// Matched is the number of resources the rule applied to
+	Matched int `json:"matched"`
+	// Deleted is the number of resources deleted because of the rule
+	Deleted int `json:"deleted"`
+	// Notified is the number of delete notifications sent because of the rule
+	Notified int `json:"notified"`
+}
+
+// CleanupResult summarizes a cleanup run
+type CleanupResult struct {
+	// Processed is the number of resources that matched the filters
+	Processed int `json:"processed"`
+	// Deleted is the number of deleted resources by resource type, e.g. "pods"
+	// or "deployments.apps"
+	Deleted map[string]int `json:"deleted"`
+	// Skipped is the number of skipped resources by reason, e.g. "no-ttl"
+	Skipped map[string]int `json:"skipped"`
+	// Errors is the number of resources or resource types that failed to
+	// be processed, e.g. because a list or delete call failed
+	Errors int `json:"errors"`
+	// Rules counts what each rule that matched a resource did, by rule ID
+	Rules map[string]RuleStats `json:"rules,omitempty"`
+}
+
+// newCleanupResult builds the result of a cleanup run from its counters
+func newCleanupResult(counter map[string]int) *CleanupResult {
+	result := &CleanupResult{
+		Deleted: make(map[string]int),
+		Skipped: make(map[string]int),
+	}
+
+	for k, v := range counter {
+		switch {
+		case k == processedCounter:
+			result.Processed = v
+		case k == errorsCounter:
+			result.Errors = v
+		case strings.HasPrefix(k, ruleCounterPrefix):
+			id, stat, _ := strings.Cut(strings.TrimPrefix(k, ruleCounterPrefix), ":")
+			if result.Rules == nil {
+				result.Rules = make(map[string]RuleStats)
+			}
+			stats := result.Rules[id]
+			switch stat {
+			case ruleStatMatched:
+				stats.Matched = v
+			case ruleStatDeleted:
+				stats.Deleted = v
+			case ruleStatNotified:
+				stats.Notified = v
+			}
+			result.Rules[id] = stats
+		case strings.HasPrefix(k, skippedCounterPrefix):
+			result.Skipped[strings.TrimPrefix(k, skippedCounterPrefix)] = v
+		case strings.HasSuffix(k, deletedCounterSuffix):
+			result.Deleted[strings.TrimSuffix(k, deletedCounterSuffix)] = v
+		}
+	}
+
+	return result
+}
+
+// countSkip increments the skip counter for the given reason
+func (j *Janitor) countSkip(counter map[string]int, reason string) {
+	j.counterMutex.Lock()
+	defer j.counterMutex.Unlock()
+	counter[skippedCounterPrefix+reason]++
+}
+
+// countRule increments a statistic of a rule
+func (j *Janitor) countRule(counter map[string]int, ruleID, stat string) {
+	j.counterMutex.Lock()
+	defer j.counterMutex.Unlock()
+	counter[ruleCounterPrefix+ruleID+":"+stat]++
+}
+
+// countError increments the error counter
+func (j *Janitor) countError(counter map[string]int) {
+	j.counterMutex.Lock()
+	defer j.counterMutex.Unlock()
+	counter[errorsCounter]++
+}
+
+// skipResource counts a retained resource by skip reason, records the keep
+// decision and, if enabled, explains it in an event
+func (j *Janitor) skipResource(ctx context.Context, obj metav1.Object, counter map[string]int, skipReason, source, reason string) {
+	j.countSkip(counter, skipReason)
+	j.recordDecision(obj, source, DecisionKeep, reason)
+	j.emitKeepEvent(ctx, obj, skipReason, reason)
+}
@@ -0,0 +1,150 @@
+package janitor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSkipReasonsTallied(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			ExcludeNamespaces: []string{"kube-system"},
+			Rules: []Rule{
+				{
+					ID:        "temporary",
+					Resources: []string{"pods"},
+					JMESPath:  "metadata.labels.temporary == 'true'",
+					TTL:       "1h",
+				},
+			},
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-30 * time.Minute)
+	pods := []struct {
+		name        string
+		namespace   string
+		created     time.Time
+		annotations map[string]string
+	}{
+		{"system-pod", "kube-system", old, map[string]string{TTLAnnotation: "1h"}},
+		{"bare-pod", "default", old, nil},
+		{"unmatched-pod", "default", old, map[string]string{"team": "a"}},
+		{"forever-pod", "default", old, map[string]string{TTLAnnotation: "forever"}},
+		{"valid-pod", "default", recent, map[string]string{TTLAnnotation: "1h"}},
+		{"valid-expiry-pod", "default", recent, map[string]string{ExpiryAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339)}},
+		{"expired-pod", "default", old, map[string]string{TTLAnnotation: "1h"}},
+	}
+
+	counter := make(map[string]int)
+	for _, p := range pods {
+		pod := newUnstructuredPod(p.name, p.namespace, p.created, p.annotations)
+		if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource(%s) error = %v", p.name, err)
+		}
+	}
+
+	result := newCleanupResult(counter)
+	wantSkipped := map[string]int{
+		SkipReasonExcludedNamespace: 1,
+		SkipReasonNoMatchingRule:    2,
+		SkipReasonUnlimitedTTL:      1,
+		SkipReasonNotExpired:        2,
+	}
+	if !reflect.DeepEqual(result.Skipped, wantSkipped) {
+		t.Errorf("Skipped = %v, want %v", result.Skipped, wantSkipped)
+	}
+	if result.Processed != 6 {
+		t.Errorf("Processed = %d, want 6", result.Processed)
+	}
+	if want := map[string]int{"pods": 1}; !reflect.DeepEqual(result.Deleted, want) {
+		t.Errorf("Deleted = %v, want %v", result.Deleted, want)
+	}
+}
+
+func TestRuleStatsTallied(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:             true,
+			IncludeResources:   []string{"all"},
+			IncludeNamespaces:  []string{"all"},
+			DeleteNotification: 1800,
+			Rules: []Rule{
+				{
+					ID:        "temporary",
+					Resources: []string{"*"},
+					JMESPath:  "metadata.labels.temporary == 'true'",
+					TTL:       "1h",
+				},
+				{
+					ID:        "preview",
+					Resources: []string{"*"},
+					JMESPath:  "metadata.labels.preview == 'true'",
+					TTL:       "2h",
+				},
+				{
+					ID:        "unused",
+					Resources: []string{"*"},
+					JMESPath:  "metadata.labels.unused == 'true'",
+					TTL:       "1h",
+				},
+			},
+		},
+		cache: make(map[string]interface{}),
+	}
+	for i := range j.config.Rules {
+		if err := j.config.Rules[i].ValidateAndCompile(); err != nil {
+			t.Fatalf("Failed to compile rule: %v", err)
+		}
+	}
+
+	pods := []struct {
+		name  string
+		label string
+		age   time.Duration
+	}{
+		{"expired-temporary", "temporary", 2 * time.Hour},
+		{"recent-temporary", "temporary", 10 * time.Minute},
+		// Expires within the delete notification lead time
+		{"expiring-preview", "preview", 100 * time.Minute},
+		{"unlabeled", "", 2 * time.Hour},
+	}
+
+	counter := make(map[string]int)
+	for _, p := range pods {
+		pod := newUnstructuredPod(p.name, "default", time.Now().Add(-p.age), nil)
+		if p.label != "" {
+			pod.SetLabels(map[string]string{p.label: "true"})
+		}
+		if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource(%s) error = %v", p.name, err)
+		}
+	}
+
+	result := newCleanupResult(counter)
+	want := map[string]RuleStats{
+		"temporary": {Matched: 2, Deleted: 1},
+		"preview":   {Matched: 1, Notified: 1},
+	}
+	if !reflect.DeepEqual(result.Rules, want) {
+		t.Errorf("Rules = %v, want %v", result.Rules, want)
+	}
+	if want := map[string]int{"pods": 1}; !reflect.DeepEqual(result.Deleted, want) {
+		t.Errorf("Deleted = %v, want %v", result.Deleted, want)
+	}
+	if got := testutil.ToFloat64(ruleMatches.WithLabelValues("preview")); got != 1 {
+		t.Errorf("rule matches of preview = %v, want 1", got)
+	}
+}
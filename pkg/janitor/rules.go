@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/jmespath/go-jmespath"
 	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 var ruleIDPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
@@ -17,9 +21,22 @@ type Rule struct {
 	Resources []string `yaml:"resources"`
 	JMESPath  string   `yaml:"jmespath"`
 	TTL       string   `yaml:"ttl"`
+	Enabled   *bool    `yaml:"enabled"`
 
-	// Compiled JMESPath expression
-	compiledExpr *jmespath.JMESPath
+	// Whether * in the resources also matches cluster-scoped resources
+	ClusterScoped bool `yaml:"clusterScoped"`
+
+	// Lead time of delete notifications for the matched resources, overrides
+	// --delete-notification
+	Notification string `yaml:"notification"`
+
+	// Annotation holding an RFC 3339 timestamp the TTL counts from instead of
+	// the deployment or creation time
+	TTLFrom string `yaml:"ttlFrom"`
+
+	// Compiled JMESPath expression and parsed notification lead time
+	compiledExpr     *jmespath.JMESPath
+	notificationLead time.Duration
 }
 
 // RulesFile represents the structure of the YAML rules file
@@ -34,11 +51,25 @@ func (r *Rule) ValidateAndCompile() error {
 		return fmt.Errorf("invalid rule ID %q: must match ^[a-z][a-z0-9-]*$", r.ID)
 	}
 
+	// Disabled rules are never evaluated, so they may be work in progress
+	if !r.IsEnabled() {
+		return nil
+	}
+
 	// Validate TTL format
 	if _, err := ParseTTL(r.TTL); err != nil {
 		return fmt.Errorf("invalid TTL %q in rule %s: %v", r.TTL, r.ID, err)
 	}
 
+	// Validate notification lead time
+	if r.Notification != "" {
+		lead, err := ParseTTL(r.Notification)
+		if err != nil || lead <= 0 {
+			return fmt.Errorf("invalid notification %q in rule %s: must be a positive duration", r.Notification, r.ID)
+		}
+		r.notificationLead = lead
+	}
+
 	// Compile JMESPath expression
 	expr, err := jmespath.Compile(r.JMESPath)
 	if err != nil {
@@ -49,19 +80,49 @@ func (r *Rule) ValidateAndCompile() error {
 	return nil
 }
 
-// Matches checks if the rule matches the given resource and context
+// IsEnabled returns whether the rule is enabled, rules are enabled by default
+func (r *Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// Matches checks if the rule matches the given resource and context. The
+// resource type is guessed from the kind of the resource, use MatchesResource
+// if it is known from discovery.
 func (r *Rule) Matches(resource map[string]interface{}, context map[string]interface{}) bool {
-	// Check if resource type matches
 	kind, ok := resource["kind"].(string)
 	if !ok {
 		return false
 	}
-	resourceType := fmt.Sprintf("%ss", kind)
+	apiVersion, _ := resource["apiVersion"].(string)
+	gv, _ := schema.ParseGroupVersion(apiVersion)
+	namespace, _, _ := unstructured.NestedString(resource, "metadata", "namespace")
+
+	resourceType := ResourceType{
+		Group:      gv.Group,
+		Version:    gv.Version,
+		Kind:       kind,
+		Plural:     strings.ToLower(kind) + "s",
+		Namespaced: namespace != "",
+	}
+	return r.MatchesResource(resourceType, resource, context)
+}
+
+// MatchesResource checks if the rule matches the given resource of the given
+// type and context. * in the resources of the rule matches any namespaced
+// resource type, and cluster-scoped ones too if the rule says so.
+func (r *Rule) MatchesResource(resourceType ResourceType, resource map[string]interface{}, context map[string]interface{}) bool {
+	if !r.IsEnabled() || r.compiledExpr == nil {
+		return false
+	}
 
 	matches := false
 	for _, allowedResource := range r.Resources {
-		if allowedResource == "*" || allowedResource == resourceType {
-			matches = true
+		if allowedResource == "*" {
+			matches = resourceType.Namespaced || r.ClusterScoped
+		} else {
+			matches = matchesResourceName(allowedResource, resourceType.Plural, resourceType.Group)
+		}
+		if matches {
 			break
 		}
 	}
@@ -81,8 +142,12 @@ func (r *Rule) Matches(resource map[string]interface{}, context map[string]inter
 	if err != nil {
 		return false
 	}
+	return isTruthy(result)
+}
 
-	// Convert result to boolean
+// isTruthy converts the result of a JMESPath expression to a boolean, empty
+// strings, lists and objects are false
+func isTruthy(result interface{}) bool {
 	switch v := result.(type) {
 	case bool:
 		return v
@@ -1,9 +1,19 @@
 package janitor
 
 import (
+	"bytes"
+	"context"
+	"log"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestRuleValidation(t *testing.T) {
@@ -52,6 +62,28 @@ func TestRuleValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid notification lead time",
+			rule: Rule{
+				ID:           "test-rule",
+				Resources:    []string{"pods"},
+				JMESPath:     "metadata.labels.test",
+				TTL:          "7d",
+				Notification: "1d",
+			},
+			wantErr: false,
+		},
+		{
+			name: "unlimited notification lead time",
+			rule: Rule{
+				ID:           "test-rule",
+				Resources:    []string{"pods"},
+				JMESPath:     "metadata.labels.test",
+				TTL:          "7d",
+				Notification: "forever",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -235,3 +267,353 @@ rules:
 		t.Error("LoadRules() expected error for nonexistent file")
 	}
 }
+
+func TestWarnRuleConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []Rule
+		wantWarn string
+	}{
+		{
+			name: "conflicting TTLs",
+			rules: []Rule{
+				{ID: "keep-forever", Resources: []string{"*"}, JMESPath: "metadata.labels.team == 'platform'", TTL: "forever"},
+				{ID: "delete-test", Resources: []string{"*"}, JMESPath: "metadata.labels.environment == 'test'", TTL: "1h"},
+			},
+			wantWarn: "keep-forever (ttl forever), delete-test (ttl 1h), rule delete-test takes effect",
+		},
+		{
+			name: "matching rules with the same TTL",
+			rules: []Rule{
+				{ID: "platform", Resources: []string{"*"}, JMESPath: "metadata.labels.team == 'platform'", TTL: "1h"},
+				{ID: "test", Resources: []string{"*"}, JMESPath: "metadata.labels.environment == 'test'", TTL: "1h"},
+			},
+		},
+		{
+			name: "only one rule matches",
+			rules: []Rule{
+				{ID: "platform", Resources: []string{"*"}, JMESPath: "metadata.labels.team == 'platform'", TTL: "forever"},
+				{ID: "prod", Resources: []string{"*"}, JMESPath: "metadata.labels.environment == 'prod'", TTL: "1h"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.rules {
+				if err := tt.rules[i].ValidateAndCompile(); err != nil {
+					t.Fatalf("ValidateAndCompile() error = %v", err)
+				}
+			}
+			j := &Janitor{
+				client: fake.NewSimpleClientset(),
+				config: &Config{
+					DryRun:            true,
+					IncludeResources:  []string{"all"},
+					IncludeNamespaces: []string{"all"},
+					Rules:             tt.rules,
+					WarnRuleConflicts: true,
+				},
+				cache: make(map[string]interface{}),
+			}
+
+			var buf bytes.Buffer
+			log.SetOutput(&buf)
+			defer log.SetOutput(os.Stderr)
+
+			pod := newUnstructuredPod("pod", "default", time.Now(), nil)
+			pod.SetLabels(map[string]string{"team": "platform", "environment": "test"})
+			if err := j.handleResource(context.Background(), pod, make(map[string]int), make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			warned := strings.Contains(buf.String(), "conflicting rules")
+			if tt.wantWarn == "" {
+				if warned {
+					t.Errorf("Expected no conflict warning, got:\n%s", buf.String())
+				}
+			} else if !strings.Contains(buf.String(), "Warning: conflicting rules match Pod default/pod: "+tt.wantWarn) {
+				t.Errorf("Expected conflict warning %q, got:\n%s", tt.wantWarn, buf.String())
+			}
+		})
+	}
+}
+
+func TestDisabledRules(t *testing.T) {
+	content := `
+rules:
+- id: disabled-rule
+  enabled: false
+  resources: ["*"]
+  jmespath: "metadata.labels.test == 'true'"
+  ttl: "not-a-ttl"
+- id: enabled-rule
+  enabled: true
+  resources: ["*"]
+  jmespath: "metadata.labels.test == 'true'"
+  ttl: "7d"
+- id: default-rule
+  resources: ["*"]
+  jmespath: "metadata.labels.test == 'true'"
+  ttl: "1d"
+`
+	tmpfile, err := os.CreateTemp("", "rules*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatalf("Failed to close temp file: %v", err)
+	}
+
+	// The invalid TTL of the disabled rule is not validated
+	rules, err := LoadRules(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+
+	resource := map[string]interface{}{
+		"kind": "Pod",
+		"metadata": map[string]interface{}{
+			"namespace": "default",
+			"labels": map[string]interface{}{
+				"test": "true",
+			},
+		},
+	}
+	want := map[string]bool{
+		"disabled-rule": false,
+		"enabled-rule":  true,
+		"default-rule":  true,
+	}
+	for _, rule := range rules {
+		if got := rule.Matches(resource, map[string]interface{}{}); got != want[rule.ID] {
+			t.Errorf("Rule %s Matches() = %v, want %v", rule.ID, got, want[rule.ID])
+		}
+	}
+
+	// A disabled rule is skipped when evaluating rules, so the next one applies
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:            true,
+			DryRunTable:       true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			Rules:             rules,
+		},
+		cache: make(map[string]interface{}),
+	}
+	pod := newUnstructuredPod("pod", "default", time.Now().Add(-36*time.Hour), nil)
+	pod.SetLabels(map[string]string{"test": "true"})
+	if err := j.handleResource(context.Background(), pod, make(map[string]int), make(map[string]bool)); err != nil {
+		t.Fatalf("handleResource() error = %v", err)
+	}
+	decisions := j.decisions
+	if len(decisions) != 1 || decisions[0].Source != "rule enabled-rule (ttl 7d)" {
+		t.Errorf("Expected the decision to come from enabled-rule, got %+v", decisions)
+	}
+}
+
+func TestRuleMatchesResourceTypes(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+				{Name: "persistentvolumes", Kind: "PersistentVolume", Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "networking.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	j := &Janitor{client: clientset, config: &Config{}}
+	if _, err := j.getResourceTypes(); err != nil {
+		t.Fatalf("getResourceTypes() error = %v", err)
+	}
+
+	rules := map[string]*Rule{
+		"any":              {ID: "any", Resources: []string{"*"}},
+		"any-cluster":      {ID: "any-cluster", Resources: []string{"*"}, ClusterScoped: true},
+		"network-policies": {ID: "network-policies", Resources: []string{"networkpolicies.networking.k8s.io"}},
+	}
+	for _, rule := range rules {
+		rule.JMESPath = "metadata.name"
+		rule.TTL = "1d"
+		if err := rule.ValidateAndCompile(); err != nil {
+			t.Fatalf("Failed to compile rule: %v", err)
+		}
+	}
+
+	newObject := func(apiVersion, kind, namespace string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName("test")
+		return obj
+	}
+
+	tests := []struct {
+		obj  *unstructured.Unstructured
+		want []string
+	}{
+		{obj: newObject("v1", "Pod", "default"), want: []string{"any", "any-cluster"}},
+		{obj: newObject("apps/v1", "Deployment", "default"), want: []string{"any", "any-cluster"}},
+		{obj: newObject("networking.k8s.io/v1", "NetworkPolicy", "default"), want: []string{"any", "any-cluster", "network-policies"}},
+		{obj: newObject("v1", "PersistentVolume", ""), want: []string{"any-cluster"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.obj.GetKind(), func(t *testing.T) {
+			resourceType := j.resourceTypeFor(tt.obj)
+			var got []string
+			for _, id := range []string{"any", "any-cluster", "network-policies"} {
+				if rules[id].MatchesResource(resourceType, tt.obj.Object, map[string]interface{}{}) {
+					got = append(got, id)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Matching rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleNotificationLead(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(),
+		config: &Config{
+			DryRun:             true,
+			IncludeResources:   []string{"all"},
+			IncludeNamespaces:  []string{"all"},
+			DeleteNotification: 600,
+			Rules: []Rule{
+				{
+					ID:           "critical",
+					Resources:    []string{"pods"},
+					JMESPath:     "metadata.labels.tier == 'critical'",
+					TTL:          "3h",
+					Notification: "2h",
+				},
+				{
+					ID:        "other",
+					Resources: []string{"pods"},
+					JMESPath:  "metadata.labels.tier == 'other'",
+					TTL:       "3h",
+				},
+			},
+		},
+		cache: make(map[string]interface{}),
+	}
+	for i := range j.config.Rules {
+		if err := j.config.Rules[i].ValidateAndCompile(); err != nil {
+			t.Fatalf("Failed to compile rule: %v", err)
+		}
+	}
+	if !j.config.NotificationsEnabled() {
+		t.Error("Expected notifications to be enabled")
+	}
+
+	// Both pods expire in 90 minutes, within the lead time of the critical
+	// rule but not within the global lead time of 10 minutes
+	counter := make(map[string]int)
+	for _, tier := range []string{"critical", "other"} {
+		pod := newUnstructuredPod(tier, "default", time.Now().Add(-90*time.Minute), nil)
+		pod.SetLabels(map[string]string{"tier": tier})
+		if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource(%s) error = %v", tier, err)
+		}
+	}
+
+	result := newCleanupResult(counter)
+	want := map[string]RuleStats{
+		"critical": {Matched: 1, Notified: 1},
+		"other":    {Matched: 1},
+	}
+	if !reflect.DeepEqual(result.Rules, want) {
+		t.Errorf("Rules = %v, want %v", result.Rules, want)
+	}
+}
+
+func TestRuleTTLFrom(t *testing.T) {
+	const baseAnnotation = "example.com/approved-at"
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantDeleted bool
+	}{
+		{
+			name:        "TTL counts from the annotation",
+			annotations: map[string]string{baseAnnotation: time.Now().Add(-24 * time.Hour).Format(time.RFC3339)},
+		},
+		{
+			name:        "annotation overrides the deployment time",
+			annotations: map[string]string{baseAnnotation: time.Now().Add(-8 * 24 * time.Hour).Format(time.RFC3339), "deployment-time": time.Now().Format(time.RFC3339)},
+			wantDeleted: true,
+		},
+		{
+			name:        "missing annotation falls back to the creation time",
+			wantDeleted: true,
+		},
+		{
+			name:        "invalid annotation falls back to the creation time",
+			annotations: map[string]string{baseAnnotation: "last tuesday"},
+			wantDeleted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Janitor{
+				client: fake.NewSimpleClientset(),
+				config: &Config{
+					DryRun:                   true,
+					IncludeResources:         []string{"all"},
+					IncludeNamespaces:        []string{"all"},
+					DeploymentTimeAnnotation: "deployment-time",
+					Rules: []Rule{
+						{
+							ID:        "approved",
+							Resources: []string{"pods"},
+							JMESPath:  "metadata.name == 'pod'",
+							TTL:       "7d",
+							TTLFrom:   baseAnnotation,
+						},
+					},
+				},
+				cache: make(map[string]interface{}),
+			}
+			if err := j.config.Rules[0].ValidateAndCompile(); err != nil {
+				t.Fatalf("Failed to compile rule: %v", err)
+			}
+
+			counter := make(map[string]int)
+			pod := newUnstructuredPod("pod", "default", time.Now().Add(-10*24*time.Hour), tt.annotations)
+			if err := j.handleResource(context.Background(), pod, counter, make(map[string]bool)); err != nil {
+				t.Fatalf("handleResource() error = %v", err)
+			}
+
+			result := newCleanupResult(counter)
+			if deleted := result.Rules["approved"].Deleted == 1; deleted != tt.wantDeleted {
+				t.Errorf("Expected deleted = %v, got %+v", tt.wantDeleted, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// planRun determines which namespaces are due for processing in the run
+// starting at now and marks them as processed. Namespaces are due when their
+// interval, from the janitor/interval annotation or the global interval, has
+// elapsed since they were last processed.
+func (j *Janitor) planRun(ctx context.Context, now time.Time) error {
+	namespaces, err := j.scheduledNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Cache the namespace labels and annotations for the run
+	cache := make(map[string]metav1.ObjectMeta, len(namespaces))
+	for _, ns := range namespaces {
+		cache[ns.Name] = metav1.ObjectMeta{Labels: ns.Labels, Annotations: ns.Annotations}
+	}
+	j.namespaceMutex.Lock()
+	j.namespaceCache = cache
+	j.namespaceMutex.Unlock()
+
+	j.scheduleMutex.Lock()
+	defer j.scheduleMutex.Unlock()
+
+	if j.lastProcessed == nil {
+		j.lastProcessed = make(map[string]time.Time)
+	}
+
+	// Cluster-scoped resources always use the global interval
+	intervals := map[string]time.Duration{"": j.globalInterval()}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		intervals[ns.Name] = j.namespaceInterval(ns)
+	}
+
+	due := make(map[string]bool)
+	for namespace, interval := range intervals {
+		last, ok := j.lastProcessed[namespace]
+		if !ok || now.Sub(last) >= interval {
+			due[namespace] = true
+			j.lastProcessed[namespace] = now
+			resetTimeToExpiry(namespace)
+		}
+	}
+
+	// Forget namespaces that no longer exist
+	for namespace := range j.lastProcessed {
+		if _, ok := intervals[namespace]; !ok {
+			delete(j.lastProcessed, namespace)
+			resetTimeToExpiry(namespace)
+		}
+	}
+
+	j.namespaceIntervals = intervals
+	j.dueNamespaces = due
+	j.debugLog("%d of %d namespaces are due for processing", len(due), len(intervals))
+	return nil
+}
+
+// scheduledNamespaces returns the namespaces to schedule. With --namespace
+// only that namespace is fetched, so that the janitor doesn't need to list
+// the namespaces of the cluster.
+func (j *Janitor) scheduledNamespaces(ctx context.Context) ([]corev1.Namespace, error) {
+	if j.config.Namespace != "" {
+		ns, err := j.client.CoreV1().Namespaces().Get(ctx, j.config.Namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			j.debugLog("Namespace %s does not exist, nothing to schedule", j.config.Namespace)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get namespace %s: %v", j.config.Namespace, err)
+		}
+		return []corev1.Namespace{*ns}, nil
+	}
+
+	namespaces, err := j.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	return namespaces.Items, nil
+}
+
+// isDue checks if a namespace is due for processing in the current run. The
+// empty namespace stands for cluster-scoped resources.
+func (j *Janitor) isDue(namespace string) bool {
+	j.scheduleMutex.Lock()
+	defer j.scheduleMutex.Unlock()
+
+	// Everything is due if no run was planned
+	if j.dueNamespaces == nil {
+		return true
+	}
+	return j.dueNamespaces[namespace]
+}
+
+// NextRunIn returns the time until the next namespace is due for processing,
+// which is at most the global interval
+func (j *Janitor) NextRunIn(now time.Time) time.Duration {
+	j.scheduleMutex.Lock()
+	defer j.scheduleMutex.Unlock()
+
+	next := j.globalInterval()
+	for namespace, interval := range j.namespaceIntervals {
+		last, ok := j.lastProcessed[namespace]
+		if !ok {
+			continue
+		}
+		if wait := last.Add(interval).Sub(now); wait < next {
+			next = wait
+		}
+	}
+
+	if next < 0 {
+		return 0
+	}
+	return next
+}
+
+// namespaceInterval returns the interval for a namespace, falling back to the
+// global interval if the namespace has no valid interval annotation
+func (j *Janitor) namespaceInterval(ns *corev1.Namespace) time.Duration {
+	value, ok := ns.Annotations[IntervalAnnotation]
+	if !ok {
+		return j.globalInterval()
+	}
+
+	interval, err := ParseTTL(value)
+	if err != nil || interval <= 0 {
+		log.Printf("Warning: ignoring invalid %s annotation %q on namespace %s", IntervalAnnotation, value, ns.Name)
+		return j.globalInterval()
+	}
+	return interval
+}
+
+// namespaceIntervalOf returns the interval of a namespace from the latest
+// planned run, falling back to the global interval. The empty namespace stands
+// for cluster-scoped resources.
+func (j *Janitor) namespaceIntervalOf(namespace string) time.Duration {
+	j.scheduleMutex.Lock()
+	defer j.scheduleMutex.Unlock()
+
+	if interval, ok := j.namespaceIntervals[namespace]; ok {
+		return interval
+	}
+	return j.globalInterval()
+}
+
+// globalInterval returns the configured loop interval
+func (j *Janitor) globalInterval() time.Duration {
+	return time.Duration(j.config.Interval) * time.Second
+}
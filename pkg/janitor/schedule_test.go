@@ -0,0 +1,117 @@
+package janitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPlanRunNamespaceInterval(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "ephemeral",
+				Annotations: map[string]string{IntervalAnnotation: "5m"},
+			}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "stable"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "invalid",
+				Annotations: map[string]string{IntervalAnnotation: "soon"},
+			}},
+		),
+		config: &Config{Interval: 30 * 60},
+	}
+
+	// Simulate runs every 5 minutes for an hour
+	start := time.Now()
+	processed := make(map[string]int)
+	for i := 0; i < 12; i++ {
+		if err := j.planRun(context.Background(), start.Add(time.Duration(i)*5*time.Minute)); err != nil {
+			t.Fatalf("planRun() error = %v", err)
+		}
+		for _, namespace := range []string{"ephemeral", "stable", "invalid", ""} {
+			if j.isDue(namespace) {
+				processed[namespace]++
+			}
+		}
+	}
+
+	want := map[string]int{
+		"ephemeral": 12,
+		"stable":    2,
+		"invalid":   2,
+		"":          2,
+	}
+	for namespace, count := range want {
+		if processed[namespace] != count {
+			t.Errorf("Namespace %q processed %d times, want %d", namespace, processed[namespace], count)
+		}
+	}
+}
+
+func TestPlanRunSingleNamespace(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Labels: map[string]string{"team": "a"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+	)
+	// A janitor restricted to one namespace may not list namespaces
+	clientset.PrependReactor("list", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", errors.New("denied"))
+	})
+
+	j := &Janitor{client: clientset, config: &Config{Interval: 30 * 60, Namespace: "team-a"}}
+	if err := j.planRun(context.Background(), time.Now()); err != nil {
+		t.Fatalf("planRun() error = %v", err)
+	}
+	if !j.isDue("team-a") || j.isDue("team-b") {
+		t.Errorf("Expected only team-a to be due, got %v", j.dueNamespaces)
+	}
+	if labels := j.namespaceMeta(context.Background(), "team-a").Labels; labels["team"] != "a" {
+		t.Errorf("Expected the namespace labels to be cached, got %v", labels)
+	}
+
+	// A missing namespace leaves nothing to do
+	j = &Janitor{client: clientset, config: &Config{Interval: 30 * 60, Namespace: "gone"}}
+	if err := j.planRun(context.Background(), time.Now()); err != nil {
+		t.Fatalf("planRun() error = %v", err)
+	}
+	if j.isDue("gone") {
+		t.Error("Expected the missing namespace not to be due")
+	}
+}
+
+func TestNextRunIn(t *testing.T) {
+	j := &Janitor{
+		client: fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "ephemeral",
+				Annotations: map[string]string{IntervalAnnotation: "5m"},
+			}},
+		),
+		config: &Config{Interval: 30 * 60},
+	}
+
+	now := time.Now()
+	if got := j.NextRunIn(now); got != 30*time.Minute {
+		t.Errorf("NextRunIn() before the first run = %v, want 30m", got)
+	}
+
+	if err := j.planRun(context.Background(), now); err != nil {
+		t.Fatalf("planRun() error = %v", err)
+	}
+	if got := j.NextRunIn(now.Add(time.Minute)); got != 4*time.Minute {
+		t.Errorf("NextRunIn() = %v, want 4m", got)
+	}
+	if got := j.NextRunIn(now.Add(10 * time.Minute)); got != 0 {
+		t.Errorf("NextRunIn() when overdue = %v, want 0", got)
+	}
+}
@@ -0,0 +1,59 @@
+package janitor
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// NewServeMux returns the handler of the janitor's HTTP server, serving the
+// health and metrics endpoints, the status and readiness of j if it is not
+// nil and, if enabled, the pprof endpoints
+func NewServeMux(config *Config, j *Janitor) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+	mux.Handle("/metrics", metricsHandler())
+	if j != nil {
+		mux.HandleFunc("/status", j.statusHandler)
+		mux.HandleFunc("/readyz", j.readyzHandler)
+	}
+
+	if config.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	return mux
+}
+
+// StartServer serves the janitor's HTTP endpoints on the configured listen
+// address until the context is canceled
+func StartServer(ctx context.Context, config *Config, j *Janitor) {
+	server := &http.Server{
+		Addr:              config.ListenAddress,
+		Handler:           NewServeMux(config, j),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		log.Printf("Serving HTTP endpoints on %s", config.ListenAddress)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("HTTP server failed: %v", err)
+		}
+	}()
+}
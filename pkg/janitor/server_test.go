@@ -0,0 +1,129 @@
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServeMuxPprof(t *testing.T) {
+	tests := []struct {
+		name        string
+		enablePprof bool
+		wantStatus  int
+	}{
+		{
+			name:        "pprof disabled by default",
+			enablePprof: false,
+			wantStatus:  http.StatusNotFound,
+		},
+		{
+			name:        "pprof enabled",
+			enablePprof: true,
+			wantStatus:  http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(NewServeMux(&Config{EnablePprof: tt.enablePprof}, nil))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/debug/pprof/")
+			if err != nil {
+				t.Fatalf("Failed to get pprof index: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			resp, err = http.Get(server.URL + "/healthz")
+			if err != nil {
+				t.Fatalf("Failed to get health endpoint: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected health endpoint status %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestServeMuxStatus(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"},
+		newUnstructuredPod("expired", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"}),
+	)
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			DryRun:            true,
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+		},
+		cache: make(map[string]interface{}),
+	}
+	server := httptest.NewServer(NewServeMux(&Config{}, j))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d before the first run, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	start := time.Now()
+	if _, err := j.CleanUp(context.Background()); err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+
+	resp, err = http.Get(server.URL + "/status")
+	if err != nil {
+		t.Fatalf("Failed to get status: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var status RunStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if status.LastRun.Before(start.Add(-time.Second)) || status.DurationSeconds < 0 {
+		t.Errorf("Unexpected run time in status %+v", status)
+	}
+	if status.LastError != "" {
+		t.Errorf("Expected no error, got %q", status.LastError)
+	}
+	// The namespace without a TTL is processed as well
+	if status.Result == nil || status.Result.Processed != 2 || status.Result.Deleted["pods"] != 1 {
+		t.Errorf("Expected the namespace and the deleted pod to be processed, got %+v", status.Result)
+	}
+}
@@ -0,0 +1,43 @@
+package janitor
+
+import (
+	"fmt"
+	"log"
+
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// Minimum apiserver versions of the optional features the janitor uses
+var (
+	minServerDryRunVersion     = utilversion.MustParseGeneric("1.18.0")
+	minEndpointSlicesV1Version = utilversion.MustParseGeneric("1.21.0")
+)
+
+// CheckServerVersion queries and logs the version of the apiserver and
+// disables the features it doesn't support. Server-side dry-run falls back to
+// client-side dry-run, and Services are checked for endpoints with Endpoints
+// instead of EndpointSlices. If the version can't be determined, all features
+// stay enabled.
+func (j *Janitor) CheckServerVersion() error {
+	info, err := j.discoveryClient().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get server version: %v", err)
+	}
+	log.Printf("Connected to Kubernetes %s", info.GitVersion)
+
+	serverVersion, err := utilversion.ParseGeneric(info.GitVersion)
+	if err != nil {
+		log.Printf("Warning: failed to parse server version %q, assuming all features are supported: %v", info.GitVersion, err)
+		return nil
+	}
+
+	if j.config.DryRunServer && !serverVersion.AtLeast(minServerDryRunVersion) {
+		log.Printf("Warning: server-side dry-run requires Kubernetes %s or later, falling back to client-side dry-run", minServerDryRunVersion)
+		j.config.DryRunServer = false
+	}
+	if !serverVersion.AtLeast(minEndpointSlicesV1Version) {
+		j.debugLog("EndpointSlices require Kubernetes %s or later, using Endpoints instead", minEndpointSlicesV1Version)
+		j.noEndpointSlices = true
+	}
+	return nil
+}
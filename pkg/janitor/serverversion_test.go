@@ -0,0 +1,64 @@
+package janitor
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckServerVersion(t *testing.T) {
+	tests := []struct {
+		name                 string
+		gitVersion           string
+		wantDryRunServer     bool
+		wantNoEndpointSlices bool
+	}{
+		{
+			name:             "current cluster supports all features",
+			gitVersion:       "v1.28.3",
+			wantDryRunServer: true,
+		},
+		{
+			name:                 "provider suffix is ignored",
+			gitVersion:           "v1.20.15-gke.1000",
+			wantDryRunServer:     true,
+			wantNoEndpointSlices: true,
+		},
+		{
+			name:                 "old cluster falls back to client-side dry-run",
+			gitVersion:           "v1.16.3",
+			wantNoEndpointSlices: true,
+		},
+		{
+			name:             "unparsable version keeps all features",
+			gitVersion:       "unknown",
+			wantDryRunServer: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: tt.gitVersion}
+			j := &Janitor{
+				client: client,
+				config: &Config{DryRun: true, DryRunServer: true},
+			}
+
+			if err := j.CheckServerVersion(); err != nil {
+				t.Fatalf("CheckServerVersion() error = %v", err)
+			}
+			if j.config.DryRunServer != tt.wantDryRunServer {
+				t.Errorf("DryRunServer = %v, want %v", j.config.DryRunServer, tt.wantDryRunServer)
+			}
+			if !j.config.DryRun {
+				t.Error("Expected dry-run to stay enabled")
+			}
+			if j.noEndpointSlices != tt.wantNoEndpointSlices {
+				t.Errorf("noEndpointSlices = %v, want %v", j.noEndpointSlices, tt.wantNoEndpointSlices)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package janitor
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPNotifier sends notifications as email via an SMTP server
+type SMTPNotifier struct {
+	Host     string // host:port of the SMTP server
+	From     string
+	To       []string
+	Username string
+	Password string
+	TLS      bool // use implicit TLS instead of STARTTLS
+}
+
+// Send sends the notification message as a plain text email
+func (n *SMTPNotifier) Send(message WebhookMessage) error {
+	serverName, _, err := net.SplitHostPort(n.Host)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP host %q: %v", n.Host, err)
+	}
+	tlsConfig := &tls.Config{ServerName: serverName}
+
+	var client *smtp.Client
+	if n.TLS {
+		conn, err := tls.Dial("tcp", n.Host, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("failed to connect to SMTP server: %v", err)
+		}
+		client, err = smtp.NewClient(conn, serverName)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to create SMTP client: %v", err)
+		}
+	} else {
+		client, err = smtp.Dial(n.Host)
+		if err != nil {
+			return fmt.Errorf("failed to connect to SMTP server: %v", err)
+		}
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return fmt.Errorf("failed to start TLS: %v", err)
+			}
+		}
+	}
+	defer client.Close()
+
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, serverName)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+
+	if err := client.Mail(n.From); err != nil {
+		return fmt.Errorf("failed to set SMTP sender: %v", err)
+	}
+	for _, to := range n.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to set SMTP recipient %s: %v", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP data: %v", err)
+	}
+	if _, err := w.Write(n.buildEmail(message)); err != nil {
+		return fmt.Errorf("failed to write email: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+
+	return client.Quit()
+}
+
+// buildEmail renders the notification message as an RFC 5322 email
+func (n *SMTPNotifier) buildEmail(message WebhookMessage) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", notificationSubject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(message.Message)
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,108 @@
+package janitor
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer accepts a single SMTP session and records the envelope and data
+type fakeSMTPServer struct {
+	listener   net.Listener
+	from       string
+	recipients []string
+	data       string
+	done       chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	s := &fakeSMTPServer{listener: listener, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.done)
+
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 fake")
+		case "MAIL":
+			s.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.recipients = append(s.recipients, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	defer server.listener.Close()
+
+	notifier := &SMTPNotifier{
+		Host: server.listener.Addr().String(),
+		From: "janitor@example.com",
+		To:   []string{"team-a@example.com", "team-b@example.com"},
+	}
+
+	if err := notifier.Send(WebhookMessage{Message: "Pod default/test-pod will be deleted"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	<-server.done
+
+	if server.from != "janitor@example.com" {
+		t.Errorf("Expected sender janitor@example.com, got %s", server.from)
+	}
+	if strings.Join(server.recipients, ",") != "team-a@example.com,team-b@example.com" {
+		t.Errorf("Expected recipients team-a@example.com,team-b@example.com, got %v", server.recipients)
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(server.data)))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("Failed to parse email headers: %v", err)
+	}
+	if header.Get("Subject") != notificationSubject {
+		t.Errorf("Expected subject %q, got %q", notificationSubject, header.Get("Subject"))
+	}
+	if !strings.Contains(server.data, "Pod default/test-pod will be deleted") {
+		t.Errorf("Expected email body to contain the notification message, got %q", server.data)
+	}
+}
@@ -0,0 +1,54 @@
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// SNSPublishAPI is the subset of the SNS client used to publish notifications
+type SNSPublishAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNSNotifier publishes notifications to an AWS SNS topic
+type SNSNotifier struct {
+	Client   SNSPublishAPI
+	TopicARN string
+}
+
+// NewSNSNotifier creates an SNSNotifier using the default AWS credential chain
+func NewSNSNotifier(ctx context.Context, topicARN string) (*SNSNotifier, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+
+	return &SNSNotifier{
+		Client:   sns.NewFromConfig(cfg),
+		TopicARN: topicARN,
+	}, nil
+}
+
+// Send publishes the message to the SNS topic using the same JSON payload as the webhook
+func (n *SNSNotifier) Send(message WebhookMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SNS message: %v", err)
+	}
+
+	_, err = n.Client.Publish(context.Background(), &sns.PublishInput{
+		TopicArn: aws.String(n.TopicARN),
+		Subject:  aws.String(notificationSubject),
+		Message:  aws.String(string(data)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish SNS message: %v", err)
+	}
+
+	return nil
+}
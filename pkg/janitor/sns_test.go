@@ -0,0 +1,48 @@
+package janitor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+type mockSNSClient struct {
+	inputs []*sns.PublishInput
+}
+
+func (m *mockSNSClient) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	m.inputs = append(m.inputs, params)
+	return &sns.PublishOutput{MessageId: aws.String("test-id")}, nil
+}
+
+func TestSNSNotifierSend(t *testing.T) {
+	client := &mockSNSClient{}
+	notifier := &SNSNotifier{
+		Client:   client,
+		TopicARN: "arn:aws:sns:us-east-1:123456789012:kube-janitor",
+	}
+
+	if err := notifier.Send(WebhookMessage{Message: "Pod default/test-pod will be deleted"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(client.inputs) != 1 {
+		t.Fatalf("Expected Publish to be called once, got %d", len(client.inputs))
+	}
+
+	input := client.inputs[0]
+	if aws.ToString(input.TopicArn) != notifier.TopicARN {
+		t.Errorf("Expected topic ARN %s, got %s", notifier.TopicARN, aws.ToString(input.TopicArn))
+	}
+
+	var payload WebhookMessage
+	if err := json.Unmarshal([]byte(aws.ToString(input.Message)), &payload); err != nil {
+		t.Fatalf("Failed to decode SNS message: %v", err)
+	}
+	if payload.Message != "Pod default/test-pod will be deleted" {
+		t.Errorf("Expected message %q, got %q", "Pod default/test-pod will be deleted", payload.Message)
+	}
+}
@@ -0,0 +1,95 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deletionPending checks whether the deletion of an expired resource has to
+// wait, while deletions are paused, for a confirmation, for the expired grace
+// period and then for the soft delete grace period. It returns true and
+// records the skip if the deletion has to wait. A paused run is checked first,
+// so that it doesn't mark anything either.
+func (j *Janitor) deletionPending(ctx context.Context, obj metav1.Object, counter map[string]int, source string) (bool, error) {
+	if j.paused.Load() {
+		log.Printf("**PAUSED**: Would delete %s %s/%s", objectGVK(obj).Kind, obj.GetNamespace(), obj.GetName())
+		j.skipResource(ctx, obj, counter, SkipReasonPaused, source, "deletions are paused")
+		return true, nil
+	}
+
+	if !j.deletionConfirmed(obj) {
+		log.Printf("Not deleting %s %s/%s, it is not one of the confirmed deletions", objectGVK(obj).Kind, obj.GetNamespace(), obj.GetName())
+		j.skipResource(ctx, obj, counter, SkipReasonNotConfirmed, source, "not confirmed")
+		return true, nil
+	}
+
+	pending, err := j.expiredGracePending(ctx, obj, counter, source)
+	if err != nil || pending {
+		return pending, err
+	}
+	return j.softDeletePending(ctx, obj, counter, source)
+}
+
+// expiredGracePending makes sure that a resource is seen expired for a while,
+// e.g. by more than one run, before it is deleted: the first time it is seen
+// expired it is marked with the expired since annotation, and it is only
+// deleted once the mark is older than the grace period. This protects against
+// resources that appear expired by mistake, e.g. after a clock skew or an
+// import.
+func (j *Janitor) expiredGracePending(ctx context.Context, obj metav1.Object, counter map[string]int, source string) (bool, error) {
+	return j.gracePending(ctx, obj, counter, source, ExpiredSinceAnnotation, j.config.ExpiredGrace,
+		SkipReasonExpiredGracePending, "as expired", "seen expired")
+}
+
+// softDeletePending implements the two-phase soft delete: an expired resource
+// is first marked with the soft delete annotation and only deleted once the
+// mark is older than the grace period. It returns true and records the skip if
+// the deletion has to wait.
+func (j *Janitor) softDeletePending(ctx context.Context, obj metav1.Object, counter map[string]int, source string) (bool, error) {
+	return j.gracePending(ctx, obj, counter, source, SoftDeleteAnnotation, j.config.SoftDeleteGrace,
+		SkipReasonSoftDeletePending, "for deletion", "soft deleted")
+}
+
+// gracePending marks an expired resource with the current time in the given
+// annotation and keeps it until the mark is older than the grace period. mark
+// and state describe the mark in logs and skip reasons.
+func (j *Janitor) gracePending(ctx context.Context, obj metav1.Object, counter map[string]int, source, annotation string, grace time.Duration, skipReason, mark, state string) (bool, error) {
+	if grace <= 0 {
+		return false, nil
+	}
+
+	kind := objectGVK(obj).Kind
+	if value, ok := obj.GetAnnotations()[annotation]; ok {
+		markedAt, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			deleteAt := markedAt.Add(grace)
+			if time.Now().After(deleteAt) {
+				return false, nil
+			}
+			j.infoLog("Resource %s %s/%s was marked %s on %s, waiting for the grace period",
+				kind, obj.GetNamespace(), obj.GetName(), mark, value)
+			j.skipResource(ctx, obj, counter, skipReason, source, fmt.Sprintf("%s, will be deleted on %s", state, deleteAt.Format(time.RFC3339)))
+			return true, nil
+		}
+		log.Printf("Warning: invalid %s annotation %q on %s %s/%s, marking it again",
+			annotation, value, kind, obj.GetNamespace(), obj.GetName())
+	}
+
+	now := time.Now().UTC()
+	if j.dryRun() {
+		log.Printf("**DRY-RUN**: Would mark %s %s/%s %s", kind, obj.GetNamespace(), obj.GetName(), mark)
+	} else {
+		j.infoLog("Marking %s %s/%s %s", kind, obj.GetNamespace(), obj.GetName(), mark)
+		if err := j.patchAnnotation(ctx, obj, annotation, now.Format(time.RFC3339)); err != nil {
+			return false, fmt.Errorf("failed to mark %s %s/%s %s: %v", kind, obj.GetNamespace(), obj.GetName(), mark, err)
+		}
+	}
+
+	deleteAt := now.Add(grace)
+	j.skipResource(ctx, obj, counter, skipReason, source, fmt.Sprintf("%s, will be deleted on %s", state, deleteAt.Format(time.RFC3339)))
+	return true, nil
+}
@@ -0,0 +1,150 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSoftDelete(t *testing.T) {
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	pod := newUnstructuredPod("pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+	j := &Janitor{
+		client:        fake.NewSimpleClientset(),
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			SoftDeleteGrace:   time.Hour,
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	handle := func() *CleanupResult {
+		t.Helper()
+		obj, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "pod", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get pod: %v", err)
+		}
+		counter := make(map[string]int)
+		if err := j.handleResource(context.Background(), obj, counter, make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource() error = %v", err)
+		}
+		return newCleanupResult(counter)
+	}
+
+	// Mark phase: the expired pod is annotated instead of deleted
+	if result := handle(); result.Skipped[SkipReasonSoftDeletePending] != 1 || result.Deleted["pods"] != 0 {
+		t.Fatalf("Expected the pod to be soft deleted, got %+v", result)
+	}
+	obj, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "pod", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the pod to still exist: %v", err)
+	}
+	markedAt, err := time.Parse(time.RFC3339, obj.GetAnnotations()[SoftDeleteAnnotation])
+	if err != nil || time.Since(markedAt) > time.Minute {
+		t.Fatalf("Expected a recent %s annotation, got %q", SoftDeleteAnnotation, obj.GetAnnotations()[SoftDeleteAnnotation])
+	}
+
+	// Within the grace period the pod is kept
+	if result := handle(); result.Skipped[SkipReasonSoftDeletePending] != 1 || result.Deleted["pods"] != 0 {
+		t.Fatalf("Expected the pod to be kept during the grace period, got %+v", result)
+	}
+
+	// Delete phase: once the mark is older than the grace period the pod is deleted
+	obj.SetAnnotations(map[string]string{
+		TTLAnnotation:        "1h",
+		SoftDeleteAnnotation: time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+	})
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Update(context.Background(), obj, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update pod: %v", err)
+	}
+	if result := handle(); result.Deleted["pods"] != 1 {
+		t.Fatalf("Expected the pod to be deleted after the grace period, got %+v", result)
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "pod", metav1.GetOptions{}); err == nil {
+		t.Error("Expected the pod to be deleted")
+	}
+}
+
+func TestExpiredGrace(t *testing.T) {
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	pod := newUnstructuredPod("pod", "default", time.Now().Add(-2*time.Hour), map[string]string{TTLAnnotation: "1h"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), pod)
+	j := &Janitor{
+		client:        fake.NewSimpleClientset(),
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			ExpiredGrace:      10 * time.Minute,
+			SoftDeleteGrace:   time.Hour,
+		},
+		cache: make(map[string]interface{}),
+	}
+
+	get := func() *unstructured.Unstructured {
+		t.Helper()
+		obj, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "pod", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get pod: %v", err)
+		}
+		return obj
+	}
+	handle := func() *CleanupResult {
+		t.Helper()
+		counter := make(map[string]int)
+		if err := j.handleResource(context.Background(), get(), counter, make(map[string]bool)); err != nil {
+			t.Fatalf("handleResource() error = %v", err)
+		}
+		return newCleanupResult(counter)
+	}
+
+	// First run: the expired pod is only marked as expired
+	if result := handle(); result.Skipped[SkipReasonExpiredGracePending] != 1 || result.Deleted["pods"] != 0 {
+		t.Fatalf("Expected the pod to wait for the expired grace period, got %+v", result)
+	}
+	obj := get()
+	seenAt, err := time.Parse(time.RFC3339, obj.GetAnnotations()[ExpiredSinceAnnotation])
+	if err != nil || time.Since(seenAt) > time.Minute {
+		t.Fatalf("Expected a recent %s annotation, got %q", ExpiredSinceAnnotation, obj.GetAnnotations()[ExpiredSinceAnnotation])
+	}
+	if _, ok := obj.GetAnnotations()[SoftDeleteAnnotation]; ok {
+		t.Error("Expected no soft delete mark before the expired grace period")
+	}
+
+	// Second run within the grace period: the pod is kept
+	if result := handle(); result.Skipped[SkipReasonExpiredGracePending] != 1 {
+		t.Fatalf("Expected the pod to be kept during the expired grace period, got %+v", result)
+	}
+
+	// Once seen expired for longer than the grace period, the pod moves on
+	// to the soft delete
+	obj.SetAnnotations(map[string]string{
+		TTLAnnotation:          "1h",
+		ExpiredSinceAnnotation: time.Now().Add(-20 * time.Minute).UTC().Format(time.RFC3339),
+	})
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Update(context.Background(), obj, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("Failed to update pod: %v", err)
+	}
+	if result := handle(); result.Skipped[SkipReasonSoftDeletePending] != 1 || result.Deleted["pods"] != 0 {
+		t.Fatalf("Expected the pod to be soft deleted after the expired grace period, got %+v", result)
+	}
+
+	// Without a soft delete grace period, the pod is deleted right away
+	j.config.SoftDeleteGrace = 0
+	if result := handle(); result.Deleted["pods"] != 1 {
+		t.Fatalf("Expected the pod to be deleted after the expired grace period, got %+v", result)
+	}
+	if _, err := dynamicClient.Resource(podsGVR).Namespace("default").Get(context.Background(), "pod", metav1.GetOptions{}); err == nil {
+		t.Error("Expected the pod to be deleted")
+	}
+}
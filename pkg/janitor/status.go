@@ -0,0 +1,70 @@
+package janitor
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// RunStatus describes the latest cleanup run
+type RunStatus struct {
+	// LastRun is when the latest run started
+	LastRun time.Time `json:"last_run"`
+	// DurationSeconds is how long the latest run took
+	DurationSeconds float64 `json:"duration_seconds"`
+	// Result summarizes the latest run, nil if it failed before processing
+	// any resources
+	Result *CleanupResult `json:"result,omitempty"`
+	// LastError is the error of the latest run, empty if it succeeded
+	LastError string `json:"last_error,omitempty"`
+}
+
+// recordStatus remembers the outcome of a cleanup run for the status endpoint
+func (j *Janitor) recordStatus(start time.Time, result *CleanupResult, err error) {
+	status := &RunStatus{
+		LastRun:         start,
+		DurationSeconds: time.Since(start).Seconds(),
+		Result:          result,
+	}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+
+	j.statusMutex.Lock()
+	defer j.statusMutex.Unlock()
+	j.status = status
+}
+
+// Status returns the status of the latest cleanup run, or nil if no run has
+// finished yet
+func (j *Janitor) Status() *RunStatus {
+	j.statusMutex.Lock()
+	defer j.statusMutex.Unlock()
+	return j.status
+}
+
+// statusHandler serves the status of the latest cleanup run as JSON
+func (j *Janitor) statusHandler(w http.ResponseWriter, r *http.Request) {
+	status := j.Status()
+	if status == nil {
+		http.Error(w, "no cleanup run has finished yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// readyzHandler reports whether the janitor is ready, which it is not while
+// discovery finds too few resource types
+func (j *Janitor) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	j.statusMutex.Lock()
+	notReady := j.notReady
+	j.statusMutex.Unlock()
+
+	if notReady != nil {
+		http.Error(w, notReady.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,74 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation name used for all janitor spans
+const tracerName = "github.com/dschaaff/kube-janitor"
+
+// Span attribute keys
+const (
+	attrKind      = attribute.Key("k8s.resource.kind")
+	attrNamespace = attribute.Key("k8s.namespace.name")
+	attrName      = attribute.Key("k8s.resource.name")
+	attrCount     = attribute.Key("kube_janitor.resource.count")
+)
+
+// InitTracing installs a global tracer provider that exports spans to the
+// given OTLP/HTTP endpoint (e.g. http://otel-collector:4318). The returned
+// function flushes and stops the exporter.
+func InitTracing(ctx context.Context, endpoint string, version string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("kube-janitor"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// startSpan starts a span using the janitor's tracer, falling back to the
+// global tracer provider (a no-op unless tracing is configured)
+func (j *Janitor) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := j.tracer
+	if tracer == nil {
+		tracer = otel.Tracer(tracerName)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on the span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
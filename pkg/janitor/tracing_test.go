@@ -0,0 +1,92 @@
+package janitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCleanUpSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}})
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	pod := newUnstructuredPod("expired-pod", "default", time.Now().Add(-2*time.Hour), map[string]string{
+		TTLAnnotation: "1h",
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Version: "v1", Resource: "pods"}: "PodList"}, pod)
+
+	j := &Janitor{
+		client:        clientset,
+		dynamicClient: dynamicClient,
+		config: &Config{
+			IncludeResources:  []string{"all"},
+			IncludeNamespaces: []string{"all"},
+			Parallelism:       2,
+		},
+		cache:  make(map[string]interface{}),
+		tracer: provider.Tracer(tracerName),
+	}
+
+	if _, err := j.CleanUp(context.Background()); err != nil {
+		t.Fatalf("CleanUp() error = %v", err)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	// Expected span name -> parent span name
+	wantParents := map[string]string{
+		"CleanUp":                 "",
+		"cleanupResourceType":     "CleanUp",
+		"listNamespacedResources": "cleanupResourceType",
+		"deleteResource":          "cleanupResourceType",
+	}
+	for name, parentName := range wantParents {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected span %s to be recorded", name)
+			continue
+		}
+		if parentName == "" {
+			if span.Parent.IsValid() {
+				t.Errorf("Expected span %s to be a root span", name)
+			}
+			continue
+		}
+		if span.Parent.SpanID() != spans[parentName].SpanContext.SpanID() {
+			t.Errorf("Expected span %s to be a child of %s", name, parentName)
+		}
+	}
+
+	attrs := make(map[string]string)
+	for _, attr := range spans["deleteResource"].Attributes {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	if attrs[string(attrKind)] != "Pod" || attrs[string(attrNamespace)] != "default" || attrs[string(attrName)] != "expired-pod" {
+		t.Errorf("Unexpected deleteResource span attributes: %v", attrs)
+	}
+}
@@ -2,14 +2,38 @@ package janitor
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported webhook payload formats
+const (
+	WebhookFormatJSON  = "json"
+	WebhookFormatSlack = "slack"
 )
 
+// DefaultWebhookSignatureHeader is the header that carries the payload signature
+const DefaultWebhookSignatureHeader = "X-Signature"
+
 // WebhookMessage represents a message to be sent to a webhook
 type WebhookMessage struct {
 	Message string `json:"message"`
+
+	// The context name of the cluster, if configured
+	Cluster string `json:"cluster,omitempty"`
+
+	// The resource the message is about, used by backends that need
+	// structured data (e.g. for deduplication). Not part of the payload.
+	Kind      string `json:"-"`
+	Namespace string `json:"-"`
+	Name      string `json:"-"`
 }
 
 // WebhookClient interface for webhook notifications
@@ -17,9 +41,27 @@ type WebhookClient interface {
 	Send(message WebhookMessage) error
 }
 
+// WebhookTarget configures an additional webhook that receives delete notifications
+type WebhookTarget struct {
+	URL     string            `yaml:"url"`
+	Format  string            `yaml:"format"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// WebhookTargetsFile represents the structure of the YAML webhook targets file
+type WebhookTargetsFile struct {
+	Targets []WebhookTarget `yaml:"targets"`
+}
+
 // DefaultWebhookClient implements WebhookClient
 type DefaultWebhookClient struct {
-	URL string
+	URL     string
+	Format  string // payload format, defaults to WebhookFormatJSON
+	Headers map[string]string
+
+	// Secret enables HMAC-SHA256 signing of the payload when set
+	Secret          string
+	SignatureHeader string // defaults to DefaultWebhookSignatureHeader
 }
 
 func (c *DefaultWebhookClient) Send(message WebhookMessage) error {
@@ -27,12 +69,33 @@ func (c *DefaultWebhookClient) Send(message WebhookMessage) error {
 		return nil
 	}
 
-	jsonData, err := json.Marshal(message)
+	var payload interface{} = message
+	if c.Format == WebhookFormatSlack {
+		payload = map[string]string{"text": message.Message}
+	}
+
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal webhook message: %v", err)
 	}
 
-	resp, err := http.Post(c.URL, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.Secret != "" {
+		header := c.SignatureHeader
+		if header == "" {
+			header = DefaultWebhookSignatureHeader
+		}
+		req.Header.Set(header, signWebhookPayload(c.Secret, jsonData))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send webhook request: %v", err)
 	}
@@ -44,3 +107,36 @@ func (c *DefaultWebhookClient) Send(message WebhookMessage) error {
 
 	return nil
 }
+
+// signWebhookPayload computes the "sha256=<hex>" HMAC signature of a payload
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadWebhookTargets loads webhook targets from a YAML file
+func LoadWebhookTargets(filename string) ([]WebhookTarget, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook targets file: %v", err)
+	}
+
+	var targetsFile WebhookTargetsFile
+	if err := yaml.Unmarshal(data, &targetsFile); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook targets file: %v", err)
+	}
+
+	for i, target := range targetsFile.Targets {
+		if target.URL == "" {
+			return nil, fmt.Errorf("invalid webhook target #%d: url is required", i)
+		}
+		switch target.Format {
+		case "", WebhookFormatJSON, WebhookFormatSlack:
+		default:
+			return nil, fmt.Errorf("invalid webhook target #%d: unknown format %q", i, target.Format)
+		}
+	}
+
+	return targetsFile.Targets, nil
+}
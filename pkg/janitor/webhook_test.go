@@ -1,10 +1,16 @@
 package janitor
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -72,7 +78,7 @@ func TestSendWebhookNotification(t *testing.T) {
 			}
 
 			// Test webhook notification
-			err := SendWebhookNotification(tt.message)
+			err := SendWebhookNotification(nil, tt.message)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SendWebhookNotification() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -85,8 +91,188 @@ func TestWebhookNotificationWithInvalidURL(t *testing.T) {
 	os.Setenv("WEBHOOK_URL", "invalid-url")
 	defer os.Setenv("WEBHOOK_URL", oldURL)
 
-	err := SendWebhookNotification("test message")
+	err := SendWebhookNotification(nil, "test message")
 	if err == nil {
 		t.Error("Expected error for invalid webhook URL, got nil")
 	}
 }
+
+func TestDefaultWebhookClientFormatAndHeaders(t *testing.T) {
+	var body map[string]string
+	var authHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &DefaultWebhookClient{
+		URL:     server.URL,
+		Format:  WebhookFormatSlack,
+		Headers: map[string]string{"Authorization": "Bearer test-token"},
+	}
+	if err := client.Send(WebhookMessage{Message: "test message"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if body["text"] != "test message" {
+		t.Errorf("Expected Slack payload with text %q, got %v", "test message", body)
+	}
+	if authHeader != "Bearer test-token" {
+		t.Errorf("Expected Authorization header %q, got %q", "Bearer test-token", authHeader)
+	}
+}
+
+func TestLoadWebhookTargets(t *testing.T) {
+	content := `
+targets:
+- url: https://hooks.slack.com/services/test
+  format: slack
+- url: https://webhook.test/notify
+  headers:
+    Authorization: Bearer test-token
+`
+	tmpfile, err := os.CreateTemp("", "webhooks*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatalf("Failed to close temp file: %v", err)
+	}
+
+	targets, err := LoadWebhookTargets(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("LoadWebhookTargets() error = %v", err)
+	}
+
+	if len(targets) != 2 {
+		t.Fatalf("LoadWebhookTargets() got %d targets, want 2", len(targets))
+	}
+	if targets[0].Format != WebhookFormatSlack {
+		t.Errorf("Expected first target format %q, got %q", WebhookFormatSlack, targets[0].Format)
+	}
+	if targets[1].Headers["Authorization"] != "Bearer test-token" {
+		t.Errorf("Expected second target Authorization header, got %v", targets[1].Headers)
+	}
+
+	_, err = LoadWebhookTargets("nonexistent.yaml")
+	if err == nil {
+		t.Error("LoadWebhookTargets() expected error for nonexistent file")
+	}
+}
+
+func TestDefaultWebhookClientSignature(t *testing.T) {
+	tests := []struct {
+		name            string
+		signatureHeader string
+		wantHeader      string
+	}{
+		{
+			name:       "default signature header",
+			wantHeader: DefaultWebhookSignatureHeader,
+		},
+		{
+			name:            "custom signature header",
+			signatureHeader: "X-Hub-Signature-256",
+			wantHeader:      "X-Hub-Signature-256",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var signature string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				signature = r.Header.Get(tt.wantHeader)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			client := &DefaultWebhookClient{
+				URL:             server.URL,
+				Secret:          "test-secret",
+				SignatureHeader: tt.signatureHeader,
+			}
+			if err := client.Send(WebhookMessage{Message: "test message"}); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			mac := hmac.New(sha256.New, []byte("test-secret"))
+			mac.Write(body)
+			want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+			if signature != want {
+				t.Errorf("Expected signature %q, got %q", want, signature)
+			}
+		})
+	}
+}
+
+func TestSendWebhookNotificationPrefersConfigURL(t *testing.T) {
+	var received []string
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = append(received, name)
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	configServer := newServer("config")
+	defer configServer.Close()
+	envServer := newServer("env")
+	defer envServer.Close()
+
+	oldURL := os.Getenv("WEBHOOK_URL")
+	os.Setenv("WEBHOOK_URL", envServer.URL)
+	defer os.Setenv("WEBHOOK_URL", oldURL)
+
+	if err := SendWebhookNotification(&Config{WebhookURL: configServer.URL}, "test message"); err != nil {
+		t.Fatalf("SendWebhookNotification() error = %v", err)
+	}
+	// Without a configured URL the environment variable is used
+	if err := SendWebhookNotification(&Config{}, "test message"); err != nil {
+		t.Fatalf("SendWebhookNotification() error = %v", err)
+	}
+
+	if want := []string{"config", "env"}; !reflect.DeepEqual(received, want) {
+		t.Errorf("Expected webhooks %v, got %v", want, received)
+	}
+}
+
+func TestWebhookURLFlag(t *testing.T) {
+	var payload WebhookMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := NewConfig()
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"-webhook-url", server.URL}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	config.ParseStringFlags()
+	if config.WebhookURL != server.URL {
+		t.Errorf("Expected WebhookURL %q, got %q", server.URL, config.WebhookURL)
+	}
+
+	notifier, err := NewNotifier(config)
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+	if err := notifier.Send(WebhookMessage{Message: "test message"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if payload.Message != "test message" {
+		t.Errorf("Expected the notification at the flag URL, got %+v", payload)
+	}
+}